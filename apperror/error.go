@@ -0,0 +1,272 @@
+package apperror
+
+import (
+	"errors"
+	"fmt"
+	"miHttpServer/i18n"
+	"net/http"
+)
+
+// 错误类别，决定响应结构体中的code（requestError或serverError）
+type Kind int
+
+const (
+	// 客户端请求错误
+	KindRequest Kind = iota
+	// 服务端处理错误
+	KindServer
+)
+
+// 应用错误，handler统一返回该类型的错误
+type Error struct {
+	// 类别
+	Kind Kind
+	// HTTP状态码
+	Status int
+	// 细粒度错误码，同时也是i18n的消息编号，例如ITEM_NOT_FOUND
+	Code string
+	// 返回给客户端的错误详情
+	Detail *i18n.Error
+	// 内部错误原因，只记录日志，不返回给客户端
+	Cause error
+	// 建议客户端重试的等待时间（秒），大于0时响应Retry-After请求头
+	RetryAfter int
+	// 字段级别的校验错误
+	Fields []FieldError
+	// 返回给客户端的附加信息，例如冲突商品的item_id
+	Meta map[string]interface{}
+}
+
+// 单个字段的校验错误
+type FieldError struct {
+	// 字段名（json字段名）
+	Field string
+	// 错误码，同时也是i18n的消息编号，例如FIELD_REQUIRED
+	Code string
+	// 错误详情
+	Detail *i18n.Error
+}
+
+// 创建应用错误
+func New(kind Kind, status int, code string, detail *i18n.Error, cause error) *Error {
+	return &Error{
+		Kind:   kind,
+		Status: status,
+		Code:   code,
+		Detail: detail,
+		Cause:  cause,
+	}
+}
+
+// 实现error接口
+func (e *Error) Error() string {
+	msg := e.Code
+	if e.Detail != nil {
+		msg = fmt.Sprintf("%s: %s", e.Code, e.Detail.Error())
+	}
+	if e.Cause != nil {
+		msg = fmt.Sprintf("%s: %s", msg, e.Cause.Error())
+	}
+	return msg
+}
+
+// 支持errors.Is和errors.As获取内部错误
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+// 支持errors.Is按错误码比较，错误码相同的两个应用错误视为相同
+func (e *Error) Is(target error) bool {
+	var t *Error
+	if errors.As(target, &t) {
+		return t.Code == e.Code
+	}
+	return false
+}
+
+// 将任意错误转换为应用错误，未知错误视为服务器内部错误
+func From(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return Internal(i18n.InternalError, err)
+}
+
+// 客户端传递的json非法
+func InvalidJSON(err error) *Error {
+	return New(KindRequest, http.StatusBadRequest, i18n.InvalidJSON, i18n.NewError(i18n.Raw, err.Error()), err)
+}
+
+// 请求体（例如CSV）格式非法
+func InvalidBody(err error) *Error {
+	return New(KindRequest, http.StatusBadRequest, i18n.InvalidBody, i18n.NewError(i18n.Raw, err.Error()), err)
+}
+
+// item_id非法
+func InvalidItemID(err error) *Error {
+	return New(KindRequest, http.StatusBadRequest, i18n.InvalidItemID, i18n.NewError(i18n.Raw, err.Error()), err)
+}
+
+// 请求的json格式正确，但字段类型或取值不符合要求
+func ValidationFailed(detail *i18n.Error, cause error) *Error {
+	return New(KindRequest, http.StatusUnprocessableEntity, i18n.ValidationFailed, detail, cause)
+}
+
+// 字段校验失败，返回每个字段的错误详情
+func InvalidFields(fields []FieldError) *Error {
+	appErr := ValidationFailed(i18n.NewError(i18n.ValidationFailedDetail, len(fields)), nil)
+	appErr.Fields = fields
+	return appErr
+}
+
+// 商品不存在
+func ItemNotFound(itemID int64) *Error {
+	return New(KindRequest, http.StatusNotFound, i18n.ItemNotFound, i18n.NewError(i18n.ItemNotExist, itemID), nil)
+}
+
+// 商品名称已存在，返回已存在商品的item_id
+func DuplicateName(name string, existingID int64) *Error {
+	appErr := New(KindRequest, http.StatusConflict, i18n.ItemNameConflict, i18n.NewError(i18n.ItemNameExist, name, existingID), nil)
+	appErr.Meta = map[string]interface{}{"item_id": existingID}
+	return appErr
+}
+
+// 获取分布式锁超时，说明有其他请求正在修改同一资源，客户端稍后重试即可
+func LockTimeout(retryAfter int) *Error {
+	appErr := New(KindRequest, http.StatusConflict, i18n.LockTimeout, i18n.NewError(i18n.RetryLater), nil)
+	appErr.RetryAfter = retryAfter
+	return appErr
+}
+
+// 获取分布式锁出错（Redis不可用），服务暂时无法处理写请求
+func LockUnavailable(retryAfter int, cause error) *Error {
+	appErr := New(KindServer, http.StatusServiceUnavailable, i18n.LockError, i18n.NewError(i18n.RetryLater), cause)
+	appErr.RetryAfter = retryAfter
+	return appErr
+}
+
+// 服务器内部错误，code区分出错的环节（例如INSERT_FAILED），内部错误不返回给客户端
+func Internal(code string, cause error) *Error {
+	return New(KindServer, http.StatusInternalServerError, code, i18n.NewError(i18n.InternalErrorDetail), cause)
+}
+
+// 客户端请求错误
+func Request(status int, code string, detail *i18n.Error) *Error {
+	return New(KindRequest, status, code, detail, nil)
+}
+
+// Idempotency-Key格式非法
+func InvalidIdempotencyKey(maxLength int) *Error {
+	return Request(http.StatusBadRequest, i18n.InvalidIdempotencyKey, i18n.NewError(i18n.InvalidIdempotencyKeyDetail, maxLength))
+}
+
+// 相同的Idempotency-Key用于了不同的请求内容
+func IdempotencyKeyReused() *Error {
+	return Request(http.StatusUnprocessableEntity, i18n.IdempotencyKeyReused, i18n.NewError(i18n.IdempotencyKeyReusedDetail))
+}
+
+// 相同Idempotency-Key的请求正在处理中
+func IdempotencyInProgress(retryAfter int) *Error {
+	appErr := Request(http.StatusConflict, i18n.IdempotencyInProgress, i18n.NewError(i18n.IdempotencyInProgressDetail))
+	appErr.RetryAfter = retryAfter
+	return appErr
+}
+
+// 商品不存在指定的版本（变更历史）
+func VersionNotFound(itemID, auditID int64) *Error {
+	return New(KindRequest, http.StatusNotFound, i18n.VersionNotFound, i18n.NewError(i18n.VersionNotExist, itemID, auditID), nil)
+}
+
+// 可用库存不足，无法预留
+func InsufficientStock(itemID, available, quantity int64) *Error {
+	appErr := Request(http.StatusConflict, i18n.InsufficientStock, i18n.NewError(i18n.InsufficientStockDetail, itemID, available, quantity))
+	appErr.Meta = map[string]interface{}{"available": available}
+	return appErr
+}
+
+// 预留不存在
+func ReservationNotFound(reservationID string) *Error {
+	return Request(http.StatusNotFound, i18n.ReservationNotFound, i18n.NewError(i18n.ReservationNotExist, reservationID))
+}
+
+// 预留已经被确认或释放，不能再修改
+func ReservationConflict(reservationID, status string) *Error {
+	appErr := Request(http.StatusConflict, i18n.ReservationConflict, i18n.NewError(i18n.ReservationConflictDetail, reservationID, status))
+	appErr.Meta = map[string]interface{}{"status": status}
+	return appErr
+}
+
+// 预留已过期，库存已被释放
+func ReservationExpired(reservationID string) *Error {
+	return Request(http.StatusGone, i18n.ReservationExpired, i18n.NewError(i18n.ReservationExpiredDetail, reservationID))
+}
+
+// 分类不存在
+func CategoryNotFound(categoryID int64) *Error {
+	return Request(http.StatusNotFound, i18n.CategoryNotFound, i18n.NewError(i18n.CategoryNotExist, categoryID))
+}
+
+// 分类下还有子分类，不能删除
+func CategoryNotEmpty(categoryID int64) *Error {
+	return Request(http.StatusConflict, i18n.CategoryNotEmpty, i18n.NewError(i18n.CategoryNotEmptyDetail, categoryID))
+}
+
+// 商品不存在指定的媒体文件
+func MediaNotFound(itemID int64, mediaID string) *Error {
+	return Request(http.StatusNotFound, i18n.MediaNotFound, i18n.NewError(i18n.MediaNotExist, itemID, mediaID))
+}
+
+// 上传的文件超过大小限制
+func MediaTooLarge(maxBytes int64) *Error {
+	return Request(http.StatusRequestEntityTooLarge, i18n.MediaTooLarge, i18n.NewError(i18n.MediaTooLargeDetail, maxBytes))
+}
+
+// 上传的文件无法解析
+func InvalidMedia(cause error) *Error {
+	return New(KindRequest, http.StatusUnprocessableEntity, i18n.InvalidMedia, i18n.NewError(i18n.InvalidMediaDetail, cause.Error()), cause)
+}
+
+// 商品的媒体文件数量已达到上限
+func MediaLimitExceeded(maxPerItem int) *Error {
+	return Request(http.StatusConflict, i18n.MediaLimitExceeded, i18n.NewError(i18n.MediaLimitExceededDetail, maxPerItem))
+}
+
+// 商品在站点不存在指定的定时价格
+func PriceScheduleNotFound(itemID int64, site string, scheduleID int64) *Error {
+	return Request(http.StatusNotFound, i18n.PriceScheduleNotFound, i18n.NewError(i18n.PriceScheduleNotExist, itemID, site, scheduleID))
+}
+
+// 定时价格的时间段和已有的定时价格重叠
+func PriceScheduleOverlap(itemID int64, site string) *Error {
+	return Request(http.StatusConflict, i18n.PriceScheduleOverlap, i18n.NewError(i18n.PriceScheduleOverlapDetail, itemID, site))
+}
+
+// 定时价格已经结束或取消
+func PriceScheduleFinished(scheduleID int64, status string) *Error {
+	appErr := Request(http.StatusConflict, i18n.PriceScheduleFinished, i18n.NewError(i18n.PriceScheduleFinishedDetail, scheduleID, status))
+	appErr.Meta = map[string]interface{}{"status": status}
+	return appErr
+}
+
+// 商品的状态不允许从from转换为to，allowed为from允许转换到的状态
+func InvalidStatusTransition(itemID int64, site, from, to string, allowed []string) *Error {
+	appErr := Request(http.StatusConflict, i18n.InvalidStatusTransition, i18n.NewError(i18n.InvalidStatusTransitionDetail, itemID, site, from, to))
+	appErr.Meta = map[string]interface{}{"status": from, "allowed": allowed}
+	return appErr
+}
+
+// 商品不存在指定的SKU
+func SKUNotFound(itemID, skuID int64) *Error {
+	return Request(http.StatusNotFound, i18n.SKUNotFound, i18n.NewError(i18n.SKUNotExist, itemID, skuID))
+}
+
+// SKU编码已被其他SKU使用
+func SKUCodeConflict(code string) *Error {
+	return Request(http.StatusConflict, i18n.SKUCodeConflict, i18n.NewError(i18n.SKUCodeExist, code))
+}
+
+// 商品的SKU数量已达到上限
+func SKULimitExceeded(maxSKUs int) *Error {
+	return Request(http.StatusConflict, i18n.SKULimitExceeded, i18n.NewError(i18n.SKULimitExceededDetail, maxSKUs))
+}
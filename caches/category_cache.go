@@ -0,0 +1,55 @@
+package caches
+
+import (
+	"context"
+	"miHttpServer/database"
+	"miHttpServer/metrics"
+	"miHttpServer/models"
+	"miHttpServer/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// 分类的本地缓存
+var CategoryLocalCache *LRUCache[models.CategoryCache]
+
+// 查询分类的本地缓存
+func QueryLocalCategory(categoryID int64) (models.CategoryCache, bool) {
+	categoryCache, ok := CategoryLocalCache.Get(categoryID)
+	metrics.CacheResult("category", "local", ok)
+	return categoryCache, ok
+}
+
+// 添加分类的本地缓存
+func AddLocalCategory(categoryCache models.CategoryCache) {
+	CategoryLocalCache.Put(categoryCache.CategoryID, categoryCache)
+}
+
+// 查询分类的redis缓存
+func QueryRedisCategory(ctx context.Context, categoryID int64) (models.CategoryCache, bool, error) {
+	ctx, span := tracing.Start(ctx, "cache.redis.get")
+	defer span.End()
+	var categoryCache models.CategoryCache
+	ok, err := database.QueryCategoryCache(ctx, categoryID, &categoryCache)
+	if err != nil {
+		metrics.CacheError("category", "redis")
+		tracing.SetError(span, err)
+	} else {
+		metrics.CacheResult("category", "redis", ok)
+		span.SetAttributes(attribute.Bool("cache.hit", ok))
+	}
+	return categoryCache, ok, err
+}
+
+// 添加分类的redis缓存
+func AddRedisCategory(ctx context.Context, category models.Category) error {
+	return database.AddCategoryCache(ctx, models.NewCategoryCache(category))
+}
+
+// 删除分类的本地缓存和redis缓存（分类被修改、移动或删除时调用）
+func DeleteCategoryCaches(ctx context.Context, categoryIDs []int64) error {
+	for _, categoryID := range categoryIDs {
+		CategoryLocalCache.Delete(categoryID)
+	}
+	return database.DeleteCategoryCaches(ctx, categoryIDs)
+}
@@ -1,47 +1,53 @@
 package caches
 
 import (
+	"context"
 	"miHttpServer/config"
+	"miHttpServer/metrics"
 	"miHttpServer/models"
+	"miHttpServer/tracing"
 	"sync"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
-type Node struct {
+type Node[V any] struct {
 	key   int64
-	value models.ItemCache
-	pre   *Node
-	next  *Node
+	value V
+	pre   *Node[V]
+	next  *Node[V]
 	// 过期时间
 	expireAt time.Time
 }
 
-type LRUCache struct {
+// 本地LRU缓存，V为缓存的数据类型（商品、分类等）
+type LRUCache[V any] struct {
 	// 缓存的最大容量
 	capacity int
 	// 用于快速查找节点
-	cache map[int64]*Node
+	cache map[int64]*Node[V]
 	// 指向双向链表的头节点
-	head *Node
+	head *Node[V]
 	// 指向双向链表的尾节点
-	end *Node
+	end *Node[V]
 	// 互斥锁
 	mutex sync.Mutex
 }
 
 // 本地缓存
-var LocalCache *LRUCache
+var LocalCache *LRUCache[models.ItemCache]
 
 // 构造函数，初始化LRUCache
-func NewLRUCache(capacity int) *LRUCache {
-	return &LRUCache{
-		capacity: config.Configs.LocalCache.Capacity,
-		cache:    make(map[int64]*Node, capacity),
+func NewLRUCache[V any](capacity int) *LRUCache[V] {
+	return &LRUCache[V]{
+		capacity: capacity,
+		cache:    make(map[int64]*Node[V], capacity),
 	}
 }
 
 // 获取数据，返回额外的布尔值表示是否找到
-func (lruCache *LRUCache) Get(key int64) (models.ItemCache, bool) {
+func (lruCache *LRUCache[V]) Get(key int64) (V, bool) {
 	lruCache.mutex.Lock()
 	defer lruCache.mutex.Unlock()
 
@@ -51,17 +57,19 @@ func (lruCache *LRUCache) Get(key int64) (models.ItemCache, bool) {
 			// 如果过期了，则删除节点
 			removeKey := lruCache.removeNode(node)
 			delete(lruCache.cache, removeKey)
-			return models.ItemCache{}, false
+			var zero V
+			return zero, false
 		}
 		// 如果没有过期，则将节点移动到链表尾部
 		lruCache.moveNodeToEnd(node)
 		return node.value, true
 	}
-	return models.ItemCache{}, false
+	var zero V
+	return zero, false
 }
 
 // 添加数据
-func (lruCache *LRUCache) Put(key int64, value models.ItemCache) {
+func (lruCache *LRUCache[V]) Put(key int64, value V) {
 	lruCache.mutex.Lock()
 	defer lruCache.mutex.Unlock()
 	duration := time.Duration(config.Configs.LocalCache.ExpireSec) * time.Second
@@ -79,7 +87,7 @@ func (lruCache *LRUCache) Put(key int64, value models.ItemCache) {
 			delete(lruCache.cache, removeKey)
 		}
 		// 添加新节点到链表尾部
-		node := &Node{
+		node := &Node[V]{
 			key:      key,
 			value:    value,
 			expireAt: expireAt,
@@ -90,7 +98,7 @@ func (lruCache *LRUCache) Put(key int64, value models.ItemCache) {
 }
 
 // 移动节点到双向链表尾部
-func (lruCache *LRUCache) moveNodeToEnd(node *Node) {
+func (lruCache *LRUCache[V]) moveNodeToEnd(node *Node[V]) {
 	if node != lruCache.end {
 		lruCache.removeNode(node)
 		lruCache.addNode(node)
@@ -98,7 +106,7 @@ func (lruCache *LRUCache) moveNodeToEnd(node *Node) {
 }
 
 // 移除节点
-func (lruCache *LRUCache) removeNode(node *Node) int64 {
+func (lruCache *LRUCache[V]) removeNode(node *Node[V]) int64 {
 	if node == lruCache.end {
 		// 如果移除的是尾节点，则更新end指针
 		lruCache.end = node.pre
@@ -125,7 +133,7 @@ func (lruCache *LRUCache) removeNode(node *Node) int64 {
 }
 
 // 添加节点
-func (lruCache *LRUCache) addNode(node *Node) {
+func (lruCache *LRUCache[V]) addNode(node *Node[V]) {
 	if node == nil {
 		return
 	}
@@ -144,18 +152,24 @@ func (lruCache *LRUCache) addNode(node *Node) {
 	}
 }
 
-// 查询本地缓存
-func QueryLocalCache(key int64) (bool, map[string]interface{}) {
-	if value, ok := LocalCache.Get(key); ok {
-		storeInfo := make(map[string]interface{})
-		storeInfo["store_info"] = map[string]interface{}{
-			"item_id": value.ItemID,
-			"name":    value.Name,
-			"price":   value.Price,
-		}
-		return true, storeInfo
+// 删除数据
+func (lruCache *LRUCache[V]) Delete(key int64) {
+	lruCache.mutex.Lock()
+	defer lruCache.mutex.Unlock()
+	if node, ok := lruCache.cache[key]; ok {
+		lruCache.removeNode(node)
+		delete(lruCache.cache, key)
 	}
-	return false, nil
+}
+
+// 查询本地缓存
+func QueryLocalCache(ctx context.Context, key int64) (bool, models.ItemCache) {
+	_, span := tracing.Start(ctx, "cache.local.get")
+	defer span.End()
+	value, ok := LocalCache.Get(key)
+	metrics.CacheResult("item", "local", ok)
+	span.SetAttributes(attribute.Bool("cache.hit", ok))
+	return ok, value
 }
 
 // 添加本地缓存
@@ -174,10 +188,27 @@ func UpdateLocalCache(key int64, value models.ItemCache) {
 
 // 删除本地缓存
 func DeleteLocalCache(key int64) {
-	LocalCache.mutex.Lock()
-	defer LocalCache.mutex.Unlock()
-	if node, ok := LocalCache.cache[key]; ok {
-		LocalCache.removeNode(node)
-		delete(LocalCache.cache, key)
+	LocalCache.Delete(key)
+}
+
+// 批量查询本地缓存，返回命中的数据和未命中的item_id
+func QueryLocalCaches(ctx context.Context, keys []int64) (map[int64]models.ItemCache, []int64) {
+	_, span := tracing.Start(ctx, "cache.local.get")
+	defer span.End()
+	found := make(map[int64]models.ItemCache, len(keys))
+	var missing []int64
+	for _, key := range keys {
+		value, ok := LocalCache.Get(key)
+		metrics.CacheResult("item", "local", ok)
+		if ok {
+			found[key] = value
+		} else {
+			missing = append(missing, key)
+		}
 	}
+	span.SetAttributes(
+		attribute.Int("cache.keys", len(keys)),
+		attribute.Int("cache.hits", len(found)),
+	)
+	return found, missing
 }
@@ -1,23 +1,26 @@
 package caches
 
 import (
+	"context"
 	"miHttpServer/database"
+	"miHttpServer/metrics"
 	"miHttpServer/models"
+	"miHttpServer/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // 更新reids缓存
-func UpdateRedisCache(item_id int64, item models.Item) error {
+func UpdateRedisCache(ctx context.Context, item_id int64, item models.Item) error {
 	// 查找redis缓存中是否有相同数据
 	itemCache := models.ItemCache{}
-	ok, err := database.QueryItemCache(item_id, &itemCache)
+	ok, err := database.QueryItemCache(ctx, item_id, &itemCache)
 	if err != nil {
 		return err
 	}
 	if ok {
 		// 如果缓存中有相同数据，更新缓存
-		itemCache.Name = item.Name
-		itemCache.Price = item.Price
-		err = database.AddItemCache(item.ItemID, itemCache)
+		err = database.AddItemCache(ctx, item.ItemID, models.NewItemCache(item))
 		if err != nil {
 			return err
 		}
@@ -25,49 +28,78 @@ func UpdateRedisCache(item_id int64, item models.Item) error {
 	return nil
 }
 
-// 查询redis缓存
-func QueryRedisCache(item_id int64) (error, map[string]interface{}) {
+// 查询redis缓存，缓存不存在时返回nil
+func QueryRedisCache(ctx context.Context, item_id int64) (error, *models.ItemCache) {
+	ctx, span := tracing.Start(ctx, "cache.redis.get")
+	defer span.End()
 	itemCache := models.ItemCache{}
-	ok, err := database.QueryItemCache(item_id, &itemCache)
+	ok, err := database.QueryItemCache(ctx, item_id, &itemCache)
 	if err != nil {
+		metrics.CacheError("item", "redis")
+		tracing.SetError(span, err)
 		return err, nil
 	}
+	metrics.CacheResult("item", "redis", ok)
+	span.SetAttributes(attribute.Bool("cache.hit", ok))
 	if ok {
-		storeInfo := make(map[string]interface{})
-		storeInfo["store_info"] = map[string]interface{}{
-			"item_id": itemCache.ItemID,
-			"name":    itemCache.Name,
-			"price":   itemCache.Price,
-		}
-		return nil, storeInfo
+		return nil, &itemCache
 	}
 	return nil, nil
 }
 
 // 新增redis缓存
-func AddRedisCache(item_id int64, item models.Item) error {
-	var itemCache models.ItemCache
+func AddRedisCache(ctx context.Context, item_id int64, item models.Item) error {
 	// 将数据存入Redis缓存
-	itemCache.ItemID = item.ItemID
-	itemCache.Name = item.Name
-	itemCache.Price = item.Price
-	err := database.AddItemCache(item.ItemID, itemCache)
+	err := database.AddItemCache(ctx, item.ItemID, models.NewItemCache(item))
 	return err
 }
 
 // 删除redis缓存
-func DeleteItemCache(item_id int64) error {
+func DeleteItemCache(ctx context.Context, item_id int64) error {
 	itemCache := models.ItemCache{}
-	ok, err := database.QueryItemCache(item_id, &itemCache)
+	ok, err := database.QueryItemCache(ctx, item_id, &itemCache)
 	if err != nil {
 		return err
 	}
 	if ok {
 		// 如果Redis缓存中有相同数据，删除缓存
-		err = database.DeleteItemCache(item_id)
+		err = database.DeleteItemCache(ctx, item_id)
 		if err != nil {
 			return err
 		}
 	}
 	return nil
 }
+
+// 批量删除redis缓存
+func DeleteRedisCaches(ctx context.Context, itemIDs []int64) error {
+	return database.DeleteItemCaches(ctx, itemIDs)
+}
+
+// 批量查询redis缓存
+func QueryRedisCaches(ctx context.Context, itemIDs []int64) (map[int64]models.ItemCache, error) {
+	ctx, span := tracing.Start(ctx, "cache.redis.get")
+	defer span.End()
+	found, err := database.QueryItemCaches(ctx, itemIDs)
+	if err != nil {
+		metrics.CacheRequests.Add(float64(len(itemIDs)), "item", "redis", "error")
+		tracing.SetError(span, err)
+		return nil, err
+	}
+	metrics.CacheRequests.Add(float64(len(found)), "item", "redis", "hit")
+	metrics.CacheRequests.Add(float64(len(itemIDs)-len(found)), "item", "redis", "miss")
+	span.SetAttributes(
+		attribute.Int("cache.keys", len(itemIDs)),
+		attribute.Int("cache.hits", len(found)),
+	)
+	return found, nil
+}
+
+// 批量新增redis缓存
+func AddRedisCaches(ctx context.Context, items []models.Item) error {
+	itemCaches := make([]models.ItemCache, 0, len(items))
+	for _, item := range items {
+		itemCaches = append(itemCaches, models.NewItemCache(item))
+	}
+	return database.AddItemCaches(ctx, itemCaches)
+}
@@ -4,12 +4,27 @@ package config
 var Configs Config
 
 type Config struct {
-	Redis      RedisConfig      `yaml:"redis"`
-	MySQL      MysqlConfig      `yaml:"mysql"`
-	Server     ServerConfig     `yaml:"server"`
-	Code       CodeConfig       `yaml:"code"`
-	Lock       LockConfig       `yaml:"lock"`
-	LocalCache LocalCacheConfig `yaml:"localCache"`
+	Redis       RedisConfig       `yaml:"redis"`
+	MySQL       MysqlConfig       `yaml:"mysql"`
+	Server      ServerConfig      `yaml:"server"`
+	Code        CodeConfig        `yaml:"code"`
+	Lock        LockConfig        `yaml:"lock"`
+	LocalCache  LocalCacheConfig  `yaml:"localCache"`
+	I18n        I18nConfig        `yaml:"i18n"`
+	Response    ResponseConfig    `yaml:"response"`
+	Validation  ValidationConfig  `yaml:"validation"`
+	Batch       BatchConfig       `yaml:"batch"`
+	Transfer    TransferConfig    `yaml:"transfer"`
+	Item        ItemConfig        `yaml:"item"`
+	Idempotency IdempotencyConfig `yaml:"idempotency"`
+	Audit       AuditConfig       `yaml:"audit"`
+	Stock       StockConfig       `yaml:"stock"`
+	Category    CategoryConfig    `yaml:"category"`
+	Search      SearchConfig      `yaml:"search"`
+	Media       MediaConfig       `yaml:"media"`
+	Price       PriceConfig       `yaml:"price"`
+	Admin       AdminConfig       `yaml:"admin"`
+	Tracing     TracingConfig     `yaml:"tracing"`
 }
 
 // redis的配置项
@@ -40,6 +55,12 @@ type MysqlConfig struct {
 // http服务的配置项
 type ServerConfig struct {
 	Port int `yaml:"port"`
+	// 收到关闭信号后，就绪检查失败到开始关闭服务之间等待的时间（秒）
+	ReadinessDelaySec int `yaml:"readinessDelaySec"`
+	// 等待正在处理的请求完成的最长时间（秒）
+	ShutdownTimeoutSec int `yaml:"shutdownTimeoutSec"`
+	// 就绪检查时每个依赖（MySQL、Redis）的超时时间（秒）
+	HealthCheckTimeoutSec int `yaml:"healthCheckTimeoutSec"`
 }
 
 // Code状态码的配置项
@@ -53,6 +74,8 @@ type CodeConfig struct {
 type LockConfig struct {
 	ExpireSec uint64 `yaml:"expireSec"`
 	WaitSec   int    `yaml:"waitSec"`
+	// 获取锁超时或失败时，建议客户端重试的等待时间（秒）
+	RetryAfterSec int `yaml:"retryAfterSec"`
 }
 
 // 本地缓存配置项
@@ -60,3 +83,155 @@ type LocalCacheConfig struct {
 	Capacity  int `yaml:"capacity"`
 	ExpireSec int `yaml:"expireSec"`
 }
+
+// 国际化配置项
+type I18nConfig struct {
+	// 默认语言
+	DefaultLanguage string `yaml:"defaultLanguage"`
+	// 各站点的默认语言（app_local -> 语言）
+	SiteLanguages map[string]string `yaml:"siteLanguages"`
+}
+
+// 响应格式配置项
+type ResponseConfig struct {
+	// 错误响应是否默认使用application/problem+json格式
+	// 为false时只有请求头Accept包含application/problem+json才使用
+	ProblemJSON bool `yaml:"problemJson"`
+	// problem details中type字段的前缀，后接小写的错误码
+	ProblemTypeBase string `yaml:"problemTypeBase"`
+}
+
+// 请求参数校验配置项
+type ValidationConfig struct {
+	Item ItemValidationConfig `yaml:"item"`
+}
+
+// 商品字段的校验规则
+type ItemValidationConfig struct {
+	// 商品名称的最小和最大长度（按字符计算）
+	NameMinLength int `yaml:"nameMinLength"`
+	NameMaxLength int `yaml:"nameMaxLength"`
+	// 商品价格的取值范围
+	PriceMin float64 `yaml:"priceMin"`
+	PriceMax float64 `yaml:"priceMax"`
+	// 商品价格最多允许的小数位数
+	PriceScale int `yaml:"priceScale"`
+}
+
+// 批量操作配置项
+type BatchConfig struct {
+	// 单次批量请求允许的最大操作数
+	MaxOperations int `yaml:"maxOperations"`
+	// 批量查询时单次允许的最大item_id数量
+	MaxQueryIDs int `yaml:"maxQueryIds"`
+}
+
+// 导入导出配置项
+type TransferConfig struct {
+	// 导出时每次从MySQL读取的行数，导入时每批写入的行数
+	ChunkSize int `yaml:"chunkSize"`
+	// 导入时最多返回的错误行数
+	MaxReportedErrors int `yaml:"maxReportedErrors"`
+}
+
+// 商品相关的配置项
+type ItemConfig struct {
+	// 商品名称是否按站点唯一，false表示所有站点全局唯一
+	UniqueNamePerSite bool `yaml:"uniqueNamePerSite"`
+	// 每个商品最多的自定义属性数量
+	MaxAttributes int `yaml:"maxAttributes"`
+	// 每个商品最多的标签数量
+	MaxTags int `yaml:"maxTags"`
+	// 每个商品最多的SKU数量
+	MaxSKUs int `yaml:"maxSkus"`
+}
+
+// 幂等键（Idempotency-Key）配置项
+type IdempotencyConfig struct {
+	// 保存响应的时间（秒），在此期间使用相同的幂等键重试会返回保存的响应
+	ExpireSec int `yaml:"expireSec"`
+	// 请求处理中的记录过期时间（秒），防止处理中断后幂等键一直不可用
+	InFlightExpireSec int `yaml:"inFlightExpireSec"`
+	// 幂等键的最大长度
+	MaxKeyLength int `yaml:"maxKeyLength"`
+}
+
+// 商品变更历史配置项
+type AuditConfig struct {
+	// 查询变更历史时默认的每页条数
+	DefaultPageSize int `yaml:"defaultPageSize"`
+	// 查询变更历史时允许的最大每页条数
+	MaxPageSize int `yaml:"maxPageSize"`
+}
+
+// 库存配置项
+type StockConfig struct {
+	// 预留库存默认的过期时间（秒）
+	ReservationTTLSec int `yaml:"reservationTtlSec"`
+	// 预留库存允许的最大过期时间（秒）
+	MaxReservationTTLSec int `yaml:"maxReservationTtlSec"`
+	// 检查并释放过期预留的间隔（秒）
+	SweepIntervalSec int `yaml:"sweepIntervalSec"`
+}
+
+// 商品分类配置项
+type CategoryConfig struct {
+	// 一个商品最多可以属于的分类数量
+	MaxPerItem int `yaml:"maxPerItem"`
+	// 查询分类下的商品时默认的每页条数
+	DefaultPageSize int `yaml:"defaultPageSize"`
+	// 查询分类下的商品时允许的最大每页条数
+	MaxPageSize int `yaml:"maxPageSize"`
+}
+
+// 商品搜索配置项
+type SearchConfig struct {
+	// 搜索结果默认的每页条数
+	DefaultPageSize int `yaml:"defaultPageSize"`
+	// 搜索结果允许的最大每页条数
+	MaxPageSize int `yaml:"maxPageSize"`
+	// 查询语句的最大长度（字符数）
+	MaxQueryLength int `yaml:"maxQueryLength"`
+	// 高亮命中词的开始和结束标签
+	HighlightPre  string `yaml:"highlightPre"`
+	HighlightPost string `yaml:"highlightPost"`
+}
+
+// 商品媒体（图片等附件）配置项
+type MediaConfig struct {
+	// 本地存储媒体文件的目录
+	LocalDir string `yaml:"localDir"`
+	// 媒体文件访问地址的前缀，本地存储时由服务提供下载
+	URLPrefix string `yaml:"urlPrefix"`
+	// 单个文件的最大字节数
+	MaxUploadBytes int64 `yaml:"maxUploadBytes"`
+	// 允许上传的文件类型（按文件内容检测）
+	AllowedTypes []string `yaml:"allowedTypes"`
+	// 缩略图的最大宽高（像素）
+	ThumbnailSize int `yaml:"thumbnailSize"`
+	// 每个商品最多的媒体文件数量
+	MaxPerItem int `yaml:"maxPerItem"`
+}
+
+// 定时价格配置项
+type PriceConfig struct {
+	// 检查定时价格开始和结束的间隔（秒）
+	SchedulerIntervalSec int `yaml:"schedulerIntervalSec"`
+}
+
+// 管理员配置项
+type AdminConfig struct {
+	// 管理员令牌，请求头X-Admin-Token为其中之一时视为管理员
+	// 管理员可以修改商品的发布状态，并且可以看到未发布的商品
+	Tokens []string `yaml:"tokens"`
+}
+
+// 分布式追踪配置项
+type TracingConfig struct {
+	// 导出方式：none（不记录）、stdout（标准输出）、file（写入文件）
+	Exporter string `yaml:"exporter"`
+	// exporter为file时写入的文件，每行一条OTLP/JSON格式的数据
+	File string `yaml:"file"`
+	// 服务名称（service.name）
+	ServiceName string `yaml:"serviceName"`
+}
@@ -0,0 +1,221 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"miHttpServer/config"
+	"miHttpServer/models"
+	"strconv"
+	"strings"
+
+	"xorm.io/xorm"
+)
+
+// 部分更新合并后的属性数量超过上限
+var ErrTooManyAttributes = errors.New("too many attributes")
+
+// 将属性值转换为保存到MySQL的类型和字符串
+func encodeAttribute(value interface{}) (string, string) {
+	switch v := value.(type) {
+	case bool:
+		return models.AttributeBool, strconv.FormatBool(v)
+	case float64:
+		return models.AttributeNumber, FormatAttributeNumber(v)
+	default:
+		return models.AttributeString, fmt.Sprint(v)
+	}
+}
+
+// 将MySQL中保存的属性值还原为原始类型
+func decodeAttribute(attribute models.ItemAttribute) interface{} {
+	switch attribute.ValueType {
+	case models.AttributeBool:
+		v, _ := strconv.ParseBool(attribute.Value)
+		return v
+	case models.AttributeNumber:
+		v, _ := strconv.ParseFloat(attribute.Value, 64)
+		return v
+	default:
+		return attribute.Value
+	}
+}
+
+// 数字类型的属性值使用最短的十进制表示保存，保证筛选时1.50和1.5相等
+func FormatAttributeNumber(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// 在事务中保存商品的属性和标签（全量替换），为nil时不修改
+func setItemExtrasIn(db xorm.Interface, item_id int64, attributes map[string]interface{}, tags []string) error {
+	if attributes != nil {
+		if _, err := db.Where("item_id = ?", item_id).Delete(&models.ItemAttribute{}); err != nil {
+			return err
+		}
+		if len(attributes) > 0 {
+			rows := make([]models.ItemAttribute, 0, len(attributes))
+			for key, value := range attributes {
+				valueType, str := encodeAttribute(value)
+				rows = append(rows, models.ItemAttribute{ItemID: item_id, Key: key, ValueType: valueType, Value: str})
+			}
+			if _, err := db.Insert(&rows); err != nil {
+				return err
+			}
+		}
+	}
+	if tags != nil {
+		if _, err := db.Where("item_id = ?", item_id).Delete(&models.ItemTag{}); err != nil {
+			return err
+		}
+		if len(tags) > 0 {
+			rows := make([]models.ItemTag, 0, len(tags))
+			for _, tag := range tags {
+				rows = append(rows, models.ItemTag{ItemID: item_id, Tag: tag})
+			}
+			if _, err := db.Insert(&rows); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// 将部分更新的属性合并到已有属性，返回合并后的属性；不修改属性时返回nil
+func mergeAttributes(current map[string]interface{}, patch models.ItemPatch) (map[string]interface{}, error) {
+	if patch.Attributes == nil && !patch.ClearAttributes {
+		return nil, nil
+	}
+	merged := make(map[string]interface{}, len(current)+len(patch.Attributes))
+	if !patch.ClearAttributes {
+		for key, value := range current {
+			merged[key] = value
+		}
+	}
+	for key, value := range patch.Attributes {
+		if value == nil {
+			delete(merged, key)
+		} else {
+			merged[key] = value
+		}
+	}
+	if maxAttributes := config.Configs.Item.MaxAttributes; maxAttributes > 0 && len(merged) > maxAttributes {
+		return nil, ErrTooManyAttributes
+	}
+	return merged, nil
+}
+
+// 删除商品时删除商品的属性和标签
+func deleteItemExtrasIn(db xorm.Interface, item_id int64) error {
+	if _, err := db.Where("item_id = ?", item_id).Delete(&models.ItemAttribute{}); err != nil {
+		return err
+	}
+	_, err := db.Where("item_id = ?", item_id).Delete(&models.ItemTag{})
+	return err
+}
+
+// 查询商品的属性、标签、媒体文件、生效中的促销价、发布状态和SKU，填充到items中
+func LoadItemExtras(db xorm.Interface, items []models.Item) error {
+	if len(items) == 0 {
+		return nil
+	}
+	itemIDs := make([]int64, 0, len(items))
+	index := make(map[int64]int, len(items))
+	for i, item := range items {
+		itemIDs = append(itemIDs, item.ItemID)
+		index[item.ItemID] = i
+	}
+	var attributes []models.ItemAttribute
+	if err := db.In("item_id", itemIDs).Find(&attributes); err != nil {
+		return err
+	}
+	for _, attribute := range attributes {
+		item := &items[index[attribute.ItemID]]
+		if item.Attributes == nil {
+			item.Attributes = make(map[string]interface{})
+		}
+		item.Attributes[attribute.Key] = decodeAttribute(attribute)
+	}
+	var tags []models.ItemTag
+	if err := db.In("item_id", itemIDs).OrderBy("tag").Find(&tags); err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		item := &items[index[tag.ItemID]]
+		item.Tags = append(item.Tags, tag.Tag)
+	}
+	if err := loadItemMediaIn(db, itemIDs, index, items); err != nil {
+		return err
+	}
+	if err := loadSalePricesIn(db, itemIDs, index, items); err != nil {
+		return err
+	}
+	if err := loadItemStatusesIn(db, itemIDs, index, items); err != nil {
+		return err
+	}
+	return loadItemSKUsIn(db, itemIDs, index, items)
+}
+
+// 查询单个商品的属性、标签、媒体文件、生效中的促销价、发布状态和SKU
+func loadItemExtrasOf(db xorm.Interface, item *models.Item) error {
+	items := []models.Item{{ItemID: item.ItemID}}
+	if err := LoadItemExtras(db, items); err != nil {
+		return err
+	}
+	item.Attributes = items[0].Attributes
+	item.Tags = items[0].Tags
+	item.Media = items[0].Media
+	item.SalePrices = items[0].SalePrices
+	item.Statuses = items[0].Statuses
+	item.SKUs = items[0].SKUs
+	return nil
+}
+
+// 按属性、标签和发布状态筛选商品，分页返回item_id（按item_id升序）和总数
+// 属性值按字符串比较，数字属性同时按规范化后的数字比较
+func ListItemIDs(ctx context.Context, filter models.ItemFilter, offset, limit int) ([]int64, int64, error) {
+	itemTable := Engine.TableName(new(models.Item))
+	attributeTable := Engine.TableName(new(models.ItemAttribute))
+	tagTable := Engine.TableName(new(models.ItemTag))
+
+	var conditions []string
+	var args []interface{}
+	for key, value := range filter.Attributes {
+		condition := fmt.Sprintf("`item_id` IN (SELECT `item_id` FROM `%s` WHERE `attr_key` = ? AND (`attr_value` = ?", attributeTable)
+		args = append(args, key, value)
+		if number, err := strconv.ParseFloat(value, 64); err == nil {
+			condition += " OR (`value_type` = ? AND `attr_value` = ?)"
+			args = append(args, models.AttributeNumber, FormatAttributeNumber(number))
+		}
+		conditions = append(conditions, condition+"))")
+	}
+	if len(filter.Tags) > 0 {
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(filter.Tags)), ", ")
+		conditions = append(conditions, fmt.Sprintf(
+			"`item_id` IN (SELECT `item_id` FROM `%s` WHERE `tag` IN (%s) GROUP BY `item_id` HAVING COUNT(*) = ?)",
+			tagTable, placeholders,
+		))
+		for _, tag := range filter.Tags {
+			args = append(args, tag)
+		}
+		args = append(args, len(filter.Tags))
+	}
+	if filter.PublishedSite != "" {
+		condition, conditionArgs := publishedCondition("`item_id`", filter.PublishedSite)
+		conditions = append(conditions, condition)
+		args = append(args, conditionArgs...)
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int64
+	countSQL := fmt.Sprintf("SELECT COUNT(*) FROM `%s`%s", itemTable, where)
+	if _, err := Engine.Context(ctx).SQL(countSQL, args...).Get(&total); err != nil {
+		return nil, 0, err
+	}
+	var itemIDs []int64
+	querySQL := fmt.Sprintf("SELECT `item_id` FROM `%s`%s ORDER BY `item_id` LIMIT ? OFFSET ?", itemTable, where)
+	err := Engine.Context(ctx).SQL(querySQL, append(args, limit, offset)...).Find(&itemIDs)
+	return itemIDs, total, err
+}
@@ -0,0 +1,62 @@
+package database
+
+import (
+	"errors"
+	"miHttpServer/config"
+	"miHttpServer/models"
+	"reflect"
+	"testing"
+)
+
+func TestMergeAttributes(t *testing.T) {
+	maxAttributes := config.Configs.Item.MaxAttributes
+	config.Configs.Item.MaxAttributes = 2
+	defer func() { config.Configs.Item.MaxAttributes = maxAttributes }()
+
+	current := map[string]interface{}{"color": "red", "size": "L"}
+	tests := []struct {
+		name    string
+		patch   models.ItemPatch
+		want    map[string]interface{}
+		wantErr error
+	}{
+		{
+			name:  "不修改属性",
+			patch: models.ItemPatch{},
+		},
+		{
+			name:  "修改和删除属性",
+			patch: models.ItemPatch{Attributes: map[string]interface{}{"color": "blue", "size": nil}},
+			want:  map[string]interface{}{"color": "blue"},
+		},
+		{
+			name:  "清空后设置属性",
+			patch: models.ItemPatch{ClearAttributes: true, Attributes: map[string]interface{}{"weight": 1.5}},
+			want:  map[string]interface{}{"weight": 1.5},
+		},
+		{
+			name:  "清空属性",
+			patch: models.ItemPatch{ClearAttributes: true},
+			want:  map[string]interface{}{},
+		},
+		{
+			name:    "合并后超过上限",
+			patch:   models.ItemPatch{Attributes: map[string]interface{}{"weight": 1.5}},
+			wantErr: ErrTooManyAttributes,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mergeAttributes(current, tt.patch)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("错误为%v，应为%v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("合并后的属性为%v，应为%v", got, tt.want)
+			}
+		})
+	}
+	if len(current) != 2 || current["color"] != "red" {
+		t.Errorf("合并不应修改原有的属性: %v", current)
+	}
+}
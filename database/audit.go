@@ -0,0 +1,78 @@
+package database
+
+import (
+	"context"
+	"miHttpServer/logger"
+	"miHttpServer/models"
+	"time"
+
+	"xorm.io/xorm"
+)
+
+// 写入一条商品变更历史，db应与变更使用同一个事务
+func insertAudit(ctx context.Context, db xorm.Interface, info models.AuditInfo, operation string, itemID int64, before, after *models.ItemSnapshot) error {
+	audit := models.ItemAudit{
+		ItemID:    itemID,
+		Operation: operation,
+		Actor:     info.Actor,
+		Site:      info.Site,
+		RequestID: info.RequestID,
+		Before:    before,
+		After:     after,
+	}
+	_, err := db.Insert(&audit)
+	if err != nil {
+		logger.Println(ctx, "写入变更历史失败:", err)
+	}
+	return err
+}
+
+// 分页查询商品的变更历史（按时间倒序），同时返回总条数
+func QueryItemHistory(ctx context.Context, item_id int64, offset, limit int) ([]models.ItemAudit, int64, error) {
+	var audits []models.ItemAudit
+	total, err := Engine.Context(ctx).Where("item_id = ?", item_id).
+		Desc("audit_id").
+		Limit(limit, offset).
+		FindAndCount(&audits)
+	return audits, total, err
+}
+
+// 查询商品在某一时刻的状态，商品在该时刻不存在时返回nil
+// 优先使用该时刻之前最后一条变更的after快照；该时刻之后才有变更时使用第一条变更的before快照；
+// 没有任何变更历史时（记录变更历史之前创建的商品）使用当前数据
+func QueryItemAsOf(ctx context.Context, item_id int64, asOf time.Time) (*models.ItemSnapshot, error) {
+	at := formatDBTime(asOf)
+	var audit models.ItemAudit
+	exist, err := Engine.Context(ctx).Where("item_id = ? AND created_at <= ?", item_id, at).Desc("audit_id").Get(&audit)
+	if err != nil {
+		return nil, err
+	}
+	if exist {
+		return audit.After, nil
+	}
+	exist, err = Engine.Context(ctx).Where("item_id = ? AND created_at > ?", item_id, at).Asc("audit_id").Get(&audit)
+	if err != nil {
+		return nil, err
+	}
+	if exist {
+		return audit.Before, nil
+	}
+	var item models.Item
+	exist, err = Engine.Context(ctx).Where("item_id = ? AND created_at <= ?", item_id, at).Get(&item)
+	if err != nil || !exist {
+		return nil, err
+	}
+	return models.NewItemSnapshot(item), nil
+}
+
+// 根据audit_id查询商品的某一条变更历史
+func QueryItemAudit(ctx context.Context, item_id, audit_id int64) (models.ItemAudit, bool, error) {
+	var audit models.ItemAudit
+	exist, err := Engine.Context(ctx).Where("item_id = ? AND audit_id = ?", item_id, audit_id).Get(&audit)
+	return audit, exist, err
+}
+
+// 时间按数据库时区保存，查询时转换为相同时区的字符串再比较
+func formatDBTime(t time.Time) string {
+	return t.In(Engine.DatabaseTZ).Format("2006-01-02 15:04:05")
+}
@@ -0,0 +1,300 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"miHttpServer/logger"
+	"miHttpServer/models"
+	"strconv"
+	"strings"
+
+	"xorm.io/xorm"
+)
+
+// 分类操作的错误，由调用方转换为对应的响应
+var (
+	// 父分类不存在
+	ErrParentCategoryNotFound = errors.New("parent category not found")
+	// 分类不能移动到自身或自己的子孙分类下
+	ErrCategoryCycle = errors.New("category cannot be moved under itself")
+	// 分类下还有子分类
+	ErrCategoryNotEmpty = errors.New("category has children")
+	// 分类不存在
+	ErrCategoryNotFound = errors.New("category not found")
+	// 分类层级过深，路径超过最大长度
+	ErrCategoryTooDeep = errors.New("category path too long")
+)
+
+// 分类路径的最大长度，与表结构中path的长度一致
+const MaxCategoryPathLength = 768
+
+// 分类的路径：父分类路径 + category_id + "/"
+func categoryPath(parentPath string, category_id int64) string {
+	if parentPath == "" {
+		parentPath = "/"
+	}
+	return parentPath + strconv.FormatInt(category_id, 10) + "/"
+}
+
+// 查询父分类的路径，parent_id为0表示根分类
+// 锁定父分类的行，防止事务提交前父分类被移动或删除
+func parentCategoryPath(session *xorm.Session, parent_id int64) (string, error) {
+	if parent_id == 0 {
+		return "/", nil
+	}
+	var parent models.Category
+	exist, err := session.ForUpdate().Where("category_id = ?", parent_id).Get(&parent)
+	if err != nil {
+		return "", err
+	}
+	if !exist {
+		return "", ErrParentCategoryNotFound
+	}
+	return parent.Path, nil
+}
+
+// 增加分类，插入后根据自增id计算路径
+func InsertCategory(ctx context.Context, category *models.Category) error {
+	err := Transaction(ctx, func(session *xorm.Session) error {
+		parentPath, err := parentCategoryPath(session, category.ParentID)
+		if err != nil {
+			return err
+		}
+		if _, err := session.Insert(category); err != nil {
+			return err
+		}
+		category.Path = categoryPath(parentPath, category.CategoryID)
+		if len(category.Path) > MaxCategoryPathLength {
+			return ErrCategoryTooDeep
+		}
+		_, err = session.ID(category.CategoryID).Cols("path").Update(category)
+		return err
+	})
+	if err != nil && !isCategoryError(err) {
+		logger.Println(ctx, "增加分类失败:", err)
+	}
+	return err
+}
+
+// 根据category_id查询分类
+func QueryCategory(ctx context.Context, category_id int64, category *models.Category) (bool, error) {
+	return Engine.Context(ctx).Where("category_id = ?", category_id).Get(category)
+}
+
+// 查询分类的直接子分类
+func QueryChildCategories(ctx context.Context, category_id int64) ([]models.Category, error) {
+	var categories []models.Category
+	err := Engine.Context(ctx).Where("parent_id = ?", category_id).OrderBy("category_id").Find(&categories)
+	return categories, err
+}
+
+// 查询全部分类，按路径排序（父分类在子分类之前）
+func QueryAllCategories(ctx context.Context) ([]models.Category, error) {
+	var categories []models.Category
+	err := Engine.Context(ctx).OrderBy("path").Find(&categories)
+	return categories, err
+}
+
+// 修改分类的名称和父分类，父分类改变时同时修改所有子孙分类的路径
+// 返回修改后的分类和路径发生变化的子孙分类的category_id（用于删除缓存）
+func UpdateCategory(ctx context.Context, category_id int64, name string, parent_id int64) (models.Category, []int64, error) {
+	var category models.Category
+	var movedIDs []int64
+	err := Transaction(ctx, func(session *xorm.Session) error {
+		exist, err := session.ForUpdate().Where("category_id = ?", category_id).Get(&category)
+		if err != nil {
+			return err
+		}
+		if !exist {
+			return ErrCategoryNotFound
+		}
+		category.Name = name
+		if parent_id == category.ParentID {
+			_, err = session.ID(category_id).Cols("name").Update(&category)
+			return err
+		}
+
+		parentPath, err := parentCategoryPath(session, parent_id)
+		if err != nil {
+			return err
+		}
+		oldPath := category.Path
+		if strings.HasPrefix(parentPath, oldPath) {
+			return ErrCategoryCycle
+		}
+		var descendants []models.Category
+		err = session.Where("path LIKE ? AND category_id <> ?", oldPath+"%", category_id).
+			Cols("category_id", "path").
+			Find(&descendants)
+		if err != nil {
+			return err
+		}
+		category.ParentID = parent_id
+		category.Path = categoryPath(parentPath, category_id)
+		// 移动后最深的子孙分类的路径也不能超过最大长度
+		maxLength := len(category.Path)
+		for _, descendant := range descendants {
+			movedIDs = append(movedIDs, descendant.CategoryID)
+			maxLength = max(maxLength, len(category.Path)+len(descendant.Path)-len(oldPath))
+		}
+		if maxLength > MaxCategoryPathLength {
+			return ErrCategoryTooDeep
+		}
+		if _, err := session.ID(category_id).Cols("name", "parent_id", "path").Update(&category); err != nil {
+			return err
+		}
+		// 子孙分类的路径前缀替换为新路径
+		sql := fmt.Sprintf(
+			"UPDATE `%s` SET `path` = CONCAT(?, SUBSTRING(`path`, ?)) WHERE `path` LIKE ? AND `category_id` <> ?",
+			Engine.TableName(new(models.Category)),
+		)
+		_, err = session.Exec(sql, category.Path, len(oldPath)+1, oldPath+"%", category_id)
+		return err
+	})
+	if err != nil && !isCategoryError(err) {
+		logger.Println(ctx, "修改分类失败:", err)
+	}
+	return category, movedIDs, err
+}
+
+// 删除分类，有子分类时不能删除；同时删除商品和该分类的关联
+func DeleteCategory(ctx context.Context, category_id int64) (bool, error) {
+	var exist bool
+	err := Transaction(ctx, func(session *xorm.Session) error {
+		var err error
+		exist, err = session.Table(new(models.Category)).Where("category_id = ?", category_id).Exist()
+		if err != nil || !exist {
+			return err
+		}
+		hasChildren, err := session.Table(new(models.Category)).Where("parent_id = ?", category_id).Exist()
+		if err != nil {
+			return err
+		}
+		if hasChildren {
+			return ErrCategoryNotEmpty
+		}
+		if _, err := session.Where("category_id = ?", category_id).Delete(&models.ItemCategory{}); err != nil {
+			return err
+		}
+		_, err = session.ID(category_id).Delete(&models.Category{})
+		return err
+	})
+	if err != nil && !isCategoryError(err) {
+		logger.Println(ctx, "删除分类失败:", err)
+	}
+	return exist, err
+}
+
+// 设置商品所属的分类（全量替换），返回不存在的category_id
+// 商品不存在时返回false
+func SetItemCategories(ctx context.Context, item_id int64, categoryIDs []int64) (bool, []int64, error) {
+	var exist bool
+	var missing []int64
+	err := Transaction(ctx, func(session *xorm.Session) error {
+		var err error
+		exist, err = session.Table(new(models.Item)).Where("item_id = ?", item_id).Exist()
+		if err != nil || !exist {
+			return err
+		}
+		if len(categoryIDs) > 0 {
+			var found []int64
+			err = session.Table(new(models.Category)).In("category_id", categoryIDs).Cols("category_id").Find(&found)
+			if err != nil {
+				return err
+			}
+			missing = missingIDs(categoryIDs, found)
+			if len(missing) > 0 {
+				return nil
+			}
+		}
+		if _, err := session.Where("item_id = ?", item_id).Delete(&models.ItemCategory{}); err != nil {
+			return err
+		}
+		if len(categoryIDs) == 0 {
+			return nil
+		}
+		relations := make([]models.ItemCategory, 0, len(categoryIDs))
+		for _, category_id := range categoryIDs {
+			relations = append(relations, models.ItemCategory{ItemID: item_id, CategoryID: category_id})
+		}
+		_, err = session.Insert(&relations)
+		return err
+	})
+	if err != nil {
+		logger.Println(ctx, "设置商品分类失败:", err)
+	}
+	return exist, missing, err
+}
+
+// 查询商品所属的分类
+func QueryItemCategories(ctx context.Context, item_id int64) ([]models.Category, error) {
+	var categories []models.Category
+	relationTable := Engine.TableName(new(models.ItemCategory))
+	categoryTable := Engine.TableName(new(models.Category))
+	err := Engine.Context(ctx).Table(categoryTable).
+		Join("INNER", relationTable, fmt.Sprintf("`%s`.`category_id` = `%s`.`category_id`", relationTable, categoryTable)).
+		Where(fmt.Sprintf("`%s`.`item_id` = ?", relationTable), item_id).
+		OrderBy(fmt.Sprintf("`%s`.`category_id`", categoryTable)).
+		Find(&categories)
+	return categories, err
+}
+
+// 分页查询分类下的商品item_id（按item_id升序），同时返回总数
+// includeDescendants为true时包含所有子孙分类下的商品，publishedSite不为空时只返回在该站点已发布的商品
+func QueryCategoryItemIDs(ctx context.Context, category models.Category, includeDescendants bool, publishedSite string, offset, limit int) ([]int64, int64, error) {
+	relationTable := Engine.TableName(new(models.ItemCategory))
+	categoryTable := Engine.TableName(new(models.Category))
+	where := fmt.Sprintf("`%s`.`category_id` = ?", relationTable)
+	args := []interface{}{category.CategoryID}
+	from := fmt.Sprintf("`%s`", relationTable)
+	if includeDescendants {
+		from = fmt.Sprintf("`%s` INNER JOIN `%s` ON `%s`.`category_id` = `%s`.`category_id`",
+			relationTable, categoryTable, categoryTable, relationTable)
+		where = fmt.Sprintf("`%s`.`path` LIKE ?", categoryTable)
+		args = []interface{}{category.Path + "%"}
+	}
+	if publishedSite != "" {
+		condition, conditionArgs := publishedCondition(fmt.Sprintf("`%s`.`item_id`", relationTable), publishedSite)
+		where += " AND " + condition
+		args = append(args, conditionArgs...)
+	}
+
+	var total int64
+	countSQL := fmt.Sprintf("SELECT COUNT(DISTINCT `%s`.`item_id`) FROM %s WHERE %s", relationTable, from, where)
+	if _, err := Engine.Context(ctx).SQL(countSQL, args...).Get(&total); err != nil {
+		return nil, 0, err
+	}
+	var itemIDs []int64
+	querySQL := fmt.Sprintf("SELECT DISTINCT `%s`.`item_id` FROM %s WHERE %s ORDER BY `%s`.`item_id` LIMIT ? OFFSET ?",
+		relationTable, from, where, relationTable)
+	err := Engine.Context(ctx).SQL(querySQL, append(args, limit, offset)...).Find(&itemIDs)
+	return itemIDs, total, err
+}
+
+// 删除商品时删除商品和分类的关联
+func deleteItemCategoriesIn(db xorm.Interface, item_id int64) error {
+	_, err := db.Where("item_id = ?", item_id).Delete(&models.ItemCategory{})
+	return err
+}
+
+// 是否为分类操作的业务错误（不需要记录日志）
+func isCategoryError(err error) bool {
+	return errors.Is(err, ErrParentCategoryNotFound) || errors.Is(err, ErrCategoryCycle) ||
+		errors.Is(err, ErrCategoryNotEmpty) || errors.Is(err, ErrCategoryNotFound) || errors.Is(err, ErrCategoryTooDeep)
+}
+
+// 返回ids中不在found中的id
+func missingIDs(ids, found []int64) []int64 {
+	set := make(map[int64]struct{}, len(found))
+	for _, id := range found {
+		set[id] = struct{}{}
+	}
+	var missing []int64
+	for _, id := range ids {
+		if _, ok := set[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,53 @@
+package database
+
+import (
+	"context"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// 幂等键在Redis中的键名
+func idempotencyKey(key string) string {
+	return namespace + "idempotency:" + key
+}
+
+// 保存幂等记录，onlyIfAbsent为true时只有键不存在才保存，返回是否保存成功
+func SetIdempotencyRecord(ctx context.Context, key string, record []byte, expireSec int, onlyIfAbsent bool) (bool, error) {
+	conn := getConn(ctx)
+	defer conn.Close()
+	args := []interface{}{idempotencyKey(key), record, "EX", expireSec}
+	if onlyIfAbsent {
+		args = append(args, "NX")
+	}
+	_, err := redis.String(conn.Do("SET", args...))
+	if err == redis.ErrNil {
+		// NX 条件不满足时返回nil
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// 获取幂等记录
+func GetIdempotencyRecord(ctx context.Context, key string) ([]byte, bool, error) {
+	conn := getConn(ctx)
+	defer conn.Close()
+	record, err := redis.Bytes(conn.Do("GET", idempotencyKey(key)))
+	if err == redis.ErrNil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return record, true, nil
+}
+
+// 删除幂等记录
+func DeleteIdempotencyRecord(ctx context.Context, key string) error {
+	conn := getConn(ctx)
+	defer conn.Close()
+	_, err := conn.Do("DEL", idempotencyKey(key))
+	return err
+}
@@ -0,0 +1,114 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"miHttpServer/config"
+	"miHttpServer/models"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"xorm.io/xorm"
+)
+
+// MySQL唯一键冲突的错误码
+const mysqlDuplicateEntry = 1062
+
+// 计算商品名称的唯一键：名称全局唯一时为名称，按站点唯一时为“站点/名称”
+func NameKey(site, name string) string {
+	name = strings.TrimSpace(name)
+	if config.Configs.Item.UniqueNamePerSite {
+		return site + "/" + name
+	}
+	return name
+}
+
+// 判断错误是否为MySQL唯一键冲突
+func IsDuplicateKey(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlDuplicateEntry
+}
+
+// 根据名称唯一键查询商品
+func QueryItemByNameKey(db xorm.Interface, nameKey string, item *models.Item) (bool, error) {
+	return db.Where("name_key = ?", nameKey).Get(item)
+}
+
+// 按当前配置计算后名称唯一键相同的一组商品
+type nameKeyConflict struct {
+	NameKey string `xorm:"name_key"`
+	ItemIDs string `xorm:"item_ids"`
+}
+
+// 按当前配置重新计算所有商品的名称唯一键
+// 已有数据存在重复名称时无法建立唯一约束，先列出所有冲突的商品并返回错误，需要人工处理重复数据后再启动
+func syncNameKeys() error {
+	expr := "TRIM(`name`)"
+	if config.Configs.Item.UniqueNamePerSite {
+		expr = "CONCAT(`site`, '/', TRIM(`name`))"
+	}
+	table := Engine.TableName(new(models.Item))
+
+	var conflicts []nameKeyConflict
+	err := Engine.SQL(fmt.Sprintf(
+		"SELECT %s AS `name_key`, GROUP_CONCAT(`item_id` ORDER BY `item_id`) AS `item_ids` FROM `%s` GROUP BY %s HAVING COUNT(*) > 1",
+		expr, table, expr,
+	)).Find(&conflicts)
+	if err != nil {
+		return fmt.Errorf("检查重复的商品名称失败: %w", err)
+	}
+	if len(conflicts) > 0 {
+		pairs := make([]string, 0, len(conflicts))
+		for _, conflict := range conflicts {
+			pairs = append(pairs, fmt.Sprintf("%s(item_id: %s)", conflict.NameKey, conflict.ItemIDs))
+		}
+		return fmt.Errorf("存在%d组重复的商品名称，无法建立名称唯一约束: %s", len(conflicts), strings.Join(pairs, "; "))
+	}
+
+	result, err := Engine.Exec(fmt.Sprintf(
+		"UPDATE `%s` SET `name_key` = %s WHERE `name_key` IS NULL OR `name_key` <> %s",
+		table, expr, expr,
+	))
+	if err != nil {
+		return fmt.Errorf("计算商品名称唯一键失败: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		log.Printf("更新了%d个商品的名称唯一键", n)
+	}
+	return nil
+}
+
+// 查询商品所在的站点（创建后不会修改），商品不存在时返回false
+func QueryItemSite(ctx context.Context, item_id int64) (string, bool, error) {
+	var item models.Item
+	exist, err := Engine.Context(ctx).Where("item_id = ?", item_id).Cols("site").Get(&item)
+	return item.Site, exist, err
+}
+
+// 查询多个商品所在的站点（item_id -> 站点），不存在的商品不在结果中
+func QueryItemSites(ctx context.Context, itemIDs []int64) (map[int64]string, error) {
+	sites := make(map[int64]string, len(itemIDs))
+	if len(itemIDs) == 0 {
+		return sites, nil
+	}
+	var items []models.Item
+	if err := Engine.Context(ctx).In("item_id", itemIDs).Cols("item_id", "site").Find(&items); err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		sites[item.ItemID] = item.Site
+	}
+	return sites, nil
+}
+
+// 根据多个名称唯一键查询商品
+func QueryItemsByNameKeys(ctx context.Context, nameKeys []string) ([]models.Item, error) {
+	var items []models.Item
+	if len(nameKeys) == 0 {
+		return items, nil
+	}
+	err := Engine.Context(ctx).In("name_key", nameKeys).Find(&items)
+	return items, err
+}
@@ -0,0 +1,79 @@
+package database
+
+import (
+	"context"
+	"miHttpServer/models"
+	"miHttpServer/storage"
+
+	"xorm.io/xorm"
+)
+
+// 保存商品的媒体文件记录
+func InsertMedia(ctx context.Context, media *models.ItemMedia) error {
+	if _, err := Engine.Context(ctx).Insert(media); err != nil {
+		return err
+	}
+	fillMediaURL(media)
+	return nil
+}
+
+// 查询商品的媒体文件（按上传时间升序）
+func QueryItemMedia(ctx context.Context, item_id int64) ([]models.ItemMedia, error) {
+	var media []models.ItemMedia
+	if err := Engine.Context(ctx).Where("item_id = ?", item_id).OrderBy("created_at, media_id").Find(&media); err != nil {
+		return media, err
+	}
+	for i := range media {
+		fillMediaURL(&media[i])
+	}
+	return media, nil
+}
+
+// 统计商品的媒体文件数量
+func CountItemMedia(ctx context.Context, item_id int64) (int64, error) {
+	return Engine.Context(ctx).Where("item_id = ?", item_id).Count(new(models.ItemMedia))
+}
+
+// 删除商品的一个媒体文件记录，返回被删除的记录（不存在时返回nil）
+func DeleteMedia(ctx context.Context, item_id int64, media_id string) (*models.ItemMedia, error) {
+	var media models.ItemMedia
+	exist, err := Engine.Context(ctx).Where("item_id = ? AND media_id = ?", item_id, media_id).Get(&media)
+	if err != nil || !exist {
+		return nil, err
+	}
+	if _, err := Engine.Context(ctx).Where("item_id = ? AND media_id = ?", item_id, media_id).Delete(&models.ItemMedia{}); err != nil {
+		return nil, err
+	}
+	return &media, nil
+}
+
+// 在事务中删除商品的全部媒体文件记录，文件本身在事务提交后按前缀删除
+func deleteItemMediaIn(db xorm.Interface, item_id int64) error {
+	_, err := db.Where("item_id = ?", item_id).Delete(&models.ItemMedia{})
+	return err
+}
+
+// 查询商品的媒体文件，填充到items中
+func loadItemMediaIn(db xorm.Interface, itemIDs []int64, index map[int64]int, items []models.Item) error {
+	var media []models.ItemMedia
+	if err := db.In("item_id", itemIDs).OrderBy("created_at, media_id").Find(&media); err != nil {
+		return err
+	}
+	for _, m := range media {
+		fillMediaURL(&m)
+		item := &items[index[m.ItemID]]
+		item.Media = append(item.Media, m)
+	}
+	return nil
+}
+
+// 根据文件的key生成访问地址
+func fillMediaURL(media *models.ItemMedia) {
+	if storage.Blobs == nil {
+		return
+	}
+	media.URL = storage.Blobs.URL(media.BlobKey)
+	if media.ThumbnailKey != "" {
+		media.ThumbnailURL = storage.Blobs.URL(media.ThumbnailKey)
+	}
+}
@@ -1,18 +1,40 @@
 package database
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
 	"miHttpServer/config"
 	"miHttpServer/logger"
+	"miHttpServer/metrics"
 	"miHttpServer/models"
+	"miHttpServer/tracing"
+	"os"
+	"strings"
 
 	_ "github.com/go-sql-driver/mysql"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"xorm.io/xorm"
+	"xorm.io/xorm/contexts"
 )
 
 var Engine *xorm.Engine
 
+// 商品不存在（或已被删除），写入商品下的数据时由调用方转换为404
+var ErrItemNotFound = errors.New("item not found")
+
+// xorm的SQL日志文件
+var xormLogFile *os.File
+
+// 启动时同步表结构的所有表
+var tables = []interface{}{
+	new(models.Item), new(models.ItemAudit), new(models.ItemStock), new(models.StockReservation),
+	new(models.Category), new(models.ItemCategory), new(models.ItemAttribute), new(models.ItemTag),
+	new(models.ItemMedia), new(models.PriceSchedule), new(models.ItemStatus),
+	new(models.ItemSKU),
+}
+
 func InitMySQL() error {
 	// 数据库连接基本信息
 	var (
@@ -31,66 +53,407 @@ func InitMySQL() error {
 		return err
 	}
 
-	// 设置日志
-	xormLogFile := logger.SetMySQLLogger(Engine)
-	defer xormLogFile.Close()
+	// 设置日志，日志文件在关闭MySQL连接时关闭（运行期间的SQL日志也要写入）
+	xormLogFile = logger.SetMySQLLogger(Engine)
+	// 记录每条SQL语句的执行时间和跨度
+	Engine.AddHook(queryHook{})
+
+	// 发布状态表不存在时，说明是从没有发布状态的版本升级，需要设置已有商品的状态
+	statusTableExist, err := Engine.IsTableExist(new(models.ItemStatus))
+	if err != nil {
+		return err
+	}
 
 	// 同步表结构
-	err = Engine.Sync2(new(models.Item))
+	err = Engine.Sync2(tables...)
 	if err != nil {
 		return err
 	}
+	// 按配置计算已有商品的名称唯一键，存在重复名称时停止启动
+	if err := syncNameKeys(); err != nil {
+		return err
+	}
+	if !statusTableExist {
+		publishExistingItems()
+	}
 
 	return nil
 }
 
+// 记录SQL语句执行时间和跨度的xorm钩子
+// 只有通过Session.Context传入请求的context时才会记录跨度
+type queryHook struct{}
+
+// 保存SQL语句跨度的context键，与请求的跨度区分，没有开始跨度时不会结束父跨度
+type querySpanKey struct{}
+
+func (queryHook) BeforeProcess(c *contexts.ContextHook) (context.Context, error) {
+	operation := sqlOperation(c.SQL)
+	ctx, span := tracing.StartClient(c.Ctx, "mysql "+operation)
+	if !span.IsRecording() {
+		return c.Ctx, nil
+	}
+	span.SetAttributes(
+		attribute.String("db.system", "mysql"),
+		attribute.String("db.operation", operation),
+		attribute.String("db.statement", c.SQL),
+	)
+	return context.WithValue(ctx, querySpanKey{}, span), nil
+}
+
+func (queryHook) AfterProcess(c *contexts.ContextHook) error {
+	metrics.MySQLQueryDuration.Observe(c.ExecuteTime.Seconds(), sqlOperation(c.SQL))
+	if span, ok := c.Ctx.Value(querySpanKey{}).(trace.Span); ok {
+		tracing.SetError(span, c.Err)
+		span.End()
+	}
+	return nil
+}
+
+// SQL语句的类型，作为监控指标的标签，未知的类型统一为other避免标签值过多
+func sqlOperation(sql string) string {
+	fields := strings.Fields(sql)
+	if len(fields) == 0 {
+		return "other"
+	}
+	switch operation := strings.ToLower(fields[0]); operation {
+	case "select", "insert", "update", "delete", "replace", "begin", "commit", "rollback":
+		return operation
+	}
+	return "other"
+}
+
+// 检查MySQL是否可用，ctx超时后返回错误
+func PingMySQL(ctx context.Context) error {
+	return Engine.PingContext(ctx)
+}
+
 // 关闭MySQL连接
 func CloseMySQL() {
 	if Engine != nil {
 		Engine.Close()
 	}
+	if xormLogFile != nil {
+		xormLogFile.Close()
+	}
 }
 
-// 插入数据
-func InsertItem(item *models.Item) (int64, error) {
-	// 单条插入数据，一般不需要使用事务
-	n, err := Engine.Insert(item)
-	if err != nil {
-		log.Println("插入失败:", err)
-	}
+// 插入数据，和变更历史在同一个事务中写入
+func InsertItem(ctx context.Context, item *models.Item, audit models.AuditInfo) (int64, error) {
+	var n int64
+	err := Transaction(ctx, func(session *xorm.Session) error {
+		var err error
+		n, err = InsertItemIn(ctx, session, item, audit)
+		return err
+	})
 	return n, err
 }
 
-// 更新数据
-func UpdateItem(item_id int64, item *models.Item) (int64, error) {
-	n, err := Engine.Where("item_id = ?", item_id).Update(item)
-	if err != nil {
-		log.Println("更新数据失败:", err)
-	}
+// 更新数据（全量更新，指定列保证零值也会被更新），和变更历史在同一个事务中写入
+func UpdateItem(ctx context.Context, item_id int64, item *models.Item, audit models.AuditInfo) (int64, error) {
+	var n int64
+	err := Transaction(ctx, func(session *xorm.Session) error {
+		var err error
+		n, err = UpdateItemIn(ctx, session, item_id, item, audit)
+		return err
+	})
 	return n, err
 }
 
-// 根据item_id查询数据
-func QueryItem(item_id int64, item *models.Item) (bool, error) {
-	success, err := Engine.Where("item_id = ?", item_id).Get(item)
+// 根据item_id查询数据（包括属性和标签）
+// ctx中有跨度时记录查询的跨度，其中包含每条SQL语句的跨度
+func QueryItem(ctx context.Context, item_id int64, item *models.Item) (bool, error) {
+	ctx, span := tracing.Start(ctx, "mysql.QueryItem")
+	defer span.End()
+	session := Engine.NewSession().Context(ctx)
+	defer session.Close()
+
+	success, err := session.Where("item_id = ?", item_id).Get(item)
+	if err != nil || !success {
+		tracing.SetError(span, err)
+		return success, err
+	}
+	err = loadItemExtrasOf(session, item)
+	tracing.SetError(span, err)
 	return success, err
 }
 
-// 根据item_id删除数据
-func DeleteItem(item_id int64) (int64, error) {
-	session := Engine.NewSession()
+// 根据item_id删除数据，和变更历史在同一个事务中写入
+func DeleteItem(ctx context.Context, item_id int64, audit models.AuditInfo) (int64, error) {
+	var n int64
+	err := Transaction(ctx, func(session *xorm.Session) error {
+		var err error
+		n, err = DeleteItemIn(ctx, session, item_id, audit)
+		return err
+	})
+	return n, err
+}
+
+// 部分更新数据，只更新patch中不为nil的字段（包括零值），返回更新后的完整数据
+func PatchItem(ctx context.Context, item_id int64, patch models.ItemPatch, audit models.AuditInfo) (models.Item, bool, error) {
+	var item models.Item
+	session := Engine.NewSession().Context(ctx)
 	defer session.Close()
-	session.Begin()
-	defer func() {
-		if err := recover(); err != nil {
+	if err := session.Begin(); err != nil {
+		return item, false, err
+	}
+	// 加行锁读取当前数据，保证读取和更新之间不被其他事务修改
+	exist, err := session.ForUpdate().Where("item_id = ?", item_id).Get(&item)
+	if err != nil || !exist {
+		session.Rollback()
+		return item, false, err
+	}
+	before := models.NewItemSnapshot(item)
+	// 查询属性和标签用于合并，其他数据一起返回用于同步缓存
+	if err := loadItemExtrasOf(session, &item); err != nil {
+		session.Rollback()
+		return item, false, err
+	}
+	attributes, err := mergeAttributes(item.Attributes, patch)
+	if err != nil {
+		session.Rollback()
+		return item, false, err
+	}
+	if err := setItemExtrasIn(session, item_id, attributes, patch.Tags); err != nil {
+		logger.Println(ctx, "保存商品属性和标签失败:", err)
+		session.Rollback()
+		return item, false, err
+	}
+	if attributes != nil {
+		item.Attributes = attributes
+	}
+	if patch.Tags != nil {
+		item.Tags = patch.Tags
+	}
+	var cols []string
+	if patch.Name != nil {
+		item.Name = *patch.Name
+		item.NameKey = NameKey(item.Site, item.Name)
+		cols = append(cols, "name", "name_key")
+	}
+	if patch.Price != nil {
+		item.Price = *patch.Price
+		cols = append(cols, "price")
+	}
+	if len(cols) > 0 {
+		// 指定列后xorm也会更新零值
+		_, err = session.ID(item_id).Cols(cols...).Update(&item)
+		if err != nil {
+			if !IsDuplicateKey(err) {
+				logger.Println(ctx, "部分更新数据失败:", err)
+			}
+			session.Rollback()
+			return item, false, err
+		}
+		if err := insertAudit(ctx, session, audit, models.AuditUpdate, item_id, before, models.NewItemSnapshot(item)); err != nil {
 			session.Rollback()
-		} else {
-			session.Commit()
+			return item, false, err
+		}
+	}
+	return item, true, session.Commit()
+}
+
+// 在同一个事务中执行fn，fn返回错误时回滚，否则提交
+// 事务中的SQL语句使用ctx，记录跨度和请求ID
+func Transaction(ctx context.Context, fn func(session *xorm.Session) error) error {
+	session := Engine.NewSession().Context(ctx)
+	// 未提交的事务在Close时回滚
+	defer session.Close()
+	if err := session.Begin(); err != nil {
+		return err
+	}
+	if err := fn(session); err != nil {
+		return err
+	}
+	return session.Commit()
+}
+
+// 使用指定的事务插入数据并写入变更历史
+func InsertItemIn(ctx context.Context, db xorm.Interface, item *models.Item, audit models.AuditInfo) (int64, error) {
+	item.NameKey = NameKey(item.Site, item.Name)
+	n, err := db.Insert(item)
+	if err != nil {
+		if !IsDuplicateKey(err) {
+			logger.Println(ctx, "插入失败:", err)
 		}
-	}()
-	n, err := session.ID(item_id).Delete(&models.Item{})
+		return n, err
+	}
+	if err := setItemExtrasIn(db, item.ItemID, item.Attributes, item.Tags); err != nil {
+		logger.Println(ctx, "保存商品属性和标签失败:", err)
+		return n, err
+	}
+	return n, insertAudit(ctx, db, audit, models.AuditAdd, item.ItemID, nil, models.NewItemSnapshot(*item))
+}
+
+// 使用指定的事务更新数据（全量更新）并写入变更历史
+// 名称唯一键依赖商品所属站点，因此先查询修改前的数据
+func UpdateItemIn(ctx context.Context, db *xorm.Session, item_id int64, item *models.Item, audit models.AuditInfo) (int64, error) {
+	var existing models.Item
+	// 加行锁读取修改前的数据，保证变更历史中的修改前数据与实际被覆盖的数据一致
+	exist, err := db.ForUpdate().Where("item_id = ?", item_id).Get(&existing)
+	if err != nil || !exist {
+		return 0, err
+	}
+	item.ItemID = item_id
+	item.Site = existing.Site
+	item.NameKey = NameKey(existing.Site, item.Name)
+	n, err := db.Where("item_id = ?", item_id).Cols("name", "price", "name_key").Update(item)
 	if err != nil {
-		panic(err)
+		if !IsDuplicateKey(err) {
+			logger.Println(ctx, "更新数据失败:", err)
+		}
+		return n, err
 	}
-	return n, err
+	// 请求中没有属性或标签时保留原来的值，查询最终的值用于同步缓存
+	if err := setItemExtrasIn(db, item_id, item.Attributes, item.Tags); err != nil {
+		logger.Println(ctx, "保存商品属性和标签失败:", err)
+		return n, err
+	}
+	if err := loadItemExtrasOf(db, item); err != nil {
+		return n, err
+	}
+	return n, insertAudit(ctx, db, audit, models.AuditUpdate, item_id, models.NewItemSnapshot(existing), models.NewItemSnapshot(*item))
+}
+
+// 使用指定的事务删除数据并写入变更历史
+func DeleteItemIn(ctx context.Context, db xorm.Interface, item_id int64, audit models.AuditInfo) (int64, error) {
+	var existing models.Item
+	exist, err := db.Where("item_id = ?", item_id).Get(&existing)
+	if err != nil || !exist {
+		return 0, err
+	}
+	n, err := db.ID(item_id).Delete(&models.Item{})
+	if err != nil {
+		logger.Println(ctx, "删除数据失败:", err)
+		return n, err
+	}
+	if err := deleteItemCategoriesIn(db, item_id); err != nil {
+		logger.Println(ctx, "删除商品分类失败:", err)
+		return n, err
+	}
+	if err := deleteItemExtrasIn(db, item_id); err != nil {
+		logger.Println(ctx, "删除商品属性和标签失败:", err)
+		return n, err
+	}
+	if err := deleteItemMediaIn(db, item_id); err != nil {
+		logger.Println(ctx, "删除商品媒体文件记录失败:", err)
+		return n, err
+	}
+	if err := deletePriceSchedulesIn(db, item_id); err != nil {
+		logger.Println(ctx, "删除商品定时价格失败:", err)
+		return n, err
+	}
+	if err := deleteItemStatusesIn(db, item_id); err != nil {
+		logger.Println(ctx, "删除商品发布状态失败:", err)
+		return n, err
+	}
+	if err := deleteItemSKUsIn(db, item_id); err != nil {
+		logger.Println(ctx, "删除商品SKU失败:", err)
+		return n, err
+	}
+	if err := deleteItemStockIn(db, item_id); err != nil {
+		logger.Println(ctx, "删除商品库存失败:", err)
+		return n, err
+	}
+	return n, insertAudit(ctx, db, audit, models.AuditDelete, item_id, models.NewItemSnapshot(existing), nil)
+}
+
+// 根据多个item_id查询数据（WHERE item_id IN (...)）
+func QueryItems(ctx context.Context, itemIDs []int64) ([]models.Item, error) {
+	var items []models.Item
+	if len(itemIDs) == 0 {
+		return items, nil
+	}
+	ctx, span := tracing.Start(ctx, "mysql.QueryItems")
+	span.SetAttributes(attribute.Int("item.count", len(itemIDs)))
+	defer span.End()
+	session := Engine.NewSession().Context(ctx)
+	defer session.Close()
+
+	if err := session.In("item_id", itemIDs).Find(&items); err != nil {
+		tracing.SetError(span, err)
+		return items, err
+	}
+	err := LoadItemExtras(session, items)
+	tracing.SetError(span, err)
+	return items, err
+}
+
+// 按item_id升序分页查询item_id大于afterID的数据，用于分块遍历全表
+// publishedSite不为空时只返回在该站点已发布的商品
+func QueryItemsAfter(ctx context.Context, afterID int64, limit int, publishedSite string) ([]models.Item, error) {
+	var items []models.Item
+	session := Engine.Context(ctx).Where("item_id > ?", afterID)
+	if publishedSite != "" {
+		condition, args := publishedCondition("`item_id`", publishedSite)
+		session = session.And(condition, args...)
+	}
+	err := session.OrderBy("item_id").Limit(limit).Find(&items)
+	return items, err
+}
+
+// 批量插入或更新数据，并在同一个事务中写入变更历史，items中为写入后的数据（新增的商品带有item_id）
+// item_id不为0时按item_id更新name和price，名称唯一键按商品保存的站点计算；商品已被删除时按指定的item_id重新插入，记录为恢复
+// item_id为0时按名称唯一键查找，已存在的商品更新name和price，否则插入新记录
+// 不使用INSERT ... ON DUPLICATE KEY UPDATE：item_id和名称唯一键同时冲突时MySQL更新的行不确定
+func UpsertItems(ctx context.Context, items []models.Item, audit models.AuditInfo) (int64, error) {
+	if len(items) == 0 {
+		return 0, nil
+	}
+	var affected int64
+	err := Transaction(ctx, func(session *xorm.Session) error {
+		for i := range items {
+			n, err := upsertItemIn(ctx, session, &items[i], audit)
+			if err != nil {
+				return err
+			}
+			affected += n
+		}
+		return nil
+	})
+	if err != nil {
+		if !IsDuplicateKey(err) {
+			logger.Println(ctx, "批量写入数据失败:", err)
+		}
+		return 0, err
+	}
+	return affected, nil
+}
+
+// 在事务中插入或更新一个商品，加行锁读取已有的数据用于生成变更历史
+func upsertItemIn(ctx context.Context, session *xorm.Session, item *models.Item, audit models.AuditInfo) (int64, error) {
+	var existing models.Item
+	var exist bool
+	var err error
+	if item.ItemID > 0 {
+		exist, err = session.ForUpdate().Where("item_id = ?", item.ItemID).Get(&existing)
+	} else {
+		exist, err = session.ForUpdate().Where("name_key = ?", NameKey(item.Site, item.Name)).Get(&existing)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if !exist {
+		operation := models.AuditAdd
+		if item.ItemID > 0 {
+			operation = models.AuditRestore
+		}
+		item.NameKey = NameKey(item.Site, item.Name)
+		n, err := session.Insert(item)
+		if err != nil {
+			return n, err
+		}
+		return n, insertAudit(ctx, session, audit, operation, item.ItemID, nil, models.NewItemSnapshot(*item))
+	}
+
+	before := models.NewItemSnapshot(existing)
+	existing.Name = item.Name
+	existing.Price = item.Price
+	existing.NameKey = NameKey(existing.Site, item.Name)
+	n, err := session.ID(existing.ItemID).Cols("name", "price", "name_key").Update(&existing)
+	if err != nil {
+		return n, err
+	}
+	*item = existing
+	return n, insertAudit(ctx, session, audit, models.AuditUpdate, existing.ItemID, before, models.NewItemSnapshot(existing))
 }
@@ -0,0 +1,148 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"miHttpServer/models"
+	"time"
+
+	"xorm.io/xorm"
+)
+
+// 同一商品在同一站点的定时价格时间段重叠
+var ErrPriceScheduleOverlap = errors.New("price schedule overlaps")
+
+// 定时价格已经结束或取消，不能再取消
+var ErrPriceScheduleFinished = errors.New("price schedule finished")
+
+// 增加定时价格，同一商品在同一站点未结束的定时价格时间段不能重叠
+// 开始时间已到时直接设为生效中；商品不存在（或已被删除）时返回ErrItemNotFound
+func InsertPriceSchedule(ctx context.Context, schedule *models.PriceSchedule) error {
+	return Transaction(ctx, func(session *xorm.Session) error {
+		// 锁住商品行，避免并发增加时重叠检查失效，也避免商品同时被删除
+		var item models.Item
+		exist, err := session.Where("item_id = ?", schedule.ItemID).Cols("item_id").ForUpdate().Get(&item)
+		if err != nil {
+			return err
+		}
+		if !exist {
+			return ErrItemNotFound
+		}
+		overlaps, err := session.Where("item_id = ? AND site = ?", schedule.ItemID, schedule.Site).
+			In("status", models.PriceScheduled, models.PriceActive).
+			And("start_at < ? AND end_at > ?", formatDBTime(schedule.EndAt), formatDBTime(schedule.StartAt)).
+			Count(new(models.PriceSchedule))
+		if err != nil {
+			return err
+		}
+		if overlaps > 0 {
+			return ErrPriceScheduleOverlap
+		}
+		schedule.Status = models.PriceScheduled
+		if !schedule.StartAt.After(time.Now()) {
+			schedule.Status = models.PriceActive
+		}
+		_, err = session.Insert(schedule)
+		return err
+	})
+}
+
+// 查询商品在站点的定时价格（按开始时间升序）
+func QueryPriceSchedules(ctx context.Context, item_id int64, site string) ([]models.PriceSchedule, error) {
+	var schedules []models.PriceSchedule
+	err := Engine.Context(ctx).Where("item_id = ? AND site = ?", item_id, site).OrderBy("start_at, schedule_id").Find(&schedules)
+	return schedules, err
+}
+
+// 取消定时价格，返回取消后的记录（不存在时返回nil）
+// 已经结束或取消的定时价格返回ErrPriceScheduleFinished和当前记录
+func CancelPriceSchedule(ctx context.Context, item_id int64, site string, schedule_id int64) (*models.PriceSchedule, error) {
+	var schedule models.PriceSchedule
+	err := Transaction(ctx, func(session *xorm.Session) error {
+		exist, err := session.Where("schedule_id = ? AND item_id = ? AND site = ?", schedule_id, item_id, site).
+			ForUpdate().Get(&schedule)
+		if err != nil || !exist {
+			return err
+		}
+		if schedule.Status == models.PriceEnded || schedule.Status == models.PriceCancelled {
+			return ErrPriceScheduleFinished
+		}
+		schedule.Status = models.PriceCancelled
+		_, err = session.ID(schedule_id).Cols("status").Update(&schedule)
+		return err
+	})
+	if schedule.ScheduleID == 0 {
+		return nil, err
+	}
+	return &schedule, err
+}
+
+// 在事务中删除商品的全部定时价格
+func deletePriceSchedulesIn(db xorm.Interface, item_id int64) error {
+	_, err := db.Where("item_id = ?", item_id).Delete(&models.PriceSchedule{})
+	return err
+}
+
+// 查询商品当前生效的促销价，填充到items中
+// 按时间判断是否生效，不依赖后台任务更新的状态
+func loadSalePricesIn(db xorm.Interface, itemIDs []int64, index map[int64]int, items []models.Item) error {
+	now := formatDBTime(time.Now())
+	var schedules []models.PriceSchedule
+	err := db.In("item_id", itemIDs).
+		And("status <> ? AND start_at <= ? AND end_at > ?", models.PriceCancelled, now, now).
+		Find(&schedules)
+	if err != nil {
+		return err
+	}
+	for _, schedule := range schedules {
+		item := &items[index[schedule.ItemID]]
+		if item.SalePrices == nil {
+			item.SalePrices = make(map[string]models.SalePrice)
+		}
+		item.SalePrices[schedule.Site] = models.SalePrice{
+			ScheduleID: schedule.ScheduleID,
+			Price:      schedule.Price,
+			EndAt:      schedule.EndAt,
+		}
+	}
+	return nil
+}
+
+// 查询在(from, to]期间开始或结束的定时价格对应的item_id，这些商品的缓存需要删除
+func QueryPriceBoundaryItemIDs(ctx context.Context, from, to time.Time) ([]int64, error) {
+	fromStr, toStr := formatDBTime(from), formatDBTime(to)
+	var itemIDs []int64
+	err := Engine.Context(ctx).Table(new(models.PriceSchedule)).
+		Where("status <> ?", models.PriceCancelled).
+		And("((start_at > ? AND start_at <= ?) OR (end_at > ? AND end_at <= ?))", fromStr, toStr, fromStr, toStr).
+		Distinct("item_id").
+		Find(&itemIDs)
+	return itemIDs, err
+}
+
+// 查询在(from, to]期间被取消的定时价格对应的item_id
+// 取消时只删除了处理请求的实例的缓存，其他实例根据updated_at删除自己的本地缓存
+func QueryCancelledPriceItemIDs(ctx context.Context, from, to time.Time) ([]int64, error) {
+	var itemIDs []int64
+	err := Engine.Context(ctx).Table(new(models.PriceSchedule)).
+		Where("status = ? AND updated_at > ? AND updated_at <= ?", models.PriceCancelled, formatDBTime(from), formatDBTime(to)).
+		Distinct("item_id").
+		Find(&itemIDs)
+	return itemIDs, err
+}
+
+// 按当前时间更新定时价格的状态，返回开始生效和结束的数量
+func UpdatePriceScheduleStatuses(ctx context.Context, now time.Time) (int64, int64, error) {
+	nowStr := formatDBTime(now)
+	ended, err := Engine.Context(ctx).In("status", models.PriceScheduled, models.PriceActive).
+		And("end_at <= ?", nowStr).
+		Cols("status").
+		Update(&models.PriceSchedule{Status: models.PriceEnded})
+	if err != nil {
+		return 0, 0, err
+	}
+	activated, err := Engine.Context(ctx).Where("status = ? AND start_at <= ?", models.PriceScheduled, nowStr).
+		Cols("status").
+		Update(&models.PriceSchedule{Status: models.PriceActive})
+	return activated, ended, err
+}
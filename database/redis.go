@@ -1,15 +1,21 @@
 package database
 
 import (
+	"context"
 	"encoding/json"
 	"log"
 	"miHttpServer/config"
+	"miHttpServer/logger"
+	"miHttpServer/metrics"
 	"miHttpServer/models"
+	"miHttpServer/tracing"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gomodule/redigo/redis"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // 存储Redis连接池的实例
@@ -39,9 +45,11 @@ func InitRedis() {
 		MaxIdle:     maxIdle,     // 最大空闲连接数
 		MaxActive:   idleTimeout, // 最大连接数
 		IdleTimeout: timeout,     // 超时时间
-		// 创建与Redis服务器的连接
-		Dial: func() (redis.Conn, error) {
-			conn, err := redis.Dial(
+		// 创建与Redis服务器的连接，ctx用于限制就绪检查等调用方的等待时间
+		// Redis不可用时服务降级运行，不能退出进程
+		DialContext: func(ctx context.Context) (redis.Conn, error) {
+			conn, err := redis.DialContext(
+				ctx,
 				protocal,
 				address,
 				redis.DialPassword(password),
@@ -50,7 +58,7 @@ func InitRedis() {
 				redis.DialWriteTimeout(writeTimeout),
 			)
 			if err != nil {
-				log.Fatalf("Redis连接失败：%s", err)
+				log.Printf("Redis连接失败：%s", err)
 				return nil, err
 			}
 			return conn, nil
@@ -62,11 +70,16 @@ func InitRedis() {
 			}
 			_, err := c.Do("PING")
 			if err != nil {
-				log.Fatalf("ping Redis 失败：%s", err)
+				log.Printf("ping Redis 失败：%s", err)
 			}
 			return err
 		},
 	}
+	// 输出监控指标时读取连接池的连接数
+	metrics.RedisPoolConnections.Set(func() map[string]float64 {
+		stats := pool.Stats()
+		return map[string]float64{"active": float64(stats.ActiveCount), "idle": float64(stats.IdleCount)}
+	})
 	log.Println("Redis连接池创建成功")
 }
 
@@ -80,14 +93,57 @@ func CloseRedis() {
 	}
 }
 
+// 检查Redis是否可用，从连接池获取连接并执行PING，ctx超时后返回错误
+func PingRedis(ctx context.Context) error {
+	conn, err := pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	timeout := time.Second
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+	_, err = redis.DoWithTimeout(conn, timeout, "PING")
+	return err
+}
+
+// 从连接池获取连接，ctx中有跨度时连接执行的每条命令都记录为子跨度
+func getConn(ctx context.Context) redis.Conn {
+	conn := pool.Get()
+	if !trace.SpanFromContext(ctx).IsRecording() {
+		return conn
+	}
+	return tracedConn{Conn: conn, ctx: ctx}
+}
+
+// 记录每条命令的跨度的Redis连接
+type tracedConn struct {
+	redis.Conn
+	ctx context.Context
+}
+
+func (c tracedConn) Do(command string, args ...interface{}) (interface{}, error) {
+	_, span := tracing.StartClient(c.ctx, "redis "+command)
+	span.SetAttributes(
+		attribute.String("db.system", "redis"),
+		attribute.String("db.operation", command),
+	)
+	reply, err := c.Conn.Do(command, args...)
+	tracing.SetError(span, err)
+	span.End()
+	return reply, err
+}
+
 // 增加商品
-func AddItemCache(itemID int64, itemCache models.ItemCache) error {
+func AddItemCache(ctx context.Context, itemID int64, itemCache models.ItemCache) error {
 	itemJson, err := json.Marshal(itemCache)
 	if err != nil {
 		return err
 	}
 	// 从连接池中获取一个连接
-	conn := pool.Get()
+	conn := getConn(ctx)
 	// 用完后将连接放回连接池
 	defer conn.Close()
 	key := namespace + strconv.FormatInt(itemID, 10)
@@ -96,8 +152,8 @@ func AddItemCache(itemID int64, itemCache models.ItemCache) error {
 }
 
 // 获取商品
-func QueryItemCache(itemID int64, itemCache *models.ItemCache) (bool, error) {
-	conn := pool.Get()
+func QueryItemCache(ctx context.Context, itemID int64, itemCache *models.ItemCache) (bool, error) {
+	conn := getConn(ctx)
 	defer conn.Close()
 
 	key := namespace + strconv.FormatInt(itemID, 10)
@@ -116,12 +172,12 @@ func QueryItemCache(itemID int64, itemCache *models.ItemCache) (bool, error) {
 }
 
 // 修改商品
-func UpdateItemCache(itemID int64, itemCache models.ItemCache) error {
+func UpdateItemCache(ctx context.Context, itemID int64, itemCache models.ItemCache) error {
 	itemJson, err := json.Marshal(itemCache)
 	if err != nil {
 		return err
 	}
-	conn := pool.Get()
+	conn := getConn(ctx)
 	defer conn.Close()
 
 	key := namespace + strconv.FormatInt(itemID, 10)
@@ -130,8 +186,8 @@ func UpdateItemCache(itemID int64, itemCache models.ItemCache) error {
 }
 
 // 删除商品
-func DeleteItemCache(itemID int64) error {
-	conn := pool.Get()
+func DeleteItemCache(ctx context.Context, itemID int64) error {
+	conn := getConn(ctx)
 	defer conn.Close()
 
 	key := namespace + strconv.FormatInt(itemID, 10)
@@ -140,10 +196,16 @@ func DeleteItemCache(itemID int64) error {
 }
 
 // Lock 尝试获取分布式锁
-func Lock(key string, requestID string, expireSec uint64, maxWait time.Duration) (bool, error) {
-	conn := pool.Get()
+func Lock(ctx context.Context, key string, requestID string, expireSec uint64, maxWait time.Duration) (locked bool, err error) {
+	// 每次重试的命令记录为锁跨度的子跨度，锁的跨度中记录重试次数
+	ctx, span := tracing.Start(ctx, "lock.acquire")
+	span.SetAttributes(attribute.String("lock.key", key))
+	conn := getConn(ctx)
 	defer conn.Close()
+	attempts := 0
+	defer observeLock(span, time.Now(), &attempts, &locked, &err)
 	for startTime := time.Now(); time.Since(startTime) < maxWait; {
+		attempts++
 		ok, err := SetNx(conn, key, requestID, expireSec)
 		if err != nil {
 			return false, err
@@ -157,8 +219,14 @@ func Lock(key string, requestID string, expireSec uint64, maxWait time.Duration)
 }
 
 // Unlock 释放分布式锁
-func Unlock(key, value string) error {
-	conn := pool.Get()
+func Unlock(ctx context.Context, key, value string) (err error) {
+	ctx, span := tracing.Start(ctx, "lock.release")
+	span.SetAttributes(attribute.String("lock.key", key))
+	defer func() {
+		tracing.SetError(span, err)
+		span.End()
+	}()
+	conn := getConn(ctx)
 	defer conn.Close()
 
 	// 先检查是否是自己的锁
@@ -190,6 +258,10 @@ func Unlock(key, value string) error {
 func SetNx(conn redis.Conn, key string, value string, seconds uint64) (bool, error) {
 	// "EX" 表示过期时间，"NX" 表示只有键不存在时才设置
 	res, err := redis.String(conn.Do("SET", key, value, "EX", seconds, "NX"))
+	if err == redis.ErrNil {
+		// 键已存在时返回nil，说明分布式锁还未被释放
+		return false, nil
+	}
 	if err != nil {
 		// 设置失败，发生错误
 		return false, err
@@ -201,3 +273,249 @@ func SetNx(conn redis.Conn, key string, value string, seconds uint64) (bool, err
 	// 键已存在，说明分布式锁还未被释放
 	return false, nil
 }
+
+// 同时获取多把锁的lua脚本：所有键都不存在时才全部设置，保证要么全部获取要么全部不获取
+var lockMultiScript = redis.NewScript(-1, `
+for _, key in ipairs(KEYS) do
+	if redis.call("EXISTS", key) == 1 then
+		return 0
+	end
+end
+for _, key in ipairs(KEYS) do
+	redis.call("SET", key, ARGV[1], "EX", ARGV[2])
+end
+return 1
+`)
+
+// 释放多把锁的lua脚本：只删除值为自己的锁
+var unlockMultiScript = redis.NewScript(-1, `
+local n = 0
+for _, key in ipairs(KEYS) do
+	if redis.call("GET", key) == ARGV[1] then
+		redis.call("DEL", key)
+		n = n + 1
+	end
+end
+return n
+`)
+
+// LockMulti 尝试同时获取多把分布式锁，每次尝试只需要一次网络往返
+func LockMulti(ctx context.Context, keys []string, requestID string, expireSec uint64, maxWait time.Duration) (locked bool, err error) {
+	ctx, span := tracing.Start(ctx, "lock.acquire")
+	span.SetAttributes(attribute.String("lock.key", strings.Join(keys, ",")))
+	conn := getConn(ctx)
+	defer conn.Close()
+	attempts := 0
+	defer observeLock(span, time.Now(), &attempts, &locked, &err)
+	args := make([]interface{}, 0, len(keys)+3)
+	args = append(args, len(keys))
+	for _, key := range keys {
+		args = append(args, key)
+	}
+	args = append(args, requestID, expireSec)
+	for startTime := time.Now(); time.Since(startTime) < maxWait; {
+		attempts++
+		ok, err := redis.Int(lockMultiScript.Do(conn, args...))
+		if err != nil {
+			return false, err
+		}
+		if ok == 1 {
+			return true, nil
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return false, nil
+}
+
+// 记录获取分布式锁的结果、尝试次数和等待时间，并结束跨度
+func observeLock(span trace.Span, start time.Time, attempts *int, locked *bool, err *error) {
+	result := "acquired"
+	if *err != nil {
+		result = "error"
+	} else if !*locked {
+		result = "timeout"
+		metrics.LockTimeouts.Inc()
+	}
+	metrics.LockAcquires.Inc(result)
+	metrics.LockAttempts.Add(float64(*attempts))
+	metrics.LockWaitDuration.Observe(time.Since(start).Seconds(), result)
+	span.SetAttributes(
+		attribute.String("lock.result", result),
+		attribute.Int("lock.attempts", *attempts),
+	)
+	tracing.SetError(span, *err)
+	span.End()
+}
+
+// UnlockMulti 释放多把分布式锁
+func UnlockMulti(ctx context.Context, keys []string, requestID string) (err error) {
+	ctx, span := tracing.Start(ctx, "lock.release")
+	span.SetAttributes(attribute.String("lock.key", strings.Join(keys, ",")))
+	defer func() {
+		tracing.SetError(span, err)
+		span.End()
+	}()
+	conn := getConn(ctx)
+	defer conn.Close()
+	args := make([]interface{}, 0, len(keys)+2)
+	args = append(args, len(keys))
+	for _, key := range keys {
+		args = append(args, key)
+	}
+	args = append(args, requestID)
+	_, err = unlockMultiScript.Do(conn, args...)
+	return err
+}
+
+// 批量删除商品缓存，使用pipeline一次发送所有命令
+func DeleteItemCaches(ctx context.Context, itemIDs []int64) (err error) {
+	if len(itemIDs) == 0 {
+		return nil
+	}
+	// pipeline中的命令不经过Do，整个pipeline记录为一个跨度
+	_, span := tracing.StartClient(ctx, "redis pipeline")
+	span.SetAttributes(
+		attribute.String("db.system", "redis"),
+		attribute.String("db.operation", "DEL"),
+		attribute.Int("db.redis.commands", len(itemIDs)),
+	)
+	defer func() {
+		tracing.SetError(span, err)
+		span.End()
+	}()
+	conn := getConn(ctx)
+	defer conn.Close()
+	for _, itemID := range itemIDs {
+		key := namespace + strconv.FormatInt(itemID, 10)
+		if err := conn.Send("DEL", key); err != nil {
+			return err
+		}
+	}
+	return receivePipeline(conn, len(itemIDs))
+}
+
+// 发送pipeline中的命令并读取所有回复，返回第一个错误
+func receivePipeline(conn redis.Conn, n int) error {
+	if err := conn.Flush(); err != nil {
+		return err
+	}
+	var firstErr error
+	for i := 0; i < n; i++ {
+		if _, err := conn.Receive(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// 批量获取商品，使用MGET一次获取，返回命中的缓存（item_id -> 缓存）
+func QueryItemCaches(ctx context.Context, itemIDs []int64) (map[int64]models.ItemCache, error) {
+	result := make(map[int64]models.ItemCache, len(itemIDs))
+	if len(itemIDs) == 0 {
+		return result, nil
+	}
+	conn := getConn(ctx)
+	defer conn.Close()
+
+	keys := make([]interface{}, 0, len(itemIDs))
+	for _, itemID := range itemIDs {
+		keys = append(keys, namespace+strconv.FormatInt(itemID, 10))
+	}
+	values, err := redis.ByteSlices(conn.Do("MGET", keys...))
+	if err != nil {
+		return nil, err
+	}
+	for i, itemJson := range values {
+		// 键不存在时返回nil
+		if itemJson == nil {
+			continue
+		}
+		var itemCache models.ItemCache
+		if err := json.Unmarshal(itemJson, &itemCache); err != nil {
+			logger.Printf(ctx, "解析商品%d的Redis缓存失败: %s", itemIDs[i], err)
+			continue
+		}
+		result[itemIDs[i]] = itemCache
+	}
+	return result, nil
+}
+
+// 批量增加商品缓存，使用pipeline一次发送所有命令
+func AddItemCaches(ctx context.Context, itemCaches []models.ItemCache) (err error) {
+	if len(itemCaches) == 0 {
+		return nil
+	}
+	// pipeline中的命令不经过Do，整个pipeline记录为一个跨度
+	_, span := tracing.StartClient(ctx, "redis pipeline")
+	span.SetAttributes(
+		attribute.String("db.system", "redis"),
+		attribute.String("db.operation", "SET"),
+		attribute.Int("db.redis.commands", len(itemCaches)),
+	)
+	defer func() {
+		tracing.SetError(span, err)
+		span.End()
+	}()
+	conn := getConn(ctx)
+	defer conn.Close()
+	for _, itemCache := range itemCaches {
+		itemJson, err := json.Marshal(itemCache)
+		if err != nil {
+			return err
+		}
+		key := namespace + strconv.FormatInt(itemCache.ItemID, 10)
+		if err := conn.Send("SET", key, itemJson, "EX", expireTime); err != nil {
+			return err
+		}
+	}
+	return receivePipeline(conn, len(itemCaches))
+}
+
+// 分类缓存的键
+func categoryCacheKey(categoryID int64) string {
+	return namespace + "category:" + strconv.FormatInt(categoryID, 10)
+}
+
+// 增加分类
+func AddCategoryCache(ctx context.Context, categoryCache models.CategoryCache) error {
+	categoryJson, err := json.Marshal(categoryCache)
+	if err != nil {
+		return err
+	}
+	conn := getConn(ctx)
+	defer conn.Close()
+	_, err = conn.Do("SET", categoryCacheKey(categoryCache.CategoryID), categoryJson, "EX", expireTime)
+	return err
+}
+
+// 获取分类
+func QueryCategoryCache(ctx context.Context, categoryID int64, categoryCache *models.CategoryCache) (bool, error) {
+	conn := getConn(ctx)
+	defer conn.Close()
+	categoryJson, err := redis.Bytes(conn.Do("GET", categoryCacheKey(categoryID)))
+	if err != nil {
+		if err == redis.ErrNil {
+			return false, nil
+		}
+		return false, err
+	}
+	if err := json.Unmarshal(categoryJson, categoryCache); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// 批量删除分类缓存
+func DeleteCategoryCaches(ctx context.Context, categoryIDs []int64) error {
+	if len(categoryIDs) == 0 {
+		return nil
+	}
+	conn := getConn(ctx)
+	defer conn.Close()
+	keys := make([]interface{}, 0, len(categoryIDs))
+	for _, categoryID := range categoryIDs {
+		keys = append(keys, categoryCacheKey(categoryID))
+	}
+	_, err := conn.Do("DEL", keys...)
+	return err
+}
@@ -0,0 +1,114 @@
+package database
+
+import (
+	"context"
+	"miHttpServer/models"
+
+	"xorm.io/xorm"
+)
+
+// 增加SKU并在同一个事务中写入变更历史
+// 商品不存在时返回ErrItemNotFound，编码重复时返回唯一键冲突错误（使用IsDuplicateKey判断）
+func InsertSKU(ctx context.Context, sku *models.ItemSKU, audit models.AuditInfo) error {
+	return Transaction(ctx, func(session *xorm.Session) error {
+		item, err := lockSKUItem(session, sku.ItemID)
+		if err != nil {
+			return err
+		}
+		if _, err := session.Insert(sku); err != nil {
+			return err
+		}
+		return insertAudit(ctx, session, audit, models.AuditSKUAdd, sku.ItemID, skuSnapshot(item, nil), skuSnapshot(item, sku))
+	})
+}
+
+// 修改SKU的编码、条码、价格和规格属性，并在同一个事务中写入变更历史
+// 修改成功后重新查询，sku中为修改后的数据；商品不存在时返回ErrItemNotFound，SKU不存在时返回false
+func UpdateSKU(ctx context.Context, item_id, sku_id int64, sku *models.ItemSKU, audit models.AuditInfo) (bool, error) {
+	var exist bool
+	err := Transaction(ctx, func(session *xorm.Session) error {
+		item, err := lockSKUItem(session, item_id)
+		if err != nil {
+			return err
+		}
+		var before models.ItemSKU
+		exist, err = session.Where("item_id = ? AND sku_id = ?", item_id, sku_id).Get(&before)
+		if err != nil || !exist {
+			return err
+		}
+		_, err = session.Where("item_id = ? AND sku_id = ?", item_id, sku_id).
+			Cols("sku_code", "barcode", "price", "attributes").
+			Update(sku)
+		if err != nil {
+			return err
+		}
+		if _, err := session.Where("item_id = ? AND sku_id = ?", item_id, sku_id).Get(sku); err != nil {
+			return err
+		}
+		return insertAudit(ctx, session, audit, models.AuditSKUUpdate, item_id, skuSnapshot(item, &before), skuSnapshot(item, sku))
+	})
+	return exist, err
+}
+
+// 删除商品的一个SKU，并在同一个事务中写入变更历史
+// 商品不存在时返回ErrItemNotFound，SKU不存在时返回0
+func DeleteSKU(ctx context.Context, item_id, sku_id int64, audit models.AuditInfo) (int64, error) {
+	var n int64
+	err := Transaction(ctx, func(session *xorm.Session) error {
+		item, err := lockSKUItem(session, item_id)
+		if err != nil {
+			return err
+		}
+		var before models.ItemSKU
+		exist, err := session.Where("item_id = ? AND sku_id = ?", item_id, sku_id).Get(&before)
+		if err != nil || !exist {
+			return err
+		}
+		n, err = session.Where("item_id = ? AND sku_id = ?", item_id, sku_id).Delete(&models.ItemSKU{})
+		if err != nil {
+			return err
+		}
+		return insertAudit(ctx, session, audit, models.AuditSKUDelete, item_id, skuSnapshot(item, &before), skuSnapshot(item, nil))
+	})
+	return n, err
+}
+
+// 在事务中给SKU所属的商品加行锁，与修改和删除商品互斥，商品不存在时返回ErrItemNotFound
+func lockSKUItem(session *xorm.Session, item_id int64) (models.Item, error) {
+	var item models.Item
+	exist, err := session.ForUpdate().Where("item_id = ?", item_id).Get(&item)
+	if err != nil {
+		return item, err
+	}
+	if !exist {
+		return item, ErrItemNotFound
+	}
+	return item, nil
+}
+
+// SKU变更历史的快照：商品的快照加上变更的SKU
+// 增加前和删除后的快照只有商品，保证按时间点还原商品时商品仍然存在
+func skuSnapshot(item models.Item, sku *models.ItemSKU) *models.ItemSnapshot {
+	snapshot := models.NewItemSnapshot(item)
+	snapshot.SKU = sku
+	return snapshot
+}
+
+// 在事务中删除商品的全部SKU
+func deleteItemSKUsIn(db xorm.Interface, item_id int64) error {
+	_, err := db.Where("item_id = ?", item_id).Delete(&models.ItemSKU{})
+	return err
+}
+
+// 查询商品的SKU（按sku_id升序），填充到items中
+func loadItemSKUsIn(db xorm.Interface, itemIDs []int64, index map[int64]int, items []models.Item) error {
+	var skus []models.ItemSKU
+	if err := db.In("item_id", itemIDs).OrderBy("sku_id").Find(&skus); err != nil {
+		return err
+	}
+	for _, sku := range skus {
+		item := &items[index[sku.ItemID]]
+		item.SKUs = append(item.SKUs, sku)
+	}
+	return nil
+}
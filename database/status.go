@@ -0,0 +1,129 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"miHttpServer/models"
+	"time"
+
+	"xorm.io/xorm"
+)
+
+// 查询商品在站点的发布状态，没有记录时为草稿
+func QueryItemStatus(ctx context.Context, item_id int64, site string) (string, error) {
+	var status models.ItemStatus
+	exist, err := Engine.Context(ctx).Where("item_id = ? AND site = ?", item_id, site).Get(&status)
+	if err != nil || !exist {
+		return models.StatusDraft, err
+	}
+	return status.Status, nil
+}
+
+// 设置商品在站点的发布状态，并在同一个事务中写入变更历史，状态转换是否允许由调用方检查
+// 变更历史的site为状态所属的站点，用于查询商品在某一时刻的状态
+func SetItemStatus(ctx context.Context, item models.Item, site, from, to string, audit models.AuditInfo) error {
+	return Transaction(ctx, func(session *xorm.Session) error {
+		n, err := session.Where("item_id = ? AND site = ?", item.ItemID, site).
+			Cols("status").
+			Update(&models.ItemStatus{Status: to})
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			if _, err := session.Insert(&models.ItemStatus{ItemID: item.ItemID, Site: site, Status: to}); err != nil {
+				return err
+			}
+		}
+		before, after := models.NewItemSnapshot(item), models.NewItemSnapshot(item)
+		before.Status, after.Status = from, to
+		audit.Site = site
+		return insertAudit(ctx, session, audit, models.AuditStatus, item.ItemID, before, after)
+	})
+}
+
+// 查询商品在某一时刻在站点的发布状态
+// 优先使用该时刻之前最后一次状态变更后的状态；该时刻之后才有状态变更时使用第一次变更前的状态；
+// 没有任何状态变更的记录时（记录状态变更之前设置的状态）使用当前状态
+func QueryItemStatusAsOf(ctx context.Context, item_id int64, site string, asOf time.Time) (string, error) {
+	at := formatDBTime(asOf)
+	var audit models.ItemAudit
+	exist, err := Engine.Context(ctx).Where("item_id = ? AND site = ? AND operation = ? AND created_at <= ?", item_id, site, models.AuditStatus, at).
+		Desc("audit_id").
+		Get(&audit)
+	if err != nil {
+		return "", err
+	}
+	if exist && audit.After != nil {
+		return audit.After.Status, nil
+	}
+	exist, err = Engine.Context(ctx).Where("item_id = ? AND site = ? AND operation = ? AND created_at > ?", item_id, site, models.AuditStatus, at).
+		Asc("audit_id").
+		Get(&audit)
+	if err != nil {
+		return "", err
+	}
+	if exist && audit.Before != nil {
+		return audit.Before.Status, nil
+	}
+	return QueryItemStatus(ctx, item_id, site)
+}
+
+// 在事务中删除商品在所有站点的发布状态
+func deleteItemStatusesIn(db xorm.Interface, item_id int64) error {
+	_, err := db.Where("item_id = ?", item_id).Delete(&models.ItemStatus{})
+	return err
+}
+
+// 查询商品在各站点的发布状态，填充到items中
+func loadItemStatusesIn(db xorm.Interface, itemIDs []int64, index map[int64]int, items []models.Item) error {
+	var statuses []models.ItemStatus
+	if err := db.In("item_id", itemIDs).Find(&statuses); err != nil {
+		return err
+	}
+	for _, status := range statuses {
+		item := &items[index[status.ItemID]]
+		if item.Statuses == nil {
+			item.Statuses = make(map[string]string)
+		}
+		item.Statuses[status.Site] = status.Status
+	}
+	return nil
+}
+
+// 查询商品已发布的站点（item_id -> 站点列表）
+func QueryPublishedSites(ctx context.Context, itemIDs []int64) (map[int64][]string, error) {
+	sites := make(map[int64][]string)
+	if len(itemIDs) == 0 {
+		return sites, nil
+	}
+	var statuses []models.ItemStatus
+	err := Engine.Context(ctx).In("item_id", itemIDs).And("status = ?", models.StatusPublished).Find(&statuses)
+	for _, status := range statuses {
+		sites[status.ItemID] = append(sites[status.ItemID], status.Site)
+	}
+	return sites, err
+}
+
+// 只保留在站点已发布的商品的SQL条件，column为item_id所在的列
+func publishedCondition(column, site string) (string, []interface{}) {
+	condition := fmt.Sprintf("%s IN (SELECT `item_id` FROM `%s` WHERE `site` = ? AND `status` = ?)",
+		column, Engine.TableName(new(models.ItemStatus)))
+	return condition, []interface{}{site, models.StatusPublished}
+}
+
+// 首次创建发布状态表时，已有商品在创建它的站点设为已发布，保持升级前对外可见
+func publishExistingItems() {
+	sql := fmt.Sprintf(
+		"INSERT INTO `%s` (`item_id`, `site`, `status`, `updated_at`) SELECT `item_id`, `site`, ?, NOW() FROM `%s`",
+		Engine.TableName(new(models.ItemStatus)), Engine.TableName(new(models.Item)),
+	)
+	result, err := Engine.Exec(sql, models.StatusPublished)
+	if err != nil {
+		log.Printf("设置已有商品的发布状态失败: %s", err)
+		return
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		log.Printf("已有的%d个商品设为已发布", n)
+	}
+}
@@ -0,0 +1,204 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"miHttpServer/logger"
+	"miHttpServer/models"
+	"time"
+
+	"xorm.io/xorm"
+)
+
+// 查询商品在站点的库存
+func QueryStock(ctx context.Context, item_id int64, site string, stock *models.ItemStock) (bool, error) {
+	return Engine.Context(ctx).Where("item_id = ? AND site = ?", item_id, site).Get(stock)
+}
+
+// 设置商品在站点的可用库存（不影响已预留的数量），商品不存在时返回false
+func SetStock(ctx context.Context, item_id int64, site string, available int64) (models.ItemStock, bool, error) {
+	stock := models.ItemStock{ItemID: item_id, Site: site}
+	var exist bool
+	err := Transaction(ctx, func(session *xorm.Session) error {
+		var err error
+		exist, err = session.Table(new(models.Item)).Where("item_id = ?", item_id).Exist()
+		if err != nil || !exist {
+			return err
+		}
+		found, err := session.ForUpdate().Where("item_id = ? AND site = ?", item_id, site).Get(&stock)
+		if err != nil {
+			return err
+		}
+		stock.Available = available
+		if found {
+			_, err = session.Where("item_id = ? AND site = ?", item_id, site).Cols("available").Update(&stock)
+		} else {
+			_, err = session.Insert(&stock)
+		}
+		return err
+	})
+	if err != nil {
+		logger.Println(ctx, "设置库存失败:", err)
+	}
+	return stock, exist, err
+}
+
+// 预留库存：可用数量足够时在同一个事务中扣减可用数量并写入预留记录
+// 使用带条件的UPDATE（available >= 预留数量）保证并发预留时不会超卖
+// UPDATE关联商品表，会给商品行加共享锁，与删除商品互斥，已删除的商品不能预留（返回ErrItemNotFound）
+// 库存不足时返回false和当前可用数量
+func ReserveStock(ctx context.Context, reservation *models.StockReservation) (bool, int64, error) {
+	var reserved bool
+	var available int64
+	err := Transaction(ctx, func(session *xorm.Session) error {
+		sql := fmt.Sprintf(
+			"UPDATE `%s` AS s JOIN `%s` AS i ON i.`item_id` = s.`item_id` "+
+				"SET s.`available` = s.`available` - ?, s.`reserved` = s.`reserved` + ? "+
+				"WHERE s.`item_id` = ? AND s.`site` = ? AND s.`available` >= ?",
+			Engine.TableName(new(models.ItemStock)), Engine.TableName(new(models.Item)),
+		)
+		result, err := session.Exec(sql, reservation.Quantity, reservation.Quantity,
+			reservation.ItemID, reservation.Site, reservation.Quantity)
+		if err != nil {
+			return err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			exist, err := session.Table(new(models.Item)).Where("item_id = ?", reservation.ItemID).Exist()
+			if err != nil {
+				return err
+			}
+			if !exist {
+				return ErrItemNotFound
+			}
+			// 库存不足（或没有库存记录），查询当前可用数量用于提示
+			var stock models.ItemStock
+			if _, err := session.Where("item_id = ? AND site = ?", reservation.ItemID, reservation.Site).Get(&stock); err != nil {
+				return err
+			}
+			available = stock.Available
+			return nil
+		}
+		reservation.Status = models.ReservationPending
+		if _, err := session.Insert(reservation); err != nil {
+			return err
+		}
+		reserved = true
+		return nil
+	})
+	if err != nil && !errors.Is(err, ErrItemNotFound) {
+		logger.Println(ctx, "预留库存失败:", err)
+	}
+	return reserved, available, err
+}
+
+// 查询预留记录
+func QueryReservation(ctx context.Context, reservationID, site string, reservation *models.StockReservation) (bool, error) {
+	return Engine.Context(ctx).Where("reservation_id = ? AND site = ?", reservationID, site).Get(reservation)
+}
+
+// 将待处理的预留修改为status（confirmed或released），并同步修改库存
+// 预留已过期时改为expired并归还库存；预留不是待处理状态时不做修改
+// 返回修改后的预留记录，调用方根据其状态判断是否修改成功
+func FinishReservation(ctx context.Context, reservationID, site, status string) (models.StockReservation, bool, error) {
+	var reservation models.StockReservation
+	var exist bool
+	err := Transaction(ctx, func(session *xorm.Session) error {
+		var err error
+		exist, err = session.ForUpdate().Where("reservation_id = ? AND site = ?", reservationID, site).Get(&reservation)
+		if err != nil || !exist || reservation.Status != models.ReservationPending {
+			return err
+		}
+		if !reservation.ExpiresAt.After(time.Now()) {
+			status = models.ReservationExpired
+		}
+		return finishReservationIn(session, &reservation, status)
+	})
+	if err != nil {
+		logger.Println(ctx, "处理库存预留失败:", err)
+	}
+	return reservation, exist, err
+}
+
+// 在事务中修改预留状态：确认时扣减预留数量，释放或过期时把预留数量归还到可用数量
+func finishReservationIn(session *xorm.Session, reservation *models.StockReservation, status string) error {
+	reservation.Status = status
+	if _, err := session.ID(reservation.ReservationID).Cols("status").Update(reservation); err != nil {
+		return err
+	}
+	returned := reservation.Quantity
+	if status == models.ReservationConfirmed {
+		returned = 0
+	}
+	sql := fmt.Sprintf(
+		"UPDATE `%s` SET `reserved` = `reserved` - ?, `available` = `available` + ? WHERE `item_id` = ? AND `site` = ?",
+		Engine.TableName(new(models.ItemStock)),
+	)
+	_, err := session.Exec(sql, reservation.Quantity, returned, reservation.ItemID, reservation.Site)
+	return err
+}
+
+// 在事务中删除商品在所有站点的库存和预留记录
+func deleteItemStockIn(db xorm.Interface, item_id int64) error {
+	if _, err := db.Where("item_id = ?", item_id).Delete(&models.ItemStock{}); err != nil {
+		return err
+	}
+	_, err := db.Where("item_id = ?", item_id).Delete(&models.StockReservation{})
+	return err
+}
+
+// 释放已过期的预留，每次最多处理limit条，返回处理的数量
+func ExpireReservations(ctx context.Context, limit int) (int, error) {
+	var reservations []models.StockReservation
+	err := Engine.Context(ctx).Where("status = ? AND expires_at <= ?", models.ReservationPending, formatDBTime(time.Now())).
+		Cols("reservation_id", "site").
+		Limit(limit).
+		Find(&reservations)
+	if err != nil {
+		return 0, err
+	}
+	expired := 0
+	for _, reservation := range reservations {
+		// FinishReservation会重新加锁检查状态，已被确认或释放的预留不会重复处理
+		result, _, err := FinishReservation(ctx, reservation.ReservationID, reservation.Site, models.ReservationExpired)
+		if err != nil {
+			return expired, err
+		}
+		if result.Status == models.ReservationExpired {
+			expired++
+		}
+	}
+	return expired, nil
+}
+
+// 启动后台任务，定期释放过期的预留，返回停止任务的函数（等待正在进行的释放完成后返回）
+func StartReservationSweeper(interval time.Duration) func() {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-ticker.C:
+				n, err := ExpireReservations(context.Background(), 100)
+				if err != nil {
+					log.Println("释放过期的库存预留失败:", err)
+				} else if n > 0 {
+					log.Printf("释放了%d个过期的库存预留", n)
+				}
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
@@ -0,0 +1,178 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"miHttpServer/models"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"xorm.io/xorm"
+)
+
+// 库存测试需要真实的MySQL（条件UPDATE和行锁无法模拟），设置环境变量MI_TEST_MYSQL_DSN后运行，例如
+// MI_TEST_MYSQL_DSN='root:admin@tcp(127.0.0.1:3306)/mi_http_server_test?charset=utf8mb4' go test ./database
+func openTestMySQL(t *testing.T) {
+	t.Helper()
+	dsn := os.Getenv("MI_TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("未设置MI_TEST_MYSQL_DSN，跳过需要MySQL的测试")
+	}
+	var err error
+	Engine, err = xorm.NewEngine("mysql", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Engine.Sync2(tables...); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { Engine.Close() })
+}
+
+// 创建测试用的商品，测试结束后删除
+func insertTestItem(t *testing.T, site string) int64 {
+	t.Helper()
+	item := models.Item{Name: "stock-test-" + uuid.New().String(), Price: 1, Site: site}
+	if _, err := InsertItem(context.Background(), &item, models.AuditInfo{Actor: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { DeleteItem(context.Background(), item.ItemID, models.AuditInfo{Actor: "test"}) })
+	return item.ItemID
+}
+
+// 在同一个事务中读取库存和已确认的预留数量（REPEATABLE READ下两次读取使用同一个快照）
+func readStockSnapshot(item_id int64, site string) (available, reserved, confirmed int64, err error) {
+	session := Engine.NewSession()
+	defer session.Close()
+	if err = session.Begin(); err != nil {
+		return
+	}
+	defer session.Rollback()
+	var stock models.ItemStock
+	if _, err = session.Where("item_id = ? AND site = ?", item_id, site).Get(&stock); err != nil {
+		return
+	}
+	var total float64
+	total, err = session.Where("item_id = ? AND site = ? AND status = ?", item_id, site, models.ReservationConfirmed).
+		Sum(new(models.StockReservation), "quantity")
+	return stock.Available, stock.Reserved, int64(total), err
+}
+
+func TestReserveStockConcurrent(t *testing.T) {
+	openTestMySQL(t)
+	const (
+		site    = "uk"
+		initial = 500
+		workers = 16
+		rounds  = 40
+	)
+	item_id := insertTestItem(t, site)
+	if _, _, err := SetStock(context.Background(), item_id, site, initial); err != nil {
+		t.Fatal(err)
+	}
+
+	// 并发预留、确认和释放期间，可用数量不能为负，可用+预留+已确认的数量保持不变
+	done := make(chan struct{})
+	checkErr := make(chan error, 1)
+	go func() {
+		defer close(checkErr)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			available, reserved, confirmed, err := readStockSnapshot(item_id, site)
+			if err == nil && (available < 0 || reserved < 0 || available+reserved+confirmed != initial) {
+				err = fmt.Errorf("available=%d reserved=%d confirmed=%d，总数应为%d", available, reserved, confirmed, initial)
+			}
+			if err != nil {
+				checkErr <- err
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			random := rand.New(rand.NewSource(seed))
+			for i := 0; i < rounds; i++ {
+				reservation := models.StockReservation{
+					ReservationID: uuid.New().String(),
+					ItemID:        item_id,
+					Site:          site,
+					Quantity:      int64(random.Intn(5) + 1),
+					ExpiresAt:     time.Now().Add(time.Minute),
+				}
+				reserved, _, err := ReserveStock(context.Background(), &reservation)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if !reserved {
+					continue
+				}
+				status := models.ReservationReleased
+				if random.Intn(3) == 0 {
+					status = models.ReservationConfirmed
+				}
+				if _, _, err := FinishReservation(context.Background(), reservation.ReservationID, site, status); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(int64(w))
+	}
+	wg.Wait()
+	close(done)
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if err := <-checkErr; err != nil {
+		t.Error(err)
+	}
+
+	available, reserved, confirmed, err := readStockSnapshot(item_id, site)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if available < 0 || reserved != 0 || available+confirmed != initial {
+		t.Errorf("结束时available=%d reserved=%d confirmed=%d，应为reserved=0且available+confirmed=%d", available, reserved, confirmed, initial)
+	}
+}
+
+func TestReserveStockDeletedItem(t *testing.T) {
+	openTestMySQL(t)
+	item_id := insertTestItem(t, "uk")
+	if _, _, err := SetStock(context.Background(), item_id, "uk", 10); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DeleteItem(context.Background(), item_id, models.AuditInfo{Actor: "test"}); err != nil {
+		t.Fatal(err)
+	}
+
+	reservation := models.StockReservation{
+		ReservationID: uuid.New().String(),
+		ItemID:        item_id,
+		Site:          "uk",
+		Quantity:      1,
+		ExpiresAt:     time.Now().Add(time.Minute),
+	}
+	if _, _, err := ReserveStock(context.Background(), &reservation); !errors.Is(err, ErrItemNotFound) {
+		t.Errorf("预留已删除商品的库存返回%v，应为ErrItemNotFound", err)
+	}
+	var stock models.ItemStock
+	if exist, err := QueryStock(context.Background(), item_id, "uk", &stock); err != nil || exist {
+		t.Errorf("删除商品后库存记录仍然存在: %v", err)
+	}
+}
@@ -0,0 +1,450 @@
+package handlers
+
+import (
+	"fmt"
+	"miHttpServer/apperror"
+	"miHttpServer/caches"
+	"miHttpServer/config"
+	"miHttpServer/database"
+	"miHttpServer/i18n"
+	"miHttpServer/logger"
+	"miHttpServer/models"
+	"miHttpServer/search"
+	"miHttpServer/utils"
+	"miHttpServer/validation"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"xorm.io/xorm"
+)
+
+// 商品集合的自定义方法，例如 POST /:app_local/items:batch
+// gin会把items后面的":batch"解析为路径参数method
+func ItemsMethod(ctx *gin.Context) {
+	switch ctx.Param("method") {
+	case ":batch":
+		BatchItems(ctx)
+	default:
+		utils.RespondError(ctx, apperror.Request(
+			http.StatusNotFound,
+			i18n.RouteNotFound,
+			i18n.NewError(i18n.CheckURL),
+		))
+	}
+}
+
+// 批量增加/修改/删除商品信息
+// atomic为true时所有操作在同一个事务中执行，否则逐个执行并返回每个操作的结果
+func BatchItems(ctx *gin.Context) {
+	data, err := ctx.GetRawData()
+	if err != nil {
+		utils.RespondError(ctx, apperror.InvalidJSON(err))
+		return
+	}
+	request, err := validation.DecodeBatch(data)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+
+	// 先校验所有操作
+	operations := request.Operations
+	results := make([]models.BatchResult, len(operations))
+	valid := make([]bool, len(operations))
+	var invalidFields []apperror.FieldError
+	for i, op := range operations {
+		results[i] = models.BatchResult{Index: i, Op: op.Op, ItemID: op.ItemID}
+		err := validation.ValidateBatchOperation(op)
+		if err != nil {
+			if request.Atomic {
+				appErr := apperror.From(validation.PrefixFields(err, fmt.Sprintf("operations[%d]", i)))
+				invalidFields = append(invalidFields, appErr.Fields...)
+			} else {
+				results[i] = utils.DealBatchError(ctx, results[i], err)
+			}
+			continue
+		}
+		valid[i] = true
+	}
+	if len(invalidFields) > 0 {
+		utils.RespondError(ctx, apperror.InvalidFields(invalidFields))
+		return
+	}
+
+	// 一次性获取所有操作需要的分布式锁
+	// 修改操作的名称唯一键按商品保存的站点计算（与UpdateItem相同），先查询这些商品的站点
+	site := ctx.Param("app_local")
+	var updateIDs []int64
+	for i, op := range operations {
+		if valid[i] && op.Op == models.BatchUpdate {
+			updateIDs = append(updateIDs, op.ItemID)
+		}
+	}
+	itemSites, err := database.QueryItemSites(ctx.Request.Context(), updateIDs)
+	if err != nil {
+		utils.RespondError(ctx, apperror.Internal(i18n.QueryFailed, err))
+		return
+	}
+	unlock, err := lockAll(ctx, batchLockKeys(operations, valid, site, itemSites))
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	// 确保最后释放锁
+	defer unlock()
+
+	deleteTime, localCountry := siteLocalTime(site)
+	audit := auditInfo(ctx)
+	if request.Atomic {
+		err = database.Transaction(ctx.Request.Context(), func(session *xorm.Session) error {
+			for i, op := range operations {
+				result, err := execBatchOperation(ctx, session, op, site, deleteTime, audit)
+				if err != nil {
+					return indexedError(i, err)
+				}
+				result.Index = i
+				results[i] = result
+			}
+			return nil
+		})
+		if err != nil {
+			utils.RespondError(ctx, err)
+			return
+		}
+	} else {
+		for i, op := range operations {
+			if !valid[i] {
+				continue
+			}
+			// 每个操作单独使用一个事务，保证商品和变更历史同时写入
+			var result models.BatchResult
+			err := database.Transaction(ctx.Request.Context(), func(session *xorm.Session) error {
+				var err error
+				result, err = execBatchOperation(ctx, session, op, site, deleteTime, audit)
+				return err
+			})
+			result.Index = i
+			if err != nil {
+				result = utils.DealBatchError(ctx, result, err)
+			}
+			results[i] = result
+		}
+	}
+
+	succeeded := 0
+	for _, result := range results {
+		if result.Success {
+			succeeded++
+		}
+	}
+	batchInfo := make(map[string]interface{})
+	batchInfo["results"] = results
+	batchInfo["succeeded"] = succeeded
+	batchInfo["failed"] = len(results) - succeeded
+	response := utils.DealSuccess(ctx, batchInfo)
+	ctx.JSON(http.StatusOK, response)
+	logger.Printf(ctx, "%s站点批量操作商品，成功%d个，失败%d个", localCountry, succeeded, len(results)-succeeded)
+
+	syncBatchCaches(ctx, operations, results, deleteTime)
+	syncBatchSearchIndex(ctx, operations, results)
+}
+
+// 在事务中执行单个批量操作
+func execBatchOperation(ctx *gin.Context, db *xorm.Session, op models.BatchOperation, site, deleteTime string, audit models.AuditInfo) (models.BatchResult, error) {
+	result := models.BatchResult{Op: op.Op, ItemID: op.ItemID}
+	switch op.Op {
+	case models.BatchCreate:
+		item := models.Item{Name: op.Name, Price: op.Price, Site: site}
+		_, err := database.InsertItemIn(ctx.Request.Context(), db, &item, audit)
+		if database.IsDuplicateKey(err) {
+			return result, duplicateNameError(db, site, op.Name)
+		}
+		if err != nil {
+			return result, apperror.Internal(i18n.InsertFailed, err)
+		}
+		result.ItemID = item.ItemID
+		result.Data = map[string]interface{}{
+			"item_info": map[string]interface{}{
+				"item_id": item.ItemID,
+				"name":    item.Name,
+				"price":   item.Price,
+			},
+		}
+	case models.BatchUpdate:
+		item := models.Item{ItemID: op.ItemID, Name: op.Name, Price: op.Price}
+		n, err := database.UpdateItemIn(ctx.Request.Context(), db, op.ItemID, &item, audit)
+		if database.IsDuplicateKey(err) {
+			return result, duplicateNameError(db, item.Site, op.Name)
+		}
+		if err != nil {
+			return result, apperror.Internal(i18n.UpdateFailed, err)
+		}
+		if n == 0 {
+			return result, apperror.ItemNotFound(op.ItemID)
+		}
+		result.Data = map[string]interface{}{
+			"store_info": map[string]interface{}{
+				"item_id": item.ItemID,
+				"name":    item.Name,
+				"price":   item.Price,
+			},
+		}
+	case models.BatchDelete:
+		// 已经删除过的商品直接返回删除时间，保证幂等性
+		if deleteItemTime, exist := models.ItemDeleteTime[op.ItemID]; exist {
+			deleteTime = deleteItemTime
+		} else {
+			n, err := database.DeleteItemIn(ctx.Request.Context(), db, op.ItemID, audit)
+			if err != nil {
+				return result, apperror.Internal(i18n.DeleteFailed, err)
+			}
+			if n == 0 {
+				return result, apperror.ItemNotFound(op.ItemID)
+			}
+		}
+		result.Data = map[string]interface{}{"delete_time": deleteTime}
+	}
+	result.Success = true
+	return result, nil
+}
+
+// 给事务中失败的操作加上下标，方便客户端定位
+func indexedError(index int, err error) error {
+	appErr := apperror.From(err)
+	if appErr.Kind == apperror.KindServer {
+		return appErr
+	}
+	indexed := *appErr
+	indexed.Fields = []apperror.FieldError{{
+		Field:  fmt.Sprintf("operations[%d]", index),
+		Code:   appErr.Code,
+		Detail: appErr.Detail,
+	}}
+	return &indexed
+}
+
+// 批量操作需要获取的锁：增加和修改按商品名称加锁，修改和删除按item_id加锁
+// 增加使用请求的站点，修改使用商品保存的站点（itemSites中没有的商品不存在，修改时会返回404）
+// 排序去重后一次性获取
+func batchLockKeys(operations []models.BatchOperation, valid []bool, site string, itemSites map[int64]string) []string {
+	set := make(map[string]struct{})
+	for i, op := range operations {
+		if !valid[i] {
+			continue
+		}
+		if op.Op != models.BatchDelete {
+			nameSite := site
+			if itemSite, ok := itemSites[op.ItemID]; ok && op.Op == models.BatchUpdate {
+				nameSite = itemSite
+			}
+			set[itemNameLockKey(nameSite, op.Name)] = struct{}{}
+		}
+		if op.Op != models.BatchCreate {
+			set[itemIDLockKey(op.ItemID)] = struct{}{}
+		}
+	}
+	lockKeys := make([]string, 0, len(set))
+	for key := range set {
+		lockKeys = append(lockKeys, key)
+	}
+	sort.Strings(lockKeys)
+	return lockKeys
+}
+
+// 批量同步本地缓存和redis缓存，redis使用pipeline
+// 批量修改的请求中没有属性和标签，缓存中可能保存了旧的属性和标签，因此修改和删除都直接删除缓存
+func syncBatchCaches(ctx *gin.Context, operations []models.BatchOperation, results []models.BatchResult, deleteTime string) {
+	var invalidIDs []int64
+	for i, op := range operations {
+		if !results[i].Success {
+			continue
+		}
+		switch op.Op {
+		case models.BatchUpdate:
+			invalidIDs = append(invalidIDs, op.ItemID)
+			caches.DeleteLocalCache(op.ItemID)
+		case models.BatchDelete:
+			if _, exist := models.ItemDeleteTime[op.ItemID]; exist {
+				continue
+			}
+			models.ItemDeleteTime[op.ItemID] = deleteTime
+			invalidIDs = append(invalidIDs, op.ItemID)
+			caches.DeleteLocalCache(op.ItemID)
+		}
+	}
+	if err := caches.DeleteRedisCaches(ctx.Request.Context(), invalidIDs); err != nil {
+		logger.Printf(ctx, "批量删除商品的Redis缓存失败: %s", err.Error())
+	}
+}
+
+// 批量操作成功后更新搜索索引，被删除的商品同时删除媒体文件
+func syncBatchSearchIndex(ctx *gin.Context, operations []models.BatchOperation, results []models.BatchResult) {
+	for i, op := range operations {
+		if !results[i].Success {
+			continue
+		}
+		if op.Op == models.BatchDelete {
+			search.RemoveItem(op.ItemID)
+			removeItemMediaBlobs(ctx, op.ItemID)
+		} else {
+			search.IndexItem(models.Item{ItemID: results[i].ItemID, Name: op.Name, Price: op.Price})
+		}
+	}
+}
+
+// 批量查询商品信息（GET /:app_local/items?ids=1,2,3）
+// 依次查询本地缓存、redis（MGET）和MySQL（IN查询），结果按请求顺序返回
+// 没有ids参数时按属性和标签筛选商品列表
+func BatchQueryItems(ctx *gin.Context) {
+	if _, ok := ctx.GetQuery("ids"); !ok {
+		ListItems(ctx)
+		return
+	}
+	itemIDs, err := parseItemIDs(ctx)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+
+	// 去重，重复的item_id只查询一次
+	seen := make(map[int64]struct{}, len(itemIDs))
+	uniqueIDs := make([]int64, 0, len(itemIDs))
+	for _, itemID := range itemIDs {
+		if _, ok := seen[itemID]; !ok {
+			seen[itemID] = struct{}{}
+			uniqueIDs = append(uniqueIDs, itemID)
+		}
+	}
+
+	found, backfill, err := queryItemCaches(ctx, uniqueIDs)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	// 响应之后再回填redis缓存
+	defer backfill()
+
+	// 按请求顺序组装结果，不存在的商品标记found为false
+	itemsInfo := make([]map[string]interface{}, 0, len(itemIDs))
+	for _, itemID := range itemIDs {
+		itemCache, ok := found[itemID]
+		// 对调用方不可见的商品和不存在的商品一样处理
+		if !ok || !itemVisible(ctx, itemCache) {
+			itemsInfo = append(itemsInfo, map[string]interface{}{
+				"item_id": itemID,
+				"found":   false,
+			})
+			continue
+		}
+		itemsInfo = append(itemsInfo, map[string]interface{}{
+			"item_id":    itemID,
+			"found":      true,
+			"store_info": itemCache.StoreInfo(),
+		})
+	}
+	response := utils.DealSuccess(ctx, map[string]interface{}{"items": itemsInfo})
+	ctx.JSON(http.StatusOK, response)
+}
+
+// 批量查询商品，依次查询本地缓存、redis（MGET）和MySQL（IN查询），返回找到的商品
+// 从MySQL查询到的商品会立即回填本地缓存，回填redis缓存的函数由调用方在响应之后调用
+func queryItemCaches(ctx *gin.Context, itemIDs []int64) (map[int64]models.ItemCache, func(), error) {
+	backfill := func() {}
+
+	// 从本地缓存中查询数据
+	found, missing := caches.QueryLocalCaches(ctx.Request.Context(), itemIDs)
+
+	// 从redis缓存中查询剩余数据，并添加到本地缓存中
+	if len(missing) > 0 {
+		redisFound, err := caches.QueryRedisCaches(ctx.Request.Context(), missing)
+		if err != nil {
+			logger.Printf(ctx, "批量查询商品的Redis缓存失败: %s", err.Error())
+		} else {
+			remaining := missing[:0]
+			for _, itemID := range missing {
+				if itemCache, ok := redisFound[itemID]; ok {
+					found[itemID] = itemCache
+					caches.AddLocalCache(itemID, itemCache)
+				} else {
+					remaining = append(remaining, itemID)
+				}
+			}
+			missing = remaining
+		}
+	}
+
+	// 从MySQL查询剩余数据，并回填本地缓存和redis缓存
+	if len(missing) > 0 {
+		items, err := database.QueryItems(ctx.Request.Context(), missing)
+		if err != nil {
+			return nil, nil, apperror.Internal(i18n.QueryFailed, err)
+		}
+		for _, item := range items {
+			itemCache := models.NewItemCache(item)
+			found[item.ItemID] = itemCache
+			caches.AddLocalCache(item.ItemID, itemCache)
+		}
+		backfill = func() {
+			if err := caches.AddRedisCaches(ctx.Request.Context(), items); err != nil {
+				logger.Printf(ctx, "批量增加商品的Redis缓存失败: %s", err.Error())
+			}
+		}
+	}
+	return found, backfill, nil
+}
+
+// 分页查询商品列表，支持按属性和标签筛选
+// 例如 GET /:app_local/items?attr.color=red&attr.size=XL&tag=sale&tag=new&page=1&page_size=20
+// 多个条件需要同时满足，商品信息依次从本地缓存、redis和MySQL获取
+func ListItems(ctx *gin.Context) {
+	// 每页最多返回的数量与批量查询的item_id数量限制相同
+	maxPageSize := config.Configs.Batch.MaxQueryIDs
+	if maxPageSize <= 0 {
+		maxPageSize = 100
+	}
+	page, pageSize, err := parsePagination(ctx, min(20, maxPageSize), maxPageSize)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	filter := models.ItemFilter{
+		Attributes: make(map[string]string),
+		Tags:       validation.NormalizeTags(ctx.QueryArray("tag")),
+		// 非管理员只能看到在当前站点已发布的商品
+		PublishedSite: publishedSiteFilter(ctx),
+	}
+	for key, values := range ctx.Request.URL.Query() {
+		if attribute, ok := strings.CutPrefix(key, "attr."); ok && len(values) > 0 {
+			filter.Attributes[attribute] = values[0]
+		}
+	}
+
+	itemIDs, total, err := database.ListItemIDs(ctx.Request.Context(), filter, (page-1)*pageSize, pageSize)
+	if err != nil {
+		utils.RespondError(ctx, apperror.Internal(i18n.QueryFailed, err))
+		return
+	}
+	found, backfill, err := queryItemCaches(ctx, itemIDs)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	// 响应之后再回填redis缓存
+	defer backfill()
+
+	itemsInfo := make([]map[string]interface{}, 0, len(itemIDs))
+	for _, itemID := range itemIDs {
+		if itemCache, ok := found[itemID]; ok {
+			itemsInfo = append(itemsInfo, itemCache.StoreInfo())
+		}
+	}
+	listInfo := make(map[string]interface{})
+	listInfo["items"] = itemsInfo
+	listInfo["page"] = page
+	listInfo["page_size"] = pageSize
+	listInfo["total"] = total
+	response := utils.DealSuccess(ctx, listInfo)
+	ctx.JSON(http.StatusOK, response)
+}
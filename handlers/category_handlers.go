@@ -0,0 +1,400 @@
+package handlers
+
+import (
+	"errors"
+	"miHttpServer/apperror"
+	"miHttpServer/caches"
+	"miHttpServer/config"
+	"miHttpServer/database"
+	"miHttpServer/i18n"
+	"miHttpServer/logger"
+	"miHttpServer/models"
+	"miHttpServer/utils"
+	"miHttpServer/validation"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 分类树的分布式锁，增加、移动和删除分类时获取，防止并发移动形成环
+const categoryTreeLockKey = "category_lock_tree"
+
+// 增加分类
+func AddCategory(ctx *gin.Context) {
+	request, err := bindCategoryRequest(ctx)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+
+	unlock, err := lock(ctx, categoryTreeLockKey)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	// 确保最后释放锁
+	defer unlock()
+
+	category := models.Category{Name: request.Name, ParentID: request.ParentID}
+	err = database.InsertCategory(ctx.Request.Context(), &category)
+	if err != nil {
+		utils.RespondError(ctx, categoryError(err, category.CategoryID, request.ParentID, apperror.Internal(i18n.InsertFailed, err)))
+		return
+	}
+	response := utils.DealSuccess(ctx, map[string]interface{}{"category_info": models.NewCategoryCache(category)})
+	ctx.JSON(http.StatusOK, response)
+	logger.Printf(ctx, "增加分类，category_id: %d，name：%s，path：%s", category.CategoryID, category.Name, category.Path)
+}
+
+// 查询分类信息和直接子分类（分类信息先查询缓存，未命中再查询MySQL）
+func QueryCategory(ctx *gin.Context) {
+	category_id, err := parseCategoryID(ctx)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	category, err := queryCategory(ctx, category_id)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	children, err := database.QueryChildCategories(ctx.Request.Context(), category_id)
+	if err != nil {
+		utils.RespondError(ctx, apperror.Internal(i18n.QueryFailed, err))
+		return
+	}
+	childrenInfo := make([]models.CategoryCache, 0, len(children))
+	for _, child := range children {
+		childrenInfo = append(childrenInfo, models.NewCategoryCache(child))
+	}
+	categoryInfo := make(map[string]interface{})
+	categoryInfo["category_info"] = category
+	categoryInfo["children"] = childrenInfo
+	response := utils.DealSuccess(ctx, categoryInfo)
+	ctx.JSON(http.StatusOK, response)
+}
+
+// 查询完整的分类树（GET /:app_local/categories）
+func QueryCategoryTree(ctx *gin.Context) {
+	categories, err := database.QueryAllCategories(ctx.Request.Context())
+	if err != nil {
+		utils.RespondError(ctx, apperror.Internal(i18n.QueryFailed, err))
+		return
+	}
+	// 按路径排序后父分类一定在子分类之前，依次挂到父节点下
+	nodes := make(map[int64]map[string]interface{}, len(categories))
+	roots := make([]map[string]interface{}, 0)
+	for _, category := range categories {
+		node := map[string]interface{}{
+			"category_id": category.CategoryID,
+			"parent_id":   category.ParentID,
+			"name":        category.Name,
+			"path":        category.Path,
+			"children":    []map[string]interface{}{},
+		}
+		nodes[category.CategoryID] = node
+		if parent, ok := nodes[category.ParentID]; ok {
+			parent["children"] = append(parent["children"].([]map[string]interface{}), node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+	response := utils.DealSuccess(ctx, map[string]interface{}{"categories": roots})
+	ctx.JSON(http.StatusOK, response)
+}
+
+// 修改分类的名称和父分类，移动分类时所有子孙分类随之移动
+func UpdateCategory(ctx *gin.Context) {
+	category_id, err := parseCategoryID(ctx)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	request, err := bindCategoryRequest(ctx)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+
+	unlock, err := lock(ctx, categoryTreeLockKey)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	// 确保最后释放锁
+	defer unlock()
+
+	category, movedIDs, err := database.UpdateCategory(ctx.Request.Context(), category_id, request.Name, request.ParentID)
+	if err != nil {
+		utils.RespondError(ctx, categoryError(err, category_id, request.ParentID, apperror.Internal(i18n.UpdateFailed, err)))
+		return
+	}
+	response := utils.DealSuccess(ctx, map[string]interface{}{"category_info": models.NewCategoryCache(category)})
+	ctx.JSON(http.StatusOK, response)
+	logger.Printf(ctx, "修改分类，category_id: %d，name：%s，path：%s", category.CategoryID, category.Name, category.Path)
+
+	// 分类和路径发生变化的子孙分类都删除缓存
+	if err := caches.DeleteCategoryCaches(ctx.Request.Context(), append(movedIDs, category_id)); err != nil {
+		logger.Printf(ctx, "删除分类%d的Redis缓存失败: %s", category_id, err.Error())
+	}
+}
+
+// 删除分类，有子分类时不能删除
+func DeleteCategory(ctx *gin.Context) {
+	category_id, err := parseCategoryID(ctx)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+
+	unlock, err := lock(ctx, categoryTreeLockKey)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	// 确保最后释放锁
+	defer unlock()
+
+	exist, err := database.DeleteCategory(ctx.Request.Context(), category_id)
+	if err != nil {
+		utils.RespondError(ctx, categoryError(err, category_id, 0, apperror.Internal(i18n.DeleteFailed, err)))
+		return
+	}
+	if !exist {
+		utils.RespondError(ctx, apperror.CategoryNotFound(category_id))
+		return
+	}
+	response := utils.DealSuccess(ctx, map[string]interface{}{"category_id": category_id})
+	ctx.JSON(http.StatusOK, response)
+	logger.Printf(ctx, "删除分类，category_id: %d", category_id)
+
+	if err := caches.DeleteCategoryCaches(ctx.Request.Context(), []int64{category_id}); err != nil {
+		logger.Printf(ctx, "删除分类%d的Redis缓存失败: %s", category_id, err.Error())
+	}
+}
+
+// 分页查询分类下的商品（GET /:app_local/category/:category_id/items?include_descendants=true）
+// 默认包含所有子孙分类下的商品，商品信息依次从本地缓存、redis和MySQL获取
+func QueryCategoryItems(ctx *gin.Context) {
+	category_id, err := parseCategoryID(ctx)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	includeDescendants, err := strconv.ParseBool(ctx.DefaultQuery("include_descendants", "true"))
+	if err != nil {
+		utils.RespondError(ctx, apperror.InvalidFields([]apperror.FieldError{{
+			Field:  "include_descendants",
+			Code:   i18n.FieldInvalidType,
+			Detail: i18n.NewError(i18n.FieldInvalidType, "bool"),
+		}}))
+		return
+	}
+	page, pageSize, err := parsePagination(ctx, config.Configs.Category.DefaultPageSize, config.Configs.Category.MaxPageSize)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	category, err := queryCategory(ctx, category_id)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+
+	itemIDs, total, err := database.QueryCategoryItemIDs(ctx.Request.Context(), models.Category{CategoryID: category.CategoryID, Path: category.Path},
+		includeDescendants, publishedSiteFilter(ctx), (page-1)*pageSize, pageSize)
+	if err != nil {
+		utils.RespondError(ctx, apperror.Internal(i18n.QueryFailed, err))
+		return
+	}
+	found, backfill, err := queryItemCaches(ctx, itemIDs)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	// 响应之后再回填redis缓存
+	defer backfill()
+
+	itemsInfo := make([]map[string]interface{}, 0, len(itemIDs))
+	for _, itemID := range itemIDs {
+		if itemCache, ok := found[itemID]; ok {
+			itemsInfo = append(itemsInfo, itemCache.StoreInfo())
+		}
+	}
+	categoryItems := make(map[string]interface{})
+	categoryItems["category_info"] = category
+	categoryItems["items"] = itemsInfo
+	categoryItems["page"] = page
+	categoryItems["page_size"] = pageSize
+	categoryItems["total"] = total
+	response := utils.DealSuccess(ctx, categoryItems)
+	ctx.JSON(http.StatusOK, response)
+}
+
+// 查询商品所属的分类（GET /:app_local/item/:item_id/categories）
+func QueryItemCategories(ctx *gin.Context) {
+	item_id, err := parseItemID(ctx)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	if _, err := queryVisibleItem(ctx, item_id); err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	categories, err := database.QueryItemCategories(ctx.Request.Context(), item_id)
+	if err != nil {
+		utils.RespondError(ctx, apperror.Internal(i18n.QueryFailed, err))
+		return
+	}
+	categoriesInfo := make([]models.CategoryCache, 0, len(categories))
+	for _, category := range categories {
+		categoriesInfo = append(categoriesInfo, models.NewCategoryCache(category))
+	}
+	itemCategories := make(map[string]interface{})
+	itemCategories["item_id"] = item_id
+	itemCategories["categories"] = categoriesInfo
+	response := utils.DealSuccess(ctx, itemCategories)
+	ctx.JSON(http.StatusOK, response)
+}
+
+// 设置商品所属的分类（PUT /:app_local/item/:item_id/categories），全量替换
+func SetItemCategories(ctx *gin.Context) {
+	item_id, err := parseItemID(ctx)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	data, err := ctx.GetRawData()
+	if err != nil {
+		utils.RespondError(ctx, apperror.InvalidJSON(err))
+		return
+	}
+	request, err := validation.DecodeItemCategories(data)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	if _, err := queryVisibleItem(ctx, item_id); err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+
+	// 按item_id获取分布式锁，防止并发修改同一商品
+	unlock, err := lockItemID(ctx, item_id)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	// 确保最后释放锁
+	defer unlock()
+
+	exist, missing, err := database.SetItemCategories(ctx.Request.Context(), item_id, request.CategoryIDs)
+	if err != nil {
+		utils.RespondError(ctx, apperror.Internal(i18n.UpdateFailed, err))
+		return
+	}
+	if !exist {
+		utils.RespondError(ctx, apperror.ItemNotFound(item_id))
+		return
+	}
+	if len(missing) > 0 {
+		fields := make([]apperror.FieldError, 0, len(missing))
+		for _, categoryID := range missing {
+			fields = append(fields, apperror.FieldError{
+				Field:  "category_ids",
+				Code:   i18n.CategoryNotFound,
+				Detail: i18n.NewError(i18n.CategoryNotExist, categoryID),
+			})
+		}
+		utils.RespondError(ctx, apperror.InvalidFields(fields))
+		return
+	}
+	itemCategories := make(map[string]interface{})
+	itemCategories["item_id"] = item_id
+	itemCategories["category_ids"] = request.CategoryIDs
+	response := utils.DealSuccess(ctx, itemCategories)
+	ctx.JSON(http.StatusOK, response)
+	logger.Printf(ctx, "设置商品分类，item_id: %d，category_ids：%v", item_id, request.CategoryIDs)
+}
+
+// 查询分类信息（先查询本地缓存，再查询redis，最后查询MySQL并回填缓存）
+func queryCategory(ctx *gin.Context, category_id int64) (models.CategoryCache, error) {
+	if category, ok := caches.QueryLocalCategory(category_id); ok {
+		return category, nil
+	}
+	category, ok, err := caches.QueryRedisCategory(ctx.Request.Context(), category_id)
+	if err != nil {
+		logger.Printf(ctx, "查询分类%d的Redis缓存失败: %s", category_id, err.Error())
+	} else if ok {
+		caches.AddLocalCategory(category)
+		return category, nil
+	}
+
+	var row models.Category
+	exist, err := database.QueryCategory(ctx.Request.Context(), category_id, &row)
+	if err != nil {
+		return category, apperror.Internal(i18n.QueryFailed, err)
+	}
+	if !exist {
+		return category, apperror.CategoryNotFound(category_id)
+	}
+	category = models.NewCategoryCache(row)
+	caches.AddLocalCategory(category)
+	if err := caches.AddRedisCategory(ctx.Request.Context(), row); err != nil {
+		logger.Printf(ctx, "增加分类%d的Redis缓存失败: %s", category_id, err.Error())
+	}
+	return category, nil
+}
+
+// 解析路径参数中的category_id
+func parseCategoryID(ctx *gin.Context) (int64, error) {
+	categoryID, err := strconv.ParseInt(ctx.Param("category_id"), 10, 64)
+	if err != nil {
+		return 0, apperror.InvalidFields([]apperror.FieldError{{
+			Field:  "category_id",
+			Code:   i18n.FieldInvalidType,
+			Detail: i18n.NewError(i18n.FieldInvalidType, "int64"),
+		}})
+	}
+	return categoryID, nil
+}
+
+// 解析并校验增加和修改分类时请求的json
+func bindCategoryRequest(ctx *gin.Context) (models.CategoryRequest, error) {
+	data, err := ctx.GetRawData()
+	if err != nil {
+		return models.CategoryRequest{}, apperror.InvalidJSON(err)
+	}
+	return validation.DecodeCategory(data)
+}
+
+// 将分类操作的业务错误转换为对应的响应，其他错误返回fallback
+func categoryError(err error, category_id, parent_id int64, fallback error) error {
+	switch {
+	case errors.Is(err, database.ErrCategoryNotFound):
+		return apperror.CategoryNotFound(category_id)
+	case errors.Is(err, database.ErrParentCategoryNotFound):
+		return apperror.InvalidFields([]apperror.FieldError{{
+			Field:  "parent_id",
+			Code:   i18n.CategoryNotFound,
+			Detail: i18n.NewError(i18n.CategoryNotExist, parent_id),
+		}})
+	case errors.Is(err, database.ErrCategoryCycle):
+		return apperror.InvalidFields([]apperror.FieldError{{
+			Field:  "parent_id",
+			Code:   i18n.CategoryCycle,
+			Detail: i18n.NewError(i18n.CategoryCycle),
+		}})
+	case errors.Is(err, database.ErrCategoryTooDeep):
+		return apperror.InvalidFields([]apperror.FieldError{{
+			Field:  "parent_id",
+			Code:   i18n.CategoryTooDeep,
+			Detail: i18n.NewError(i18n.CategoryTooDeep, database.MaxCategoryPathLength),
+		}})
+	case errors.Is(err, database.ErrCategoryNotEmpty):
+		return apperror.CategoryNotEmpty(category_id)
+	}
+	return fallback
+}
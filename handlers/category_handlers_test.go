@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"miHttpServer/database"
+	"miHttpServer/i18n"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var categoryColumns = []string{"category_id", "parent_id", "name", "path"}
+
+// 移动分类时先锁定分类和新的父分类
+func expectCategoryMove(categoryRow, parentRow []driver.Value) {
+	testSQL.ExpectBegin()
+	testSQL.ExpectQuery("SELECT .* FROM `category` WHERE .*FOR UPDATE").
+		WithArgs(categoryRow[0]).
+		WillReturnRows(sqlmock.NewRows(categoryColumns).AddRow(categoryRow...))
+	testSQL.ExpectQuery("SELECT .* FROM `category` WHERE .*FOR UPDATE").
+		WithArgs(parentRow[0]).
+		WillReturnRows(sqlmock.NewRows(categoryColumns).AddRow(parentRow...))
+}
+
+func TestUpdateCategoryMove(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		parentRow  []driver.Value
+		children   *sqlmock.Rows
+		wantStatus int
+		wantField  string
+		wantUpdate bool
+	}{
+		{
+			name:       "移动到其他分类下",
+			body:       `{"name": "b", "parent_id": 2}`,
+			parentRow:  []driver.Value{int64(2), int64(0), "p", "/2/"},
+			children:   sqlmock.NewRows([]string{"category_id", "path"}).AddRow(int64(6), "/1/5/6/"),
+			wantStatus: http.StatusOK,
+			wantUpdate: true,
+		},
+		{
+			name:       "移动到自己的子分类下",
+			body:       `{"name": "b", "parent_id": 6}`,
+			parentRow:  []driver.Value{int64(6), int64(5), "c", "/1/5/6/"},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantField:  i18n.CategoryCycle,
+		},
+		{
+			name:       "移动后子孙分类的路径过长",
+			body:       `{"name": "b", "parent_id": 2}`,
+			parentRow:  []driver.Value{int64(2), int64(0), "p", "/2/" + strings.Repeat("9/", (database.MaxCategoryPathLength-len("/2/5/"))/2)},
+			children:   sqlmock.NewRows([]string{"category_id", "path"}).AddRow(int64(6), "/1/5/6/"),
+			wantStatus: http.StatusUnprocessableEntity,
+			wantField:  i18n.CategoryTooDeep,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expectCategoryMove([]driver.Value{int64(5), int64(1), "a", "/1/5/"}, tt.parentRow)
+			if tt.children != nil {
+				testSQL.ExpectQuery("SELECT .* FROM `category` WHERE .*path LIKE").
+					WithArgs("/1/5/%", int64(5)).
+					WillReturnRows(tt.children)
+			}
+			if tt.wantUpdate {
+				testSQL.ExpectExec("UPDATE `category` SET").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				// 子孙分类的路径前缀从/1/5/替换为/2/5/
+				testSQL.ExpectExec("UPDATE `category` SET `path` = CONCAT\\(\\?, SUBSTRING\\(`path`, \\?\\)\\)").
+					WithArgs("/2/5/", len("/1/5/")+1, "/1/5/%", int64(5)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				testSQL.ExpectCommit()
+			} else {
+				testSQL.ExpectRollback()
+			}
+
+			recorder, response := serve(t, http.MethodPost, "/uk/category/5", "application/json", tt.body)
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("状态码为%d，应为%d: %s", recorder.Code, tt.wantStatus, recorder.Body.String())
+			}
+			data, _ := response.Data.(map[string]interface{})
+			if tt.wantField != "" {
+				errors, _ := data["errors"].([]interface{})
+				if len(errors) != 1 || errors[0].(map[string]interface{})["code"] != tt.wantField {
+					t.Errorf("字段错误为%v，应为%s", errors, tt.wantField)
+				}
+			} else {
+				category := data["category_info"].(map[string]interface{})
+				if category["path"] != "/2/5/" || category["parent_id"] != float64(2) {
+					t.Errorf("分类为%v，路径应为/2/5/", category)
+				}
+			}
+			if err := testSQL.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
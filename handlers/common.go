@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"miHttpServer/apperror"
+	"miHttpServer/config"
+	"miHttpServer/database"
+	"miHttpServer/i18n"
+	"miHttpServer/logger"
+	"miHttpServer/middlewares"
+	"miHttpServer/models"
+	"miHttpServer/utils"
+	"miHttpServer/validation"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"xorm.io/xorm"
+)
+
+// 解析路径参数中的item_id
+func parseItemID(ctx *gin.Context) (int64, error) {
+	itemID, err := strconv.ParseInt(ctx.Param("item_id"), 10, 64)
+	if err != nil {
+		return 0, apperror.InvalidItemID(err)
+	}
+	return itemID, nil
+}
+
+// 解析并校验增加和更新商品时请求的json
+func bindRequestData(ctx *gin.Context) (models.RequestData, error) {
+	data, err := ctx.GetRawData()
+	if err != nil {
+		return models.RequestData{}, apperror.InvalidJSON(err)
+	}
+	return validation.DecodeItem(data)
+}
+
+// 根据商品名称获取分布式锁，返回释放锁的函数
+func lockItemName(ctx *gin.Context, site, name string) (func(), error) {
+	return lock(ctx, itemNameLockKey(site, name))
+}
+
+// 商品名称的分布式锁，与名称唯一键保持一致（按站点唯一时不同站点互不影响）
+func itemNameLockKey(site, name string) string {
+	return fmt.Sprintf("item_lock_name_%s", database.NameKey(site, name))
+}
+
+// 根据item_id获取分布式锁，返回释放锁的函数
+func lockItemID(ctx *gin.Context, itemID int64) (func(), error) {
+	return lock(ctx, itemIDLockKey(itemID))
+}
+
+// 商品的分布式锁，修改商品和商品下的数据都需要获取
+func itemIDLockKey(itemID int64) string {
+	return fmt.Sprintf("item_lock_id_%d", itemID)
+}
+
+// 获取分布式锁，返回释放锁的函数
+func lock(ctx *gin.Context, lockKey string) (func(), error) {
+	id := uuid.New().String()
+	ok, err := utils.GetLock(ctx.Request.Context(), lockKey, id)
+	retryAfter := config.Configs.Lock.RetryAfterSec
+	if err != nil {
+		return nil, apperror.LockUnavailable(retryAfter, err)
+	}
+	if !ok {
+		return nil, apperror.LockTimeout(retryAfter)
+	}
+	return func() { utils.ReleaseLock(ctx.Request.Context(), lockKey, id) }, nil
+}
+
+// 同时获取多把分布式锁（一次获取全部或全部不获取），返回释放锁的函数
+func lockAll(ctx *gin.Context, lockKeys []string) (func(), error) {
+	id := uuid.New().String()
+	ok, err := utils.GetLocks(ctx.Request.Context(), lockKeys, id)
+	retryAfter := config.Configs.Lock.RetryAfterSec
+	if err != nil {
+		return nil, apperror.LockUnavailable(retryAfter, err)
+	}
+	if !ok {
+		return nil, apperror.LockTimeout(retryAfter)
+	}
+	return func() { utils.ReleaseLocks(ctx.Request.Context(), lockKeys, id) }, nil
+}
+
+// 检查请求的Content-Type是否为允许的类型之一，未设置时不检查
+func checkContentType(ctx *gin.Context, allowed ...string) error {
+	contentType := ctx.ContentType()
+	if contentType == "" {
+		return nil
+	}
+	for _, t := range allowed {
+		if contentType == t {
+			return nil
+		}
+	}
+	return apperror.Request(
+		http.StatusUnsupportedMediaType,
+		i18n.UnsupportedMediaType,
+		i18n.NewError(i18n.UnsupportedMediaTypeDetail, strings.Join(allowed, ", ")),
+	)
+}
+
+// 获取站点的当前当地时间（已格式化）和站点所在国家名称（用于日志）
+func siteLocalTime(appLocal string) (string, string) {
+	// 获取当前的UTC时间
+	utcNow := time.Now().UTC()
+	location, localCountry := siteLocation(appLocal)
+	// 将 UTC 时间转换为当地时间
+	localTime := utcNow.In(location)
+	// 格式化时间
+	return localTime.Format("2006-01-02 15:04:05"), localCountry
+}
+
+// 获取站点所在的时区和国家名称
+func siteLocation(appLocal string) (*time.Location, string) {
+	var location *time.Location
+	var localCountry string
+	switch appLocal {
+	case "uk":
+		location, _ = time.LoadLocation("Europe/London")
+		localCountry = "英国"
+	case "jp":
+		location, _ = time.LoadLocation("Asia/Tokyo")
+		localCountry = "日本"
+	case "ru":
+		location, _ = time.LoadLocation("Europe/Moscow")
+		localCountry = "俄罗斯"
+	}
+	if location == nil {
+		location = time.UTC
+	}
+	return location, localCountry
+}
+
+// 解析查询参数中逗号分隔的item_id列表，例如ids=1,2,3
+func parseItemIDs(ctx *gin.Context) ([]int64, error) {
+	idsStr := strings.TrimSpace(ctx.Query("ids"))
+	if idsStr == "" {
+		return nil, apperror.InvalidFields([]apperror.FieldError{{
+			Field:  "ids",
+			Code:   i18n.FieldRequired,
+			Detail: i18n.NewError(i18n.FieldRequired),
+		}})
+	}
+	parts := strings.Split(idsStr, ",")
+	maxIDs := config.Configs.Batch.MaxQueryIDs
+	if maxIDs > 0 && len(parts) > maxIDs {
+		return nil, apperror.InvalidFields([]apperror.FieldError{{
+			Field:  "ids",
+			Code:   i18n.FieldTooManyItems,
+			Detail: i18n.NewError(i18n.FieldTooManyItems, maxIDs),
+		}})
+	}
+	itemIDs := make([]int64, 0, len(parts))
+	for _, part := range parts {
+		itemID, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+		if err != nil {
+			return nil, apperror.InvalidItemID(err)
+		}
+		itemIDs = append(itemIDs, itemID)
+	}
+	return itemIDs, nil
+}
+
+// 是否为upsert模式：查询参数upsert=true或请求头X-Upsert: true
+// upsert模式下增加商品时名称已存在则更新已存在的商品
+func isUpsert(ctx *gin.Context) bool {
+	if upsert, err := strconv.ParseBool(ctx.Query("upsert")); err == nil && upsert {
+		return true
+	}
+	upsert, err := strconv.ParseBool(ctx.GetHeader("X-Upsert"))
+	return err == nil && upsert
+}
+
+// 名称唯一键冲突时，查询已存在的商品并返回409错误
+func duplicateNameError(db xorm.Interface, site, name string) error {
+	var existing models.Item
+	exist, err := database.QueryItemByNameKey(db, database.NameKey(site, name), &existing)
+	if err != nil {
+		return apperror.Internal(i18n.QueryFailed, err)
+	}
+	if !exist {
+		// 冲突的商品在查询前已被删除，提示客户端重试
+		return apperror.LockTimeout(config.Configs.Lock.RetryAfterSec)
+	}
+	return apperror.DuplicateName(name, existing.ItemID)
+}
+
+// 从请求中获取变更历史需要记录的信息
+// 操作人根据管理员令牌的认证结果记录：管理员为admin，请求头X-Actor只作为附加的名称（admin:<X-Actor>）；
+// 其他调用方的X-Actor无法验证，记录为unverified:<X-Actor>，未设置时记录为anonymous
+// 请求ID由RequestID中间件设置（请求头X-Request-ID或生成的UUID）
+func auditInfo(ctx *gin.Context) models.AuditInfo {
+	actor := strings.TrimSpace(ctx.GetHeader("X-Actor"))
+	switch {
+	case middlewares.IsAdmin(ctx) && actor != "":
+		actor = "admin:" + actor
+	case middlewares.IsAdmin(ctx):
+		actor = "admin"
+	case actor != "":
+		actor = "unverified:" + actor
+	default:
+		actor = "anonymous"
+	}
+	return models.AuditInfo{
+		Actor:     truncate(actor, 64),
+		Site:      ctx.Param("app_local"),
+		RequestID: truncate(logger.RequestID(ctx), 64),
+	}
+}
+
+// 截断字符串，防止超过数据库字段长度
+func truncate(s string, maxLength int) string {
+	if len(s) > maxLength {
+		return s[:maxLength]
+	}
+	return s
+}
+
+// 解析分页查询参数page和page_size，page从1开始
+func parsePagination(ctx *gin.Context, defaultPageSize, maxPageSize int) (int, int, error) {
+	var fields []apperror.FieldError
+	page, err := parseIntQuery(ctx, "page", 1, 1, math.MaxInt32)
+	if err != nil {
+		fields = append(fields, *err)
+	}
+	pageSize, err := parseIntQuery(ctx, "page_size", defaultPageSize, 1, maxPageSize)
+	if err != nil {
+		fields = append(fields, *err)
+	}
+	if len(fields) > 0 {
+		return 0, 0, apperror.InvalidFields(fields)
+	}
+	return page, pageSize, nil
+}
+
+// 解析整数类型的查询参数，未设置时返回默认值
+func parseIntQuery(ctx *gin.Context, name string, defaultValue, min, max int) (int, *apperror.FieldError) {
+	valueStr := strings.TrimSpace(ctx.Query(name))
+	if valueStr == "" {
+		return defaultValue, nil
+	}
+	value, err := strconv.Atoi(valueStr)
+	if err != nil {
+		return 0, &apperror.FieldError{
+			Field:  name,
+			Code:   i18n.FieldInvalidType,
+			Detail: i18n.NewError(i18n.FieldInvalidType, "int"),
+		}
+	}
+	if value < min || value > max {
+		return 0, &apperror.FieldError{
+			Field:  name,
+			Code:   i18n.FieldOutOfRange,
+			Detail: i18n.NewError(i18n.FieldOutOfRange, min, max),
+		}
+	}
+	return value, nil
+}
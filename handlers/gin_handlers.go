@@ -1,266 +1,364 @@
 package handlers
 
 import (
-	"encoding/json"
 	"errors"
-	"fmt"
-	"log"
+	"miHttpServer/apperror"
 	"miHttpServer/caches"
 	"miHttpServer/database"
+	"miHttpServer/i18n"
+	"miHttpServer/logger"
 	"miHttpServer/models"
+	"miHttpServer/search"
 	"miHttpServer/utils"
+	"miHttpServer/validation"
 	"net/http"
-	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 )
 
 // 增加商品信息
 func AddItem(ctx *gin.Context) {
-	data, err := ctx.GetRawData()
-	var response models.ResponseData
-	if err != nil {
-		response = utils.DealRequestError(
-			"客户端传递的json非法",
-			err,
-		)
-		ctx.JSON(http.StatusBadRequest, response)
-		return
-	}
-	var requestStr models.RequestData
-	err = json.Unmarshal(data, &requestStr)
+	requestStr, err := bindRequestData(ctx)
 	if err != nil {
-		response = utils.DealRequestError(
-			"客户端传递的json非法",
-			err,
-		)
-		ctx.JSON(http.StatusBadRequest, response)
+		utils.RespondError(ctx, err)
 		return
 	}
 	item := models.Item{
-		Name:  requestStr.Name,
-		Price: requestStr.Price,
+		Name:       requestStr.Name,
+		Price:      requestStr.Price,
+		Site:       ctx.Param("app_local"),
+		Attributes: requestStr.Attributes,
+		Tags:       requestStr.Tags,
 	}
 
 	// 尝试获取分布式锁
-	id := uuid.New().String()
-	lockKey := fmt.Sprintf("item_lock_name_%s", requestStr.Name)
-	ok, err := utils.GetLock(lockKey, id)
+	unlock, err := lockItemName(ctx, item.Site, item.Name)
 	if err != nil {
-		response = utils.DealServerError("获取分布式锁错误", err)
-		ctx.JSON(http.StatusInternalServerError, response)
+		utils.RespondError(ctx, err)
 		return
 	}
-	if !ok {
-		response = utils.DealServerError("获取分布式锁超时", errors.New("请稍后再试"))
-		ctx.JSON(http.StatusConflict, response)
+	// 确保最后释放锁
+	defer unlock()
+
+	// 检查商品名称是否已存在
+	upsert := isUpsert(ctx)
+	var existing models.Item
+	exist, err := database.QueryItemByNameKey(database.Engine.Context(ctx.Request.Context()), database.NameKey(item.Site, item.Name), &existing)
+	if err != nil {
+		utils.RespondError(ctx, apperror.Internal(i18n.QueryFailed, err))
+		return
+	}
+	if exist {
+		if !upsert {
+			utils.RespondError(ctx, apperror.DuplicateName(item.Name, existing.ItemID))
+			return
+		}
+		// upsert模式下更新已存在的商品
+		upsertItem(ctx, existing.ItemID, item)
 		return
 	}
-	// 确保最后释放锁
-	defer utils.ReleaseLock(lockKey, id)
 
-	_, err = database.InsertItem(&item)
+	_, err = database.InsertItem(ctx.Request.Context(), &item, auditInfo(ctx))
+	if database.IsDuplicateKey(err) {
+		utils.RespondError(ctx, duplicateNameError(database.Engine.Context(ctx.Request.Context()), item.Site, item.Name))
+		return
+	}
 	if err != nil {
-		response = utils.DealServerError("插入数据失败", err)
-		ctx.JSON(http.StatusInternalServerError, response)
+		utils.RespondError(ctx, apperror.Internal(i18n.InsertFailed, err))
 		return
 	}
 
 	itemInfo := make(map[string]interface{})
-	itemInfo["item_info"] = map[string]interface{}{
-		"item_id": item.ItemID,
-		"name":    item.Name,
-		"price":   item.Price,
+	itemInfo["item_info"] = models.NewItemCache(item).StoreInfo()
+	if upsert {
+		itemInfo["action"] = "created"
 	}
-	response = utils.DealSuccess("成功", itemInfo)
+	response := utils.DealSuccess(ctx, itemInfo)
 	ctx.JSON(http.StatusOK, response)
-	log.Printf("增加商品，item_id: %d，name：%s", item.ItemID, item.Name)
+	logger.Printf(ctx, "增加商品，item_id: %d，name：%s", item.ItemID, item.Name)
+
+	// 更新搜索索引
+	search.IndexItem(item)
 }
 
-// 修改商品信息（如果缓存中也存在，需要同步更新）
-func UpdateItem(ctx *gin.Context) {
-	itemIDStr := ctx.Param("item_id")
-	var response models.ResponseData
-	item_id, err := strconv.ParseInt(itemIDStr, 10, 64)
+// upsert模式下增加商品时名称已存在，更新已存在的商品并同步缓存
+func upsertItem(ctx *gin.Context, item_id int64, item models.Item) {
+	// 已持有名称的锁，再获取商品的锁，与修改和部分修改互斥
+	unlock, err := lockItemID(ctx, item_id)
 	if err != nil {
-		response = utils.DealRequestError("item_id非法", err)
-		ctx.JSON(http.StatusBadRequest, response)
+		utils.RespondError(ctx, err)
 		return
 	}
+	defer unlock()
 
-	data, err := ctx.GetRawData()
+	item.ItemID = item_id
+	n, err := database.UpdateItem(ctx.Request.Context(), item_id, &item, auditInfo(ctx))
+	if err != nil {
+		utils.RespondError(ctx, apperror.Internal(i18n.UpdateFailed, err))
+		return
+	}
+	if n == 0 {
+		utils.RespondError(ctx, apperror.ItemNotFound(item_id))
+		return
+	}
+	itemInfo := make(map[string]interface{})
+	itemInfo["item_info"] = models.NewItemCache(item).StoreInfo()
+	itemInfo["action"] = "updated"
+	response := utils.DealSuccess(ctx, itemInfo)
+	ctx.JSON(http.StatusOK, response)
+	logger.Printf(ctx, "增加商品时名称已存在，更新商品，item_id: %d，name：%s", item.ItemID, item.Name)
+
+	itemCache := models.NewItemCache(item)
+	caches.UpdateLocalCache(item_id, itemCache)
+
+	// 更新搜索索引
+	search.IndexItem(item)
+	err = caches.UpdateRedisCache(ctx.Request.Context(), item_id, item)
 	if err != nil {
-		response = utils.DealRequestError(
-			"客户端传递的json非法",
-			err,
-		)
-		ctx.JSON(http.StatusBadRequest, response)
+		logger.Printf(ctx, "更新商品%d的Redis缓存失败: %s", item_id, err.Error())
+	}
+}
+
+// 修改商品信息（如果缓存中也存在，需要同步更新）
+func UpdateItem(ctx *gin.Context) {
+	item_id, err := parseItemID(ctx)
+	if err != nil {
+		utils.RespondError(ctx, err)
 		return
 	}
 
-	var requestStr models.RequestData
-	err = json.Unmarshal(data, &requestStr)
+	requestStr, err := bindRequestData(ctx)
 	if err != nil {
-		response = utils.DealRequestError(
-			"客户端传递的json非法",
-			err,
-		)
-		ctx.JSON(http.StatusBadRequest, response)
+		utils.RespondError(ctx, err)
 		return
 	}
 	item := models.Item{
 		ItemID: item_id,
 		Name:   requestStr.Name,
 		Price:  requestStr.Price,
+		// 为nil时不修改已有的属性和标签
+		Attributes: requestStr.Attributes,
+		Tags:       requestStr.Tags,
 	}
 
-	// 尝试获取分布式锁
-	id := uuid.New().String()
-	lockKey := fmt.Sprintf("item_lock_name_%s", requestStr.Name)
-	ok, err := utils.GetLock(lockKey, id)
+	// 名称唯一键按商品保存的站点计算，名称的锁也使用相同的站点，而不是请求的站点
+	site, exist, err := database.QueryItemSite(ctx.Request.Context(), item_id)
 	if err != nil {
-		response := utils.DealServerError("获取分布式锁错误", err)
-		ctx.JSON(http.StatusInternalServerError, response)
+		utils.RespondError(ctx, apperror.Internal(i18n.QueryFailed, err))
 		return
 	}
-	if !ok {
-		response := utils.DealServerError("获取分布式锁超时", errors.New("请稍后再试"))
-		ctx.JSON(http.StatusConflict, response)
+	if !exist {
+		utils.RespondError(ctx, apperror.ItemNotFound(item_id))
+		return
+	}
+
+	// 同时获取商品和新名称的分布式锁，与部分修改互斥，防止并发修改同一商品
+	unlock, err := lockAll(ctx, []string{itemIDLockKey(item_id), itemNameLockKey(site, requestStr.Name)})
+	if err != nil {
+		utils.RespondError(ctx, err)
 		return
 	}
 	// 确保最后释放锁
-	defer utils.ReleaseLock(lockKey, id)
+	defer unlock()
 
-	n, err := database.UpdateItem(item_id, &item)
+	n, err := database.UpdateItem(ctx.Request.Context(), item_id, &item, auditInfo(ctx))
+	if database.IsDuplicateKey(err) {
+		utils.RespondError(ctx, duplicateNameError(database.Engine.Context(ctx.Request.Context()), item.Site, item.Name))
+		return
+	}
 	if err != nil {
-		response = utils.DealServerError("更新数据失败", err)
-		ctx.JSON(http.StatusInternalServerError, response)
+		utils.RespondError(ctx, apperror.Internal(i18n.UpdateFailed, err))
 		return
 	}
 	if n == 0 {
-		response = utils.DealServerError(
-			"未找到相关记录",
-			fmt.Errorf("item_id为%v的商品不存在", item_id),
-		)
-		ctx.JSON(http.StatusInternalServerError, response)
+		utils.RespondError(ctx, apperror.ItemNotFound(item_id))
 		return
 	}
 	storeInfo := make(map[string]interface{})
-	storeInfo["store_info"] = map[string]interface{}{
-		"item_id": item.ItemID,
-		"name":    item.Name,
-		"price":   item.Price,
-	}
+	storeInfo["store_info"] = models.NewItemCache(item).StoreInfo()
 
-	response = utils.DealSuccess("成功", storeInfo)
+	response := utils.DealSuccess(ctx, storeInfo)
 	ctx.JSON(http.StatusOK, response)
-	log.Printf("修改商品，item_id: %d，name：%s", item.ItemID, item.Name)
+	logger.Printf(ctx, "修改商品，item_id: %d，name：%s", item.ItemID, item.Name)
 
 	// 查找本地缓存中是否有相同的数据，有则更新本地缓存
-	itemCache := models.ItemCache{
-		ItemID: item.ItemID,
-		Name:   item.Name,
-		Price:  item.Price,
-	}
+	itemCache := models.NewItemCache(item)
 	caches.UpdateLocalCache(item_id, itemCache)
 
+	// 更新搜索索引
+	search.IndexItem(item)
+
 	// 查找redis缓存中是否有相同数据，有则更新redis缓存
-	err = caches.UpdateRedisCache(item_id, item)
+	err = caches.UpdateRedisCache(ctx.Request.Context(), item_id, item)
 	if err != nil {
-		log.Printf("查询商品%d的Redis缓存失败: %s", item_id, err.Error())
+		logger.Printf(ctx, "查询商品%d的Redis缓存失败: %s", item_id, err.Error())
+	}
+}
+
+// 部分更新商品信息（JSON Merge Patch），可以只修改一个字段，也可以将字段设置为零值
+func PatchItem(ctx *gin.Context) {
+	item_id, err := parseItemID(ctx)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+
+	err = checkContentType(ctx, "application/merge-patch+json", "application/json")
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	data, err := ctx.GetRawData()
+	if err != nil {
+		utils.RespondError(ctx, apperror.InvalidJSON(err))
+		return
+	}
+	patch, err := validation.DecodeItemPatch(data)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+
+	// 按item_id获取分布式锁，防止并发修改同一商品
+	unlock, err := lockItemID(ctx, item_id)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	// 确保最后释放锁
+	defer unlock()
+
+	item, exist, err := database.PatchItem(ctx.Request.Context(), item_id, patch, auditInfo(ctx))
+	if errors.Is(err, database.ErrTooManyAttributes) {
+		utils.RespondError(ctx, validation.TooManyAttributes())
+		return
+	}
+	if database.IsDuplicateKey(err) {
+		utils.RespondError(ctx, duplicateNameError(database.Engine.Context(ctx.Request.Context()), item.Site, item.Name))
+		return
+	}
+	if err != nil {
+		utils.RespondError(ctx, apperror.Internal(i18n.UpdateFailed, err))
+		return
+	}
+	if !exist {
+		utils.RespondError(ctx, apperror.ItemNotFound(item_id))
+		return
+	}
+	storeInfo := make(map[string]interface{})
+	storeInfo["store_info"] = models.NewItemCache(item).StoreInfo()
+
+	response := utils.DealSuccess(ctx, storeInfo)
+	ctx.JSON(http.StatusOK, response)
+	logger.Printf(ctx, "部分修改商品，item_id: %d，name：%s", item.ItemID, item.Name)
+
+	// 使用更新后的完整数据同步本地缓存和redis缓存
+	itemCache := models.NewItemCache(item)
+	caches.UpdateLocalCache(item_id, itemCache)
+
+	// 更新搜索索引
+	search.IndexItem(item)
+
+	err = caches.UpdateRedisCache(ctx.Request.Context(), item_id, item)
+	if err != nil {
+		logger.Printf(ctx, "更新商品%d的Redis缓存失败: %s", item_id, err.Error())
 	}
 }
 
 // 查询商品信息（先查询缓存，未命中再查询MySQL）
+// 指定as_of参数时根据变更历史还原商品在该时刻的状态，不使用缓存
 func QueryItem(ctx *gin.Context) {
-	itemIDStr := ctx.Param("item_id")
-	var response models.ResponseData
-	item_id, err := strconv.ParseInt(itemIDStr, 10, 64)
+	item_id, err := parseItemID(ctx)
 	if err != nil {
-		response = utils.DealRequestError("item_id非法", err)
-		ctx.JSON(http.StatusBadRequest, response)
+		utils.RespondError(ctx, err)
 		return
 	}
+	if asOf := ctx.Query("as_of"); asOf != "" {
+		queryItemAsOf(ctx, item_id, asOf)
+		return
+	}
+
+	site := ctx.Param("app_local")
 
 	// 从本地缓存中查询数据
-	ok, storeInfo := caches.QueryLocalCache(item_id)
+	ok, itemCache := caches.QueryLocalCache(ctx.Request.Context(), item_id)
 	if ok {
-		response = utils.DealSuccess("成功", storeInfo)
-		ctx.JSON(http.StatusOK, response)
+		respondItemInfo(ctx, itemCache, site)
 		return
 	}
 
 	// 从redis缓存中查询数据
-	err, storeInfo = caches.QueryRedisCache(item_id)
+	err, cached := caches.QueryRedisCache(ctx.Request.Context(), item_id)
 	if err != nil {
-		log.Printf("查询商品%d的Redis缓存失败: %s", item_id, err.Error())
+		logger.Printf(ctx, "查询商品%d的Redis缓存失败: %s", item_id, err.Error())
 	} else {
-		if storeInfo != nil {
+		if cached != nil {
 			// 说明缓存中有数据
-			response = utils.DealSuccess("成功", storeInfo)
-			ctx.JSON(http.StatusOK, response)
+			respondItemInfo(ctx, *cached, site)
 			// 将数据添加到本地缓存中
-			itemCache := models.ItemCache{
-				ItemID: item_id,
-				Name:   storeInfo["store_info"].(map[string]interface{})["name"].(string),
-				Price:  storeInfo["store_info"].(map[string]interface{})["price"].(float64),
-			}
-			caches.AddLocalCache(item_id, itemCache)
+			caches.AddLocalCache(item_id, *cached)
 			return
 		}
 	}
 
 	// 从MySQL查询数据
 	item := models.Item{}
-	success, err := database.QueryItem(item_id, &item)
+	success, err := database.QueryItem(ctx.Request.Context(), item_id, &item)
 	if err != nil {
-		response = utils.DealServerError("查询数据失败", err)
-		ctx.JSON(http.StatusInternalServerError, response)
+		utils.RespondError(ctx, apperror.Internal(i18n.QueryFailed, err))
 		return
 	}
 	if !success {
-		response = utils.DealServerError(
-			"未找到相关记录",
-			fmt.Errorf("item_id为%v的商品不存在", item_id),
-		)
-		ctx.JSON(http.StatusInternalServerError, response)
+		utils.RespondError(ctx, apperror.ItemNotFound(item_id))
 		return
 	}
 
-	storeInfo = make(map[string]interface{})
-	storeInfo["store_info"] = map[string]interface{}{
-		"item_id": item.ItemID,
-		"name":    item.Name,
-		"price":   item.Price,
-	}
-	response = utils.DealSuccess("成功", storeInfo)
-	ctx.JSON(http.StatusOK, response)
+	itemCache = models.NewItemCache(item)
+	respondItemInfo(ctx, itemCache, site)
 
 	// 将数据存入本地缓存
-	itemCache := models.ItemCache{
-		ItemID: item.ItemID,
-		Name:   item.Name,
-		Price:  item.Price,
-	}
 	caches.AddLocalCache(item_id, itemCache)
 
 	// 将数据存入Redis缓存
-	err = caches.AddRedisCache(item_id, item)
+	err = caches.AddRedisCache(ctx.Request.Context(), item_id, item)
 	if err != nil {
-		log.Printf("增加商品%d的Redis缓存失败: %s", item_id, err.Error())
+		logger.Printf(ctx, "增加商品%d的Redis缓存失败: %s", item_id, err.Error())
+	}
+}
+
+// 返回商品信息，price为原价，同时返回原价（list_price）和当前站点的实际售价（effective_price）
+// 站点有生效中的促销价时，sale中返回促销价和结束时间（站点当地时间）
+// 商品在站点未发布时只有管理员可以看到，其他调用方返回商品不存在
+func respondItemInfo(ctx *gin.Context, itemCache models.ItemCache, site string) {
+	if !itemVisible(ctx, itemCache) {
+		utils.RespondError(ctx, apperror.ItemNotFound(itemCache.ItemID))
+		return
+	}
+	info := itemCache.StoreInfo()
+	info["status"] = itemCache.StatusOf(site)
+	effectivePrice, sale := itemCache.EffectivePrice(site, time.Now())
+	info["list_price"] = itemCache.Price
+	info["effective_price"] = effectivePrice
+	if sale != nil {
+		location, _ := siteLocation(site)
+		info["sale"] = map[string]interface{}{
+			"schedule_id": sale.ScheduleID,
+			"price":       sale.Price,
+			"end_at":      sale.EndAt.In(location),
+		}
 	}
+	storeInfo := make(map[string]interface{})
+	storeInfo["store_info"] = info
+	response := utils.DealSuccess(ctx, storeInfo)
+	ctx.JSON(http.StatusOK, response)
 }
 
 // 删除商品信息（如果缓存中也存在，需要同步删除）
 func DeleteItem(ctx *gin.Context) {
-	itemIDStr := ctx.Param("item_id")
-	var response models.ResponseData
-	item_id, err := strconv.ParseInt(itemIDStr, 10, 64)
+	item_id, err := parseItemID(ctx)
 	if err != nil {
-		response = utils.DealRequestError("item_id非法", err)
-		ctx.JSON(http.StatusBadRequest, response)
+		utils.RespondError(ctx, err)
 		return
 	}
 
@@ -268,51 +366,28 @@ func DeleteItem(ctx *gin.Context) {
 	// 先检查是否已经被删除，保证幂等性
 	if deleteItemTime, exist := models.ItemDeleteTime[item_id]; exist {
 		deleteTime["delete_time"] = deleteItemTime
-		response = utils.DealSuccess("成功", deleteTime)
+		response := utils.DealSuccess(ctx, deleteTime)
 		ctx.JSON(http.StatusOK, response)
 		return
 	}
 
-	// 获取当前的UTC时间
-	utcNow := time.Now().UTC()
-	var location *time.Location
-	appLocal := ctx.Param("app_local")
-	var localCountry string
-	switch appLocal {
-	case "uk":
-		location, _ = time.LoadLocation("Europe/London")
-		localCountry = "英国"
-	case "jp":
-		location, _ = time.LoadLocation("Asia/Tokyo")
-		localCountry = "日本"
-	case "ru":
-		location, _ = time.LoadLocation("Europe/Moscow")
-		localCountry = "俄罗斯"
-	}
-	// 将 UTC 时间转换为当地时间
-	localTime := utcNow.In(location)
-	// 格式化时间
-	formattedTime := localTime.Format("2006-01-02 15:04:05")
-
-	n, err := database.DeleteItem(item_id)
+	// 获取站点的当地时间
+	formattedTime, localCountry := siteLocalTime(ctx.Param("app_local"))
+
+	n, err := database.DeleteItem(ctx.Request.Context(), item_id, auditInfo(ctx))
 	if err != nil {
-		response = utils.DealServerError("删除数据失败", err)
-		ctx.JSON(http.StatusInternalServerError, response)
+		utils.RespondError(ctx, apperror.Internal(i18n.DeleteFailed, err))
 		return
 	}
 	if n == 0 {
-		response = utils.DealServerError(
-			"未找到相关记录",
-			fmt.Errorf("item_id为%v的商品不存在", item_id),
-		)
-		ctx.JSON(http.StatusInternalServerError, response)
+		utils.RespondError(ctx, apperror.ItemNotFound(item_id))
 		return
 	}
 
 	deleteTime["delete_time"] = formattedTime
-	response = utils.DealSuccess("成功", deleteTime)
+	response := utils.DealSuccess(ctx, deleteTime)
 	ctx.JSON(http.StatusOK, response)
-	log.Printf("%s站点删除商品，item_id: %d，当地时间：%s", localCountry, item_id, formattedTime)
+	logger.Printf(ctx, "%s站点删除商品，item_id: %d，当地时间：%s", localCountry, item_id, formattedTime)
 
 	// 将删除时间存入map
 	models.ItemDeleteTime[item_id] = formattedTime
@@ -320,9 +395,15 @@ func DeleteItem(ctx *gin.Context) {
 	// 查找本地缓存中是否有相同的数据，有同步删除
 	caches.DeleteLocalCache(item_id)
 
+	// 删除搜索索引
+	search.RemoveItem(item_id)
+
+	// 删除商品的媒体文件
+	removeItemMediaBlobs(ctx, item_id)
+
 	// 查找redis缓存中是否有相同数据，有则删除
-	err = caches.DeleteItemCache(item_id)
+	err = caches.DeleteItemCache(ctx.Request.Context(), item_id)
 	if err != nil {
-		log.Printf("删除商品%d的Redis缓存失败: %s", item_id, err.Error())
+		logger.Printf(ctx, "删除商品%d的Redis缓存失败: %s", item_id, err.Error())
 	}
 }
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"miHttpServer/caches"
+	"miHttpServer/config"
+	"miHttpServer/database"
+	"miHttpServer/i18n"
+	"miHttpServer/middlewares"
+	"miHttpServer/models"
+	"miHttpServer/utils"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"xorm.io/xorm"
+	"xorm.io/xorm/core"
+)
+
+var (
+	testRedis  *miniredis.Miniredis
+	testSQL    sqlmock.Sqlmock
+	testRouter *gin.Engine
+)
+
+// 使用miniredis代替Redis，sqlmock代替MySQL，路由只注册被测试的处理函数
+func TestMain(m *testing.M) {
+	// 配置文件的路径相对于项目根目录
+	if err := os.Chdir(".."); err != nil {
+		log.Fatalln(err)
+	}
+	utils.ParseYaml()
+	config.Configs.Response.ProblemJSON = false
+	// 缩短获取锁的等待时间
+	config.Configs.Lock.WaitSec = 1
+
+	var err error
+	testRedis, err = miniredis.Run()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	config.Configs.Redis.Address = testRedis.Addr()
+	config.Configs.Redis.Password = ""
+	database.InitRedis()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	testSQL = mock
+	database.Engine, err = xorm.NewEngineWithDB("mysql", "test:test@tcp(127.0.0.1:3306)/test", core.FromDB(db))
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	caches.LocalCache = caches.NewLRUCache[models.ItemCache](config.Configs.LocalCache.Capacity)
+	caches.CategoryLocalCache = caches.NewLRUCache[models.CategoryCache](config.Configs.LocalCache.Capacity)
+
+	gin.SetMode(gin.TestMode)
+	testRouter = gin.New()
+	testRouter.ContextWithFallback = true
+	testRouter.Use(middlewares.RequestID(), middlewares.SetAppLocal(), middlewares.Admin())
+	testRouter.POST("/:app_local/item", AddItem)
+	testRouter.PATCH("/:app_local/item/:item_id", PatchItem)
+	testRouter.GET("/:app_local/item/:item_id", QueryItem)
+	testRouter.POST("/:app_local/items/import", ImportItems)
+	testRouter.GET("/:app_local/item/:item_id/stock", QueryStock)
+	testRouter.POST("/:app_local/item/:item_id/skus", AddSKU)
+	testRouter.POST("/:app_local/item/:item_id/skus/:sku_id", UpdateSKU)
+	testRouter.DELETE("/:app_local/item/:item_id/skus/:sku_id", DeleteSKU)
+	testRouter.POST("/:app_local/category/:category_id", UpdateCategory)
+
+	code := m.Run()
+	database.CloseRedis()
+	testRedis.Close()
+	os.Exit(code)
+}
+
+// 发送请求并解析原有格式的响应
+func serve(t *testing.T, method, path, contentType, body string) (*httptest.ResponseRecorder, models.ResponseData) {
+	t.Helper()
+	request := httptest.NewRequest(method, path, strings.NewReader(body))
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+	recorder := httptest.NewRecorder()
+	testRouter.ServeHTTP(recorder, request)
+
+	var response models.ResponseData
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("响应不是json: %s", recorder.Body.String())
+	}
+	return recorder, response
+}
+
+func TestQueryItemNotFound(t *testing.T) {
+	testSQL.ExpectQuery("SELECT .* FROM `item`").
+		WithArgs(int64(404)).
+		WillReturnRows(sqlmock.NewRows([]string{"item_id", "name", "price", "site"}))
+
+	recorder, response := serve(t, http.MethodGet, "/uk/item/404", "", "")
+	if recorder.Code != http.StatusNotFound {
+		t.Fatalf("状态码为%d，应为404: %s", recorder.Code, recorder.Body.String())
+	}
+	if response.ErrorCode != i18n.ItemNotFound {
+		t.Errorf("error_code为%q，应为ITEM_NOT_FOUND", response.ErrorCode)
+	}
+	if response.RequestID == "" {
+		t.Error("响应中没有request_id")
+	}
+	if err := testSQL.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPatchItemLockTimeout(t *testing.T) {
+	// 其他请求持有商品的锁，等待超时后返回409
+	testRedis.DB(config.Configs.Redis.Database).Set("item_lock_id_1", "other")
+	defer testRedis.DB(config.Configs.Redis.Database).Del("item_lock_id_1")
+
+	recorder, response := serve(t, http.MethodPatch, "/uk/item/1", "application/merge-patch+json", `{"price": 10}`)
+	if recorder.Code != http.StatusConflict {
+		t.Fatalf("状态码为%d，应为409: %s", recorder.Code, recorder.Body.String())
+	}
+	if response.ErrorCode != i18n.LockTimeout {
+		t.Errorf("error_code为%q，应为LOCK_TIMEOUT", response.ErrorCode)
+	}
+	checkRetryAfter(t, recorder)
+}
+
+func TestPatchItemLockUnavailable(t *testing.T) {
+	// Redis不可用时无法获取锁，返回503
+	testRedis.Close()
+	defer func() {
+		if err := testRedis.Restart(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	recorder, response := serve(t, http.MethodPatch, "/uk/item/1", "application/merge-patch+json", `{"price": 10}`)
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Fatalf("状态码为%d，应为503: %s", recorder.Code, recorder.Body.String())
+	}
+	if response.ErrorCode != i18n.LockError {
+		t.Errorf("error_code为%q，应为LOCK_ERROR", response.ErrorCode)
+	}
+	checkRetryAfter(t, recorder)
+}
+
+// 检查响应头Retry-After与配置一致
+func checkRetryAfter(t *testing.T, recorder *httptest.ResponseRecorder) {
+	t.Helper()
+	want := strconv.Itoa(config.Configs.Lock.RetryAfterSec)
+	if got := recorder.Header().Get("Retry-After"); got != want {
+		t.Errorf("Retry-After为%q，应为%q", got, want)
+	}
+}
+
+func TestAddItemValidation(t *testing.T) {
+	recorder, response := serve(t, http.MethodPost, "/uk/item", "application/json", `{"name": "", "price": -1}`)
+	if recorder.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("状态码为%d，应为422: %s", recorder.Code, recorder.Body.String())
+	}
+	if response.ErrorCode != i18n.ValidationFailed {
+		t.Errorf("error_code为%q，应为VALIDATION_FAILED", response.ErrorCode)
+	}
+
+	data, ok := response.Data.(map[string]interface{})
+	if !ok {
+		t.Fatalf("data中没有字段错误: %v", response.Data)
+	}
+	errors, _ := data["errors"].([]interface{})
+	fields := make(map[string]bool)
+	for _, e := range errors {
+		if fieldError, ok := e.(map[string]interface{}); ok {
+			fields[fieldError["field"].(string)] = true
+		}
+	}
+	for _, field := range []string{"name", "price"} {
+		if !fields[field] {
+			t.Errorf("缺少字段%s的错误: %v", field, errors)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"miHttpServer/config"
+	"miHttpServer/health"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 存活检查（GET /healthz），进程能处理请求就返回200，不检查依赖
+func Healthz(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// 就绪检查（GET /readyz），检查MySQL和Redis并返回各自的状态和耗时
+// Redis不可用时为降级状态，仍返回200继续接收请求；MySQL不可用、服务启动完成前和开始关闭后返回503
+func Readyz(ctx *gin.Context) {
+	timeout := time.Duration(config.Configs.Server.HealthCheckTimeoutSec) * time.Second
+	report := health.Check(timeout)
+	status := http.StatusOK
+	if report.Status != health.StatusReady && report.Status != health.StatusDegraded {
+		status = http.StatusServiceUnavailable
+	}
+	ctx.JSON(status, report)
+}
@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"miHttpServer/apperror"
+	"miHttpServer/config"
+	"miHttpServer/database"
+	"miHttpServer/i18n"
+	"miHttpServer/middlewares"
+	"miHttpServer/models"
+	"miHttpServer/utils"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 分页查询商品的变更历史（GET /:app_local/item/:item_id/history?page=1&page_size=20）
+// 按时间倒序返回，商品被删除后仍然可以查询
+func QueryItemHistory(ctx *gin.Context) {
+	item_id, err := parseItemID(ctx)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	defaultPageSize := config.Configs.Audit.DefaultPageSize
+	if defaultPageSize <= 0 {
+		defaultPageSize = 20
+	}
+	maxPageSize := config.Configs.Audit.MaxPageSize
+	if maxPageSize < defaultPageSize {
+		maxPageSize = defaultPageSize
+	}
+	page, pageSize, err := parsePagination(ctx, defaultPageSize, maxPageSize)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+
+	audits, total, err := database.QueryItemHistory(ctx.Request.Context(), item_id, (page-1)*pageSize, pageSize)
+	if err != nil {
+		utils.RespondError(ctx, apperror.Internal(i18n.QueryFailed, err))
+		return
+	}
+	// 没有任何变更历史说明商品从未存在过
+	if total == 0 {
+		utils.RespondError(ctx, apperror.ItemNotFound(item_id))
+		return
+	}
+
+	historyInfo := make(map[string]interface{})
+	historyInfo["history"] = audits
+	historyInfo["page"] = page
+	historyInfo["page_size"] = pageSize
+	historyInfo["total"] = total
+	response := utils.DealSuccess(ctx, historyInfo)
+	ctx.JSON(http.StatusOK, response)
+}
+
+// 查询商品在as_of时刻的状态（GET /:app_local/item/:item_id?as_of=<RFC3339>）
+func queryItemAsOf(ctx *gin.Context, item_id int64, asOfStr string) {
+	asOf, err := parseTime("as_of", asOfStr)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	// 普通调用方只能查询在as_of时刻已在站点发布的商品（商品之后被下架或删除也可以查询）
+	if !middlewares.IsAdmin(ctx) {
+		status, err := database.QueryItemStatusAsOf(ctx.Request.Context(), item_id, ctx.Param("app_local"), asOf)
+		if err != nil {
+			utils.RespondError(ctx, apperror.Internal(i18n.QueryFailed, err))
+			return
+		}
+		if status != models.StatusPublished {
+			utils.RespondError(ctx, apperror.ItemNotFound(item_id))
+			return
+		}
+	}
+	snapshot, err := database.QueryItemAsOf(ctx.Request.Context(), item_id, asOf)
+	if err != nil {
+		utils.RespondError(ctx, apperror.Internal(i18n.QueryFailed, err))
+		return
+	}
+	if snapshot == nil {
+		utils.RespondError(ctx, apperror.ItemNotFound(item_id))
+		return
+	}
+	storeInfo := make(map[string]interface{})
+	storeInfo["store_info"] = map[string]interface{}{
+		"item_id": snapshot.ItemID,
+		"name":    snapshot.Name,
+		"price":   snapshot.Price,
+	}
+	storeInfo["as_of"] = asOf.Format(time.RFC3339)
+	response := utils.DealSuccess(ctx, storeInfo)
+	ctx.JSON(http.StatusOK, response)
+}
+
+// 比较商品的两个版本（GET /:app_local/item/:item_id/diff?from=<版本>&to=<版本>）
+// 版本可以是变更历史的audit_id（该次变更后的状态），也可以是RFC3339时间（该时刻的状态）
+// to不指定时与当前状态比较
+func DiffItemVersions(ctx *gin.Context) {
+	item_id, err := parseItemID(ctx)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	fromStr, toStr := ctx.Query("from"), ctx.Query("to")
+	if fromStr == "" {
+		utils.RespondError(ctx, apperror.InvalidFields([]apperror.FieldError{{
+			Field:  "from",
+			Code:   i18n.FieldRequired,
+			Detail: i18n.NewError(i18n.FieldRequired),
+		}}))
+		return
+	}
+	if toStr == "" {
+		toStr = time.Now().Format(time.RFC3339)
+	}
+	from, err := queryItemVersion(ctx, item_id, "from", fromStr)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	to, err := queryItemVersion(ctx, item_id, "to", toStr)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	if from == nil && to == nil {
+		utils.RespondError(ctx, apperror.ItemNotFound(item_id))
+		return
+	}
+
+	diffInfo := make(map[string]interface{})
+	diffInfo["item_id"] = item_id
+	diffInfo["from"] = from
+	diffInfo["to"] = to
+	diffInfo["changes"] = diffSnapshots(from, to)
+	response := utils.DealSuccess(ctx, diffInfo)
+	ctx.JSON(http.StatusOK, response)
+}
+
+// 查询商品的某个版本，版本为整数时按audit_id查询，否则按RFC3339时间查询
+// 商品在该版本不存在（尚未创建或已删除）时返回nil
+func queryItemVersion(ctx *gin.Context, item_id int64, field, version string) (*models.ItemSnapshot, error) {
+	if audit_id, err := strconv.ParseInt(version, 10, 64); err == nil {
+		audit, exist, err := database.QueryItemAudit(ctx.Request.Context(), item_id, audit_id)
+		if err != nil {
+			return nil, apperror.Internal(i18n.QueryFailed, err)
+		}
+		if !exist {
+			return nil, apperror.VersionNotFound(item_id, audit_id)
+		}
+		return audit.After, nil
+	}
+	at, err := parseTime(field, version)
+	if err != nil {
+		return nil, err
+	}
+	snapshot, err := database.QueryItemAsOf(ctx.Request.Context(), item_id, at)
+	if err != nil {
+		return nil, apperror.Internal(i18n.QueryFailed, err)
+	}
+	return snapshot, nil
+}
+
+// 对比两个版本，返回发生变化的字段及其前后的值
+func diffSnapshots(from, to *models.ItemSnapshot) map[string]interface{} {
+	var before, after models.ItemSnapshot
+	if from != nil {
+		before = *from
+	}
+	if to != nil {
+		after = *to
+	}
+	changes := make(map[string]interface{})
+	change := func(field string, oldValue, newValue interface{}) {
+		changes[field] = map[string]interface{}{"from": oldValue, "to": newValue}
+	}
+	if from == nil || to == nil {
+		// 商品在其中一个版本不存在时，记录存在状态的变化
+		change("exists", from != nil, to != nil)
+	}
+	if before.Name != after.Name {
+		change("name", before.Name, after.Name)
+	}
+	if before.Price != after.Price {
+		change("price", before.Price, after.Price)
+	}
+	if before.Site != after.Site {
+		change("site", before.Site, after.Site)
+	}
+	return changes
+}
+
+// 解析RFC3339格式的时间参数
+func parseTime(field, value string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return t, apperror.InvalidFields([]apperror.FieldError{{
+			Field:  field,
+			Code:   i18n.FieldInvalidType,
+			Detail: i18n.NewError(i18n.FieldInvalidType, "RFC3339"),
+		}})
+	}
+	return t, nil
+}
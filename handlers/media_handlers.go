@@ -0,0 +1,319 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"miHttpServer/apperror"
+	"miHttpServer/caches"
+	"miHttpServer/config"
+	"miHttpServer/database"
+	"miHttpServer/i18n"
+	"miHttpServer/logger"
+	"miHttpServer/middlewares"
+	"miHttpServer/models"
+	"miHttpServer/storage"
+	"miHttpServer/utils"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// 媒体文件类型对应的扩展名
+var mediaExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// 上传商品的媒体文件（POST /:app_local/item/:item_id/media）
+// 请求为multipart/form-data，文件字段为file；文件类型按内容检测，图片会生成缩略图
+func UploadItemMedia(ctx *gin.Context) {
+	item_id, err := parseItemID(ctx)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	data, contentType, err := readMediaFile(ctx)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	var thumbnail *storage.Thumbnail
+	if strings.HasPrefix(contentType, "image/") {
+		thumbnail, err = storage.MakeThumbnail(data, config.Configs.Media.ThumbnailSize)
+		if err != nil {
+			utils.RespondError(ctx, apperror.InvalidMedia(err))
+			return
+		}
+	}
+
+	unlock, err := lockItemID(ctx, item_id)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	// 确保最后释放锁
+	defer unlock()
+
+	var item models.Item
+	exist, err := database.QueryItem(ctx.Request.Context(), item_id, &item)
+	if err != nil {
+		utils.RespondError(ctx, apperror.Internal(i18n.QueryFailed, err))
+		return
+	}
+	if !exist || !itemVisible(ctx, models.NewItemCache(item)) {
+		utils.RespondError(ctx, apperror.ItemNotFound(item_id))
+		return
+	}
+	maxPerItem := config.Configs.Media.MaxPerItem
+	if maxPerItem > 0 && len(item.Media) >= maxPerItem {
+		utils.RespondError(ctx, apperror.MediaLimitExceeded(maxPerItem))
+		return
+	}
+
+	mediaID := uuid.New().String()
+	media := models.ItemMedia{
+		MediaID:     mediaID,
+		ItemID:      item_id,
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		BlobKey:     itemMediaPrefix(item_id) + mediaID + mediaExtensions[contentType],
+	}
+	if thumbnail != nil {
+		media.Width, media.Height = thumbnail.Width, thumbnail.Height
+		media.ThumbnailKey = itemMediaPrefix(item_id) + mediaID + "_thumb" + thumbnail.Ext
+	}
+	if err := saveMediaBlobs(ctx, &media, data, thumbnail); err != nil {
+		utils.RespondError(ctx, apperror.Internal(i18n.InsertFailed, err))
+		return
+	}
+	if err := database.InsertMedia(ctx.Request.Context(), &media); err != nil {
+		removeMediaBlobs(ctx, media)
+		utils.RespondError(ctx, apperror.Internal(i18n.InsertFailed, err))
+		return
+	}
+
+	// 缓存中的商品信息包含媒体文件，直接删除缓存
+	invalidateItemCache(ctx, item_id)
+
+	response := utils.DealSuccess(ctx, map[string]interface{}{"media_info": media})
+	ctx.JSON(http.StatusOK, response)
+	logger.Printf(ctx, "商品%d上传媒体文件%s，类型：%s，大小：%d字节", item_id, mediaID, contentType, media.Size)
+}
+
+// 查询商品的媒体文件（GET /:app_local/item/:item_id/media）
+func QueryItemMedia(ctx *gin.Context) {
+	item_id, err := parseItemID(ctx)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	itemCache, err := queryVisibleItem(ctx, item_id)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	media := itemCache.Media
+	if media == nil {
+		media = []models.ItemMedia{}
+	}
+	response := utils.DealSuccess(ctx, map[string]interface{}{"media": media})
+	ctx.JSON(http.StatusOK, response)
+}
+
+// 删除商品的媒体文件（DELETE /:app_local/item/:item_id/media/:media_id）
+func DeleteItemMedia(ctx *gin.Context) {
+	item_id, err := parseItemID(ctx)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	mediaID := ctx.Param("media_id")
+	if _, err := queryVisibleItem(ctx, item_id); err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+
+	unlock, err := lockItemID(ctx, item_id)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	// 确保最后释放锁
+	defer unlock()
+
+	media, err := database.DeleteMedia(ctx.Request.Context(), item_id, mediaID)
+	if err != nil {
+		utils.RespondError(ctx, apperror.Internal(i18n.DeleteFailed, err))
+		return
+	}
+	if media == nil {
+		utils.RespondError(ctx, apperror.MediaNotFound(item_id, mediaID))
+		return
+	}
+	removeMediaBlobs(ctx, *media)
+	invalidateItemCache(ctx, item_id)
+
+	response := utils.DealSuccess(ctx, map[string]interface{}{"media_id": mediaID})
+	ctx.JSON(http.StatusOK, response)
+	logger.Printf(ctx, "商品%d删除媒体文件%s", item_id, mediaID)
+}
+
+// 下载媒体文件（GET /media/*key），本地存储时由服务直接提供文件
+// 下载地址中没有站点，商品在任一站点已发布时可以下载，未发布的商品只有管理员可以下载
+func ServeMedia(ctx *gin.Context) {
+	key := strings.TrimPrefix(ctx.Param("key"), "/")
+	published, err := mediaPublished(ctx, key)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	if !published && !middlewares.IsAdmin(ctx) {
+		utils.RespondError(ctx, apperror.Request(http.StatusNotFound, i18n.MediaNotFound, i18n.NewError(i18n.CheckURL)))
+		return
+	}
+	file, err := storage.Blobs.Open(key)
+	if errors.Is(err, storage.ErrBlobNotFound) || errors.Is(err, storage.ErrInvalidKey) {
+		utils.RespondError(ctx, apperror.Request(http.StatusNotFound, i18n.MediaNotFound, i18n.NewError(i18n.CheckURL)))
+		return
+	}
+	if err != nil {
+		utils.RespondError(ctx, apperror.Internal(i18n.QueryFailed, err))
+		return
+	}
+	defer file.Close()
+	// key中包含media_id，同一个key的内容不会变化，已发布商品的文件可以长期缓存
+	if published {
+		ctx.Header("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		ctx.Header("Cache-Control", "private, no-store")
+	}
+	http.ServeContent(ctx.Writer, ctx.Request, path.Base(key), time.Time{}, file)
+}
+
+// 读取上传的文件，检查大小和类型，返回文件内容和检测到的类型
+func readMediaFile(ctx *gin.Context) ([]byte, string, error) {
+	if err := checkContentType(ctx, gin.MIMEMultipartPOSTForm); err != nil {
+		return nil, "", err
+	}
+	maxBytes := config.Configs.Media.MaxUploadBytes
+	// 限制请求体大小，multipart的边界和表单字段额外预留1MB
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxBytes+1<<20)
+	fileHeader, err := ctx.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, "", apperror.MediaTooLarge(maxBytes)
+		}
+		if errors.Is(err, http.ErrMissingFile) {
+			return nil, "", apperror.InvalidFields([]apperror.FieldError{{
+				Field:  "file",
+				Code:   i18n.FieldRequired,
+				Detail: i18n.NewError(i18n.FieldRequired),
+			}})
+		}
+		return nil, "", apperror.InvalidBody(err)
+	}
+	if fileHeader.Size > maxBytes {
+		return nil, "", apperror.MediaTooLarge(maxBytes)
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, "", apperror.InvalidBody(err)
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxBytes+1))
+	if err != nil {
+		return nil, "", apperror.InvalidBody(err)
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, "", apperror.MediaTooLarge(maxBytes)
+	}
+
+	// 不信任客户端声明的类型，按文件内容检测
+	contentType, _, _ := strings.Cut(http.DetectContentType(data), ";")
+	allowed := config.Configs.Media.AllowedTypes
+	if !slices.Contains(allowed, contentType) {
+		return nil, "", apperror.Request(
+			http.StatusUnsupportedMediaType,
+			i18n.UnsupportedMediaType,
+			i18n.NewError(i18n.UnsupportedMediaTypeDetail, strings.Join(allowed, ", ")),
+		)
+	}
+	return data, contentType, nil
+}
+
+// 保存媒体文件和缩略图，失败时删除已保存的文件
+func saveMediaBlobs(ctx *gin.Context, media *models.ItemMedia, data []byte, thumbnail *storage.Thumbnail) error {
+	if err := storage.Blobs.Put(media.BlobKey, bytes.NewReader(data)); err != nil {
+		return err
+	}
+	if thumbnail != nil {
+		if err := storage.Blobs.Put(media.ThumbnailKey, bytes.NewReader(thumbnail.Data)); err != nil {
+			removeMediaBlobs(ctx, *media)
+			return err
+		}
+	}
+	return nil
+}
+
+// 删除媒体文件和缩略图，失败时只记录日志
+func removeMediaBlobs(ctx *gin.Context, media models.ItemMedia) {
+	for _, key := range []string{media.BlobKey, media.ThumbnailKey} {
+		if key == "" {
+			continue
+		}
+		if err := storage.Blobs.Delete(key); err != nil {
+			logger.Printf(ctx, "删除媒体文件%s失败: %s", key, err.Error())
+		}
+	}
+}
+
+// 商品删除后删除它的全部媒体文件，失败时只记录日志
+func removeItemMediaBlobs(ctx *gin.Context, item_id int64) {
+	if err := storage.Blobs.DeletePrefix(itemMediaPrefix(item_id)); err != nil {
+		logger.Printf(ctx, "删除商品%d的媒体文件失败: %s", item_id, err.Error())
+	}
+}
+
+// 商品的媒体文件在存储中的key前缀
+func itemMediaPrefix(item_id int64) string {
+	return fmt.Sprintf("items/%d/", item_id)
+}
+
+// 媒体文件所属的商品是否在任一站点已发布，key不是商品的媒体文件或商品不存在时返回false
+func mediaPublished(ctx *gin.Context, key string) (bool, error) {
+	parts := strings.SplitN(key, "/", 3)
+	if len(parts) != 3 || parts[0] != "items" {
+		return false, nil
+	}
+	item_id, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return false, nil
+	}
+	found, backfill, err := queryItemCaches(ctx, []int64{item_id})
+	if err != nil {
+		return false, err
+	}
+	// 回填redis缓存
+	defer backfill()
+	itemCache, ok := found[item_id]
+	return ok && len(publishedSites(itemCache.Statuses)) > 0, nil
+}
+
+// 删除商品的本地缓存和Redis缓存
+func invalidateItemCache(ctx *gin.Context, item_id int64) {
+	caches.DeleteLocalCache(item_id)
+	if err := caches.DeleteItemCache(ctx.Request.Context(), item_id); err != nil {
+		logger.Printf(ctx, "删除商品%d的Redis缓存失败: %s", item_id, err.Error())
+	}
+}
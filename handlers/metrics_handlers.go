@@ -0,0 +1,16 @@
+package handlers
+
+import (
+	"bytes"
+	"miHttpServer/metrics"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 输出Prometheus文本格式的监控指标（GET /metrics）
+func Metrics(ctx *gin.Context) {
+	var buf bytes.Buffer
+	metrics.WriteText(&buf)
+	ctx.Data(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", buf.Bytes())
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"errors"
+	"miHttpServer/apperror"
+	"miHttpServer/database"
+	"miHttpServer/i18n"
+	"miHttpServer/logger"
+	"miHttpServer/models"
+	"miHttpServer/utils"
+	"miHttpServer/validation"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 增加商品在站点的定时价格（POST /:app_local/item/:item_id/prices）
+// start_at和end_at为站点的当地时间，在此期间商品在该站点的实际售价为price
+func AddPriceSchedule(ctx *gin.Context) {
+	item_id, err := parseItemID(ctx)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	data, err := ctx.GetRawData()
+	if err != nil {
+		utils.RespondError(ctx, apperror.InvalidJSON(err))
+		return
+	}
+	site := ctx.Param("app_local")
+	location, localCountry := siteLocation(site)
+	schedule, err := validation.DecodePriceSchedule(data, location, time.Now())
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	schedule.ItemID = item_id
+	schedule.Site = site
+
+	if _, err := queryVisibleItem(ctx, item_id); err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+
+	err = database.InsertPriceSchedule(ctx.Request.Context(), &schedule)
+	if errors.Is(err, database.ErrItemNotFound) {
+		utils.RespondError(ctx, apperror.ItemNotFound(item_id))
+		return
+	}
+	if errors.Is(err, database.ErrPriceScheduleOverlap) {
+		utils.RespondError(ctx, apperror.PriceScheduleOverlap(item_id, site))
+		return
+	}
+	if err != nil {
+		utils.RespondError(ctx, apperror.Internal(i18n.InsertFailed, err))
+		return
+	}
+	// 已经开始生效的定时价格需要立即删除缓存，未开始的由后台任务在开始时删除
+	if schedule.Status == models.PriceActive {
+		invalidateItemCache(ctx, item_id)
+	}
+
+	response := utils.DealSuccess(ctx, map[string]interface{}{"schedule_info": localSchedule(schedule, location)})
+	ctx.JSON(http.StatusOK, response)
+	logger.Printf(ctx, "%s站点增加商品%d的定时价格%d，价格：%v，%s至%s", localCountry, item_id, schedule.ScheduleID, schedule.Price,
+		schedule.StartAt.In(location).Format(time.DateTime), schedule.EndAt.In(location).Format(time.DateTime))
+}
+
+// 查询商品在站点的定时价格（GET /:app_local/item/:item_id/prices）
+func QueryPriceSchedules(ctx *gin.Context) {
+	item_id, err := parseItemID(ctx)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	if _, err := queryVisibleItem(ctx, item_id); err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	site := ctx.Param("app_local")
+	schedules, err := database.QueryPriceSchedules(ctx.Request.Context(), item_id, site)
+	if err != nil {
+		utils.RespondError(ctx, apperror.Internal(i18n.QueryFailed, err))
+		return
+	}
+	location, _ := siteLocation(site)
+	for i := range schedules {
+		schedules[i] = localSchedule(schedules[i], location)
+	}
+	if schedules == nil {
+		schedules = []models.PriceSchedule{}
+	}
+	response := utils.DealSuccess(ctx, map[string]interface{}{"schedules": schedules})
+	ctx.JSON(http.StatusOK, response)
+}
+
+// 取消商品在站点的定时价格（DELETE /:app_local/item/:item_id/prices/:schedule_id）
+// 生效中的定时价格取消后立即恢复原价
+func CancelPriceSchedule(ctx *gin.Context) {
+	item_id, err := parseItemID(ctx)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	scheduleID, err := strconv.ParseInt(ctx.Param("schedule_id"), 10, 64)
+	if err != nil {
+		utils.RespondError(ctx, apperror.InvalidFields([]apperror.FieldError{invalidTypeField("schedule_id", "int64")}))
+		return
+	}
+	if _, err := queryVisibleItem(ctx, item_id); err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	site := ctx.Param("app_local")
+	schedule, err := database.CancelPriceSchedule(ctx.Request.Context(), item_id, site, scheduleID)
+	if errors.Is(err, database.ErrPriceScheduleFinished) {
+		utils.RespondError(ctx, apperror.PriceScheduleFinished(scheduleID, schedule.Status))
+		return
+	}
+	if err != nil {
+		utils.RespondError(ctx, apperror.Internal(i18n.UpdateFailed, err))
+		return
+	}
+	if schedule == nil {
+		utils.RespondError(ctx, apperror.PriceScheduleNotFound(item_id, site, scheduleID))
+		return
+	}
+	invalidateItemCache(ctx, item_id)
+
+	location, localCountry := siteLocation(site)
+	response := utils.DealSuccess(ctx, map[string]interface{}{"schedule_info": localSchedule(*schedule, location)})
+	ctx.JSON(http.StatusOK, response)
+	logger.Printf(ctx, "%s站点取消商品%d的定时价格%d", localCountry, item_id, scheduleID)
+}
+
+// 将定时价格的开始和结束时间转换为站点的当地时间
+func localSchedule(schedule models.PriceSchedule, location *time.Location) models.PriceSchedule {
+	schedule.StartAt = schedule.StartAt.In(location)
+	schedule.EndAt = schedule.EndAt.In(location)
+	return schedule
+}
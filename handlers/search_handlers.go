@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"miHttpServer/apperror"
+	"miHttpServer/config"
+	"miHttpServer/i18n"
+	"miHttpServer/logger"
+	"miHttpServer/search"
+	"miHttpServer/utils"
+	"miHttpServer/validation"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 按商品名称全文搜索（GET /:app_local/items/search?q=关键词&page=1&page_size=20）
+// 使用内存中的倒排索引，结果按相关度排序，并返回高亮后的名称；非管理员只能搜索到在当前站点已发布的商品
+func SearchItems(ctx *gin.Context) {
+	cfg := config.Configs.Search
+	query := strings.TrimSpace(ctx.Query("q"))
+	queryRule := validation.Rule{
+		Field:  "q",
+		Checks: []validation.Check{validation.Required(), validation.Length(1, cfg.MaxQueryLength)},
+	}
+	err := validation.Validate(map[string]interface{}{"q": query}, []validation.Rule{queryRule}, false)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	page, pageSize, err := parsePagination(ctx, cfg.DefaultPageSize, cfg.MaxPageSize)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+
+	results, total := search.Items.Search(query, publishedSiteFilter(ctx), (page-1)*pageSize, pageSize, cfg.HighlightPre, cfg.HighlightPost)
+	searchInfo := make(map[string]interface{})
+	searchInfo["items"] = results
+	searchInfo["page"] = page
+	searchInfo["page_size"] = pageSize
+	searchInfo["total"] = total
+	response := utils.DealSuccess(ctx, searchInfo)
+	ctx.JSON(http.StatusOK, response)
+}
+
+// 从MySQL重建搜索索引（POST /:app_local/items/search/rebuild），只允许管理员调用
+func RebuildSearchIndex(ctx *gin.Context) {
+	n, err := search.Rebuild(ctx)
+	if err != nil {
+		utils.RespondError(ctx, apperror.Internal(i18n.QueryFailed, err))
+		return
+	}
+	response := utils.DealSuccess(ctx, map[string]interface{}{"indexed": n})
+	ctx.JSON(http.StatusOK, response)
+	logger.Printf(ctx, "重建商品搜索索引，共%d个商品", n)
+}
@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"errors"
+	"miHttpServer/apperror"
+	"miHttpServer/config"
+	"miHttpServer/database"
+	"miHttpServer/i18n"
+	"miHttpServer/logger"
+	"miHttpServer/models"
+	"miHttpServer/utils"
+	"miHttpServer/validation"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 增加商品的SKU（POST /:app_local/item/:item_id/skus）
+func AddSKU(ctx *gin.Context) {
+	item_id, err := parseItemID(ctx)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	request, err := bindSKURequest(ctx)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	// 商品不存在或对调用方不可见时返回商品不存在，与查询SKU一致
+	if _, err := queryVisibleItem(ctx, item_id); err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+
+	unlock, err := lockItemID(ctx, item_id)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	// 确保最后释放锁
+	defer unlock()
+
+	var item models.Item
+	exist, err := database.QueryItem(ctx.Request.Context(), item_id, &item)
+	if err != nil {
+		utils.RespondError(ctx, apperror.Internal(i18n.QueryFailed, err))
+		return
+	}
+	if !exist {
+		utils.RespondError(ctx, apperror.ItemNotFound(item_id))
+		return
+	}
+	maxSKUs := config.Configs.Item.MaxSKUs
+	if maxSKUs > 0 && len(item.SKUs) >= maxSKUs {
+		utils.RespondError(ctx, apperror.SKULimitExceeded(maxSKUs))
+		return
+	}
+
+	sku := newSKU(item_id, request)
+	if err := database.InsertSKU(ctx.Request.Context(), &sku, auditInfo(ctx)); err != nil {
+		utils.RespondError(ctx, skuWriteError(err, item_id, sku.Code, i18n.InsertFailed))
+		return
+	}
+	// 缓存中的商品信息包含SKU，直接删除缓存
+	invalidateItemCache(ctx, item_id)
+
+	response := utils.DealSuccess(ctx, map[string]interface{}{"sku_info": sku})
+	ctx.JSON(http.StatusOK, response)
+	logger.Printf(ctx, "商品%d增加SKU %d，编码：%s", item_id, sku.SKUID, sku.Code)
+}
+
+// 修改商品的SKU（POST /:app_local/item/:item_id/skus/:sku_id）
+func UpdateSKU(ctx *gin.Context) {
+	item_id, err := parseItemID(ctx)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	sku_id, err := parseSKUID(ctx)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	request, err := bindSKURequest(ctx)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	// 商品不存在或对调用方不可见时返回商品不存在，与查询SKU一致
+	if _, err := queryVisibleItem(ctx, item_id); err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+
+	unlock, err := lockItemID(ctx, item_id)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	// 确保最后释放锁
+	defer unlock()
+
+	sku := newSKU(item_id, request)
+	exist, err := database.UpdateSKU(ctx.Request.Context(), item_id, sku_id, &sku, auditInfo(ctx))
+	if err != nil {
+		utils.RespondError(ctx, skuWriteError(err, item_id, sku.Code, i18n.UpdateFailed))
+		return
+	}
+	if !exist {
+		utils.RespondError(ctx, apperror.SKUNotFound(item_id, sku_id))
+		return
+	}
+	invalidateItemCache(ctx, item_id)
+
+	response := utils.DealSuccess(ctx, map[string]interface{}{"sku_info": sku})
+	ctx.JSON(http.StatusOK, response)
+	logger.Printf(ctx, "商品%d修改SKU %d，编码：%s", item_id, sku_id, sku.Code)
+}
+
+// 查询商品的全部SKU（GET /:app_local/item/:item_id/skus）
+// 从商品的缓存中读取，依次查询本地缓存、redis和MySQL
+func QuerySKUs(ctx *gin.Context) {
+	item_id, err := parseItemID(ctx)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	itemCache, err := queryVisibleItem(ctx, item_id)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	skus := itemCache.SKUs
+	if skus == nil {
+		skus = []models.ItemSKU{}
+	}
+	response := utils.DealSuccess(ctx, map[string]interface{}{"skus": skus})
+	ctx.JSON(http.StatusOK, response)
+}
+
+// 查询商品的一个SKU（GET /:app_local/item/:item_id/skus/:sku_id）
+func QuerySKU(ctx *gin.Context) {
+	item_id, err := parseItemID(ctx)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	sku_id, err := parseSKUID(ctx)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	itemCache, err := queryVisibleItem(ctx, item_id)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	for _, sku := range itemCache.SKUs {
+		if sku.SKUID == sku_id {
+			response := utils.DealSuccess(ctx, map[string]interface{}{"sku_info": sku})
+			ctx.JSON(http.StatusOK, response)
+			return
+		}
+	}
+	utils.RespondError(ctx, apperror.SKUNotFound(item_id, sku_id))
+}
+
+// 删除商品的SKU（DELETE /:app_local/item/:item_id/skus/:sku_id）
+func DeleteSKU(ctx *gin.Context) {
+	item_id, err := parseItemID(ctx)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	sku_id, err := parseSKUID(ctx)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	// 商品不存在或对调用方不可见时返回商品不存在，与查询SKU一致
+	if _, err := queryVisibleItem(ctx, item_id); err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+
+	unlock, err := lockItemID(ctx, item_id)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	// 确保最后释放锁
+	defer unlock()
+
+	n, err := database.DeleteSKU(ctx.Request.Context(), item_id, sku_id, auditInfo(ctx))
+	if err != nil {
+		utils.RespondError(ctx, skuWriteError(err, item_id, "", i18n.DeleteFailed))
+		return
+	}
+	if n == 0 {
+		utils.RespondError(ctx, apperror.SKUNotFound(item_id, sku_id))
+		return
+	}
+	invalidateItemCache(ctx, item_id)
+
+	response := utils.DealSuccess(ctx, map[string]interface{}{"sku_id": sku_id})
+	ctx.JSON(http.StatusOK, response)
+	logger.Printf(ctx, "商品%d删除SKU %d", item_id, sku_id)
+}
+
+// 查询商品及其SKU，商品不存在或对调用方不可见时返回商品不存在
+func queryVisibleItem(ctx *gin.Context, item_id int64) (models.ItemCache, error) {
+	found, backfill, err := queryItemCaches(ctx, []int64{item_id})
+	if err != nil {
+		return models.ItemCache{}, err
+	}
+	// 回填redis缓存
+	defer backfill()
+	itemCache, ok := found[item_id]
+	if !ok || !itemVisible(ctx, itemCache) {
+		return models.ItemCache{}, apperror.ItemNotFound(item_id)
+	}
+	return itemCache, nil
+}
+
+// 解析并校验增加和修改SKU时请求的json
+func bindSKURequest(ctx *gin.Context) (models.SKURequest, error) {
+	data, err := ctx.GetRawData()
+	if err != nil {
+		return models.SKURequest{}, apperror.InvalidJSON(err)
+	}
+	return validation.DecodeSKU(data)
+}
+
+// 解析路径参数中的sku_id
+func parseSKUID(ctx *gin.Context) (int64, error) {
+	sku_id, err := strconv.ParseInt(ctx.Param("sku_id"), 10, 64)
+	if err != nil {
+		return 0, apperror.InvalidFields([]apperror.FieldError{invalidTypeField("sku_id", "int64")})
+	}
+	return sku_id, nil
+}
+
+// 根据请求创建SKU
+func newSKU(item_id int64, request models.SKURequest) models.ItemSKU {
+	return models.ItemSKU{
+		ItemID:     item_id,
+		Code:       request.Code,
+		Barcode:    request.Barcode,
+		Price:      request.Price,
+		Attributes: request.Attributes,
+	}
+}
+
+// 写入SKU失败时的错误，商品已被删除时返回商品不存在，编码重复时返回冲突
+func skuWriteError(err error, item_id int64, code, fallback string) error {
+	if errors.Is(err, database.ErrItemNotFound) {
+		return apperror.ItemNotFound(item_id)
+	}
+	if database.IsDuplicateKey(err) {
+		return apperror.SKUCodeConflict(code)
+	}
+	return apperror.Internal(fallback, err)
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"miHttpServer/caches"
+	"miHttpServer/i18n"
+	"miHttpServer/models"
+	"net/http"
+	"testing"
+)
+
+func TestSKUWriteVisibility(t *testing.T) {
+	// 商品只在jp站点发布，uk站点的非管理员调用方不能修改它的SKU，不会访问MySQL
+	caches.AddLocalCache(8, models.ItemCache{ItemID: 8, Name: "a", Statuses: map[string]string{"jp": models.StatusPublished}})
+	defer caches.DeleteLocalCache(8)
+
+	body := `{"code": "A-1", "price": 1}`
+	requests := []struct {
+		method, path, body string
+	}{
+		{http.MethodPost, "/uk/item/8/skus", body},
+		{http.MethodPost, "/uk/item/8/skus/1", body},
+		{http.MethodDelete, "/uk/item/8/skus/1", ""},
+	}
+	for _, r := range requests {
+		recorder, response := serve(t, r.method, r.path, "application/json", r.body)
+		if recorder.Code != http.StatusNotFound || response.ErrorCode != i18n.ItemNotFound {
+			t.Errorf("%s %s的状态码为%d，error_code为%q，应为404和ITEM_NOT_FOUND", r.method, r.path, recorder.Code, response.ErrorCode)
+		}
+	}
+	if err := testSQL.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
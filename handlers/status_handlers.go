@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"miHttpServer/apperror"
+	"miHttpServer/database"
+	"miHttpServer/i18n"
+	"miHttpServer/logger"
+	"miHttpServer/middlewares"
+	"miHttpServer/models"
+	"miHttpServer/search"
+	"miHttpServer/utils"
+	"miHttpServer/validation"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 修改商品在站点的发布状态（POST /:app_local/item/:item_id/status，只允许管理员）
+// 状态只能按models.StatusTransitions转换，状态不变时直接返回成功
+func TransitionItemStatus(ctx *gin.Context) {
+	item_id, err := parseItemID(ctx)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	data, err := ctx.GetRawData()
+	if err != nil {
+		utils.RespondError(ctx, apperror.InvalidJSON(err))
+		return
+	}
+	request, err := validation.DecodeStatus(data)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+
+	unlock, err := lockItemID(ctx, item_id)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	// 确保最后释放锁
+	defer unlock()
+
+	var item models.Item
+	exist, err := database.QueryItem(ctx.Request.Context(), item_id, &item)
+	if err != nil {
+		utils.RespondError(ctx, apperror.Internal(i18n.QueryFailed, err))
+		return
+	}
+	if !exist {
+		utils.RespondError(ctx, apperror.ItemNotFound(item_id))
+		return
+	}
+	site := ctx.Param("app_local")
+	from := models.NewItemCache(item).StatusOf(site)
+	if from != request.Status {
+		allowed := models.StatusTransitions[from]
+		if !slices.Contains(allowed, request.Status) {
+			utils.RespondError(ctx, apperror.InvalidStatusTransition(item_id, site, from, request.Status, allowed))
+			return
+		}
+		if err := database.SetItemStatus(ctx.Request.Context(), item, site, from, request.Status, auditInfo(ctx)); err != nil {
+			utils.RespondError(ctx, apperror.Internal(i18n.UpdateFailed, err))
+			return
+		}
+
+		// 更新搜索索引中已发布的站点，并删除缓存
+		if item.Statuses == nil {
+			item.Statuses = make(map[string]string)
+		}
+		item.Statuses[site] = request.Status
+		search.SetPublishedSites(item_id, publishedSites(item.Statuses))
+		invalidateItemCache(ctx, item_id)
+		_, localCountry := siteLocation(site)
+		logger.Printf(ctx, "%s站点商品%d的状态从%s修改为%s", localCountry, item_id, from, request.Status)
+	}
+
+	statusInfo := make(map[string]interface{})
+	statusInfo["item_id"] = item_id
+	statusInfo["site"] = site
+	statusInfo["from"] = from
+	statusInfo["status"] = request.Status
+	response := utils.DealSuccess(ctx, statusInfo)
+	ctx.JSON(http.StatusOK, response)
+}
+
+// 商品已发布的站点
+func publishedSites(statuses map[string]string) []string {
+	var sites []string
+	for site, status := range statuses {
+		if status == models.StatusPublished {
+			sites = append(sites, site)
+		}
+	}
+	return sites
+}
+
+// 列表和搜索只返回已发布商品时使用的站点，管理员可以看到所有商品，返回空字符串
+func publishedSiteFilter(ctx *gin.Context) string {
+	if middlewares.IsAdmin(ctx) {
+		return ""
+	}
+	return ctx.Param("app_local")
+}
+
+// 商品对调用方是否可见：管理员可以看到所有商品，其他调用方只能看到在当前站点已发布的商品
+func itemVisible(ctx *gin.Context, itemCache models.ItemCache) bool {
+	return middlewares.IsAdmin(ctx) || itemCache.StatusOf(ctx.Param("app_local")) == models.StatusPublished
+}
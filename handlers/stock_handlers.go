@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"miHttpServer/apperror"
+	"miHttpServer/config"
+	"miHttpServer/database"
+	"miHttpServer/i18n"
+	"miHttpServer/logger"
+	"miHttpServer/models"
+	"miHttpServer/utils"
+	"miHttpServer/validation"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// 查询商品在站点的库存（GET /:app_local/item/:item_id/stock）
+func QueryStock(ctx *gin.Context) {
+	item_id, err := parseItemID(ctx)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	// 商品不存在或对调用方不可见时返回商品不存在，没有库存记录时库存为0
+	if _, err := queryVisibleItem(ctx, item_id); err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	site := ctx.Param("app_local")
+	stock := models.ItemStock{ItemID: item_id, Site: site}
+	if _, err := database.QueryStock(ctx.Request.Context(), item_id, site, &stock); err != nil {
+		utils.RespondError(ctx, apperror.Internal(i18n.QueryFailed, err))
+		return
+	}
+	response := utils.DealSuccess(ctx, map[string]interface{}{"stock_info": stock})
+	ctx.JSON(http.StatusOK, response)
+}
+
+// 设置商品在站点的可用库存（PUT /:app_local/item/:item_id/stock）
+func SetStock(ctx *gin.Context) {
+	item_id, err := parseItemID(ctx)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	data, err := ctx.GetRawData()
+	if err != nil {
+		utils.RespondError(ctx, apperror.InvalidJSON(err))
+		return
+	}
+	request, err := validation.DecodeStock(data)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	if _, err := queryVisibleItem(ctx, item_id); err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+
+	// 和预留库存使用同一把分布式锁
+	site := ctx.Param("app_local")
+	unlock, err := lockStock(ctx, item_id, site)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	// 确保最后释放锁
+	defer unlock()
+
+	stock, exist, err := database.SetStock(ctx.Request.Context(), item_id, site, request.Quantity)
+	if err != nil {
+		utils.RespondError(ctx, apperror.Internal(i18n.UpdateFailed, err))
+		return
+	}
+	if !exist {
+		utils.RespondError(ctx, apperror.ItemNotFound(item_id))
+		return
+	}
+	response := utils.DealSuccess(ctx, map[string]interface{}{"stock_info": stock})
+	ctx.JSON(http.StatusOK, response)
+	logger.Printf(ctx, "设置商品库存，item_id: %d，站点：%s，可用库存：%d", item_id, site, request.Quantity)
+}
+
+// 预留库存（POST /:app_local/item/:item_id/stock/reservations）
+// 预留成功后需要在过期之前确认或释放，过期未处理的预留会被自动释放
+func ReserveStock(ctx *gin.Context) {
+	item_id, err := parseItemID(ctx)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	data, err := ctx.GetRawData()
+	if err != nil {
+		utils.RespondError(ctx, apperror.InvalidJSON(err))
+		return
+	}
+	request, err := validation.DecodeReservation(data)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	ttl := request.TTLSec
+	if ttl == 0 {
+		ttl = config.Configs.Stock.ReservationTTLSec
+	}
+	// 未在当前站点发布的商品不能预留
+	if _, err := queryVisibleItem(ctx, item_id); err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+
+	site := ctx.Param("app_local")
+	unlock, err := lockStock(ctx, item_id, site)
+	if err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	// 确保最后释放锁
+	defer unlock()
+
+	reservation := models.StockReservation{
+		ReservationID: uuid.New().String(),
+		ItemID:        item_id,
+		Site:          site,
+		Quantity:      request.Quantity,
+		ExpiresAt:     time.Now().Add(time.Duration(ttl) * time.Second),
+	}
+	reserved, available, err := database.ReserveStock(ctx.Request.Context(), &reservation)
+	if errors.Is(err, database.ErrItemNotFound) {
+		utils.RespondError(ctx, apperror.ItemNotFound(item_id))
+		return
+	}
+	if err != nil {
+		utils.RespondError(ctx, apperror.Internal(i18n.UpdateFailed, err))
+		return
+	}
+	if !reserved {
+		utils.RespondError(ctx, apperror.InsufficientStock(item_id, available, request.Quantity))
+		return
+	}
+	response := utils.DealSuccess(ctx, map[string]interface{}{"reservation_info": reservation})
+	ctx.JSON(http.StatusOK, response)
+	logger.Printf(ctx, "预留库存，item_id: %d，站点：%s，数量：%d，reservation_id：%s", item_id, site, request.Quantity, reservation.ReservationID)
+}
+
+// 确认预留（POST /:app_local/reservations/:reservation_id/confirm），扣减已预留的库存
+func ConfirmReservation(ctx *gin.Context) {
+	finishReservation(ctx, models.ReservationConfirmed)
+}
+
+// 释放预留（POST /:app_local/reservations/:reservation_id/release），归还已预留的库存
+func ReleaseReservation(ctx *gin.Context) {
+	finishReservation(ctx, models.ReservationReleased)
+}
+
+// 将预留修改为确认或释放状态，重复提交相同的操作直接返回成功
+func finishReservation(ctx *gin.Context, status string) {
+	reservationID := ctx.Param("reservation_id")
+	site := ctx.Param("app_local")
+	reservation, exist, err := database.FinishReservation(ctx.Request.Context(), reservationID, site, status)
+	if err != nil {
+		utils.RespondError(ctx, apperror.Internal(i18n.UpdateFailed, err))
+		return
+	}
+	if !exist {
+		utils.RespondError(ctx, apperror.ReservationNotFound(reservationID))
+		return
+	}
+	switch reservation.Status {
+	case status:
+	case models.ReservationExpired:
+		utils.RespondError(ctx, apperror.ReservationExpired(reservationID))
+		return
+	default:
+		utils.RespondError(ctx, apperror.ReservationConflict(reservationID, reservation.Status))
+		return
+	}
+	response := utils.DealSuccess(ctx, map[string]interface{}{"reservation_info": reservation})
+	ctx.JSON(http.StatusOK, response)
+	logger.Printf(ctx, "库存预留%s修改为%s，item_id: %d，数量：%d", reservationID, status, reservation.ItemID, reservation.Quantity)
+}
+
+// 按商品和站点获取库存的分布式锁，返回释放锁的函数
+func lockStock(ctx *gin.Context, itemID int64, site string) (func(), error) {
+	return lock(ctx, fmt.Sprintf("stock_lock_%d_%s", itemID, site))
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"miHttpServer/caches"
+	"miHttpServer/i18n"
+	"miHttpServer/models"
+	"net/http"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestQueryStockVisibility(t *testing.T) {
+	// 商品只在jp站点发布，uk站点的非管理员调用方看不到
+	caches.AddLocalCache(7, models.ItemCache{ItemID: 7, Name: "a", Statuses: map[string]string{"jp": models.StatusPublished}})
+	defer caches.DeleteLocalCache(7)
+
+	recorder, response := serve(t, http.MethodGet, "/uk/item/7/stock", "", "")
+	if recorder.Code != http.StatusNotFound || response.ErrorCode != i18n.ItemNotFound {
+		t.Fatalf("状态码为%d，error_code为%q，应为404和ITEM_NOT_FOUND", recorder.Code, response.ErrorCode)
+	}
+
+	// 已发布的站点没有库存记录时库存为0
+	testSQL.ExpectQuery("SELECT .* FROM `item_stock`").WillReturnRows(sqlmock.NewRows([]string{"item_id"}))
+	recorder, response = serve(t, http.MethodGet, "/jp/item/7/stock", "", "")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("状态码为%d，应为200: %s", recorder.Code, recorder.Body.String())
+	}
+	stock := response.Data.(map[string]interface{})["stock_info"].(map[string]interface{})
+	if stock["available"] != float64(0) {
+		t.Errorf("available为%v，应为0", stock["available"])
+	}
+	if err := testSQL.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
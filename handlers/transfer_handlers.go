@@ -0,0 +1,427 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"miHttpServer/apperror"
+	"miHttpServer/caches"
+	"miHttpServer/config"
+	"miHttpServer/database"
+	"miHttpServer/i18n"
+	"miHttpServer/logger"
+	"miHttpServer/models"
+	"miHttpServer/search"
+	"miHttpServer/utils"
+	"miHttpServer/validation"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 导入导出支持的格式
+const (
+	formatCSV    = "csv"
+	formatNDJSON = "ndjson"
+)
+
+// 导入导出格式对应的Content-Type
+var formatContentTypes = map[string]string{
+	formatCSV:    "text/csv",
+	formatNDJSON: "application/x-ndjson",
+}
+
+// CSV文件的表头
+var csvHeader = []string{"item_id", "name", "price", "created_at", "updated_at"}
+
+// 导出全部商品信息（GET /:app_local/items/export?format=csv|ndjson）
+// 按item_id分块读取MySQL并流式写出，不会一次性把全部数据加载到内存
+// 管理员导出全部商品，其他调用方只导出在当前站点已发布的商品
+func ExportItems(ctx *gin.Context) {
+	format := ctx.DefaultQuery("format", formatCSV)
+	contentType, ok := formatContentTypes[format]
+	if !ok {
+		utils.RespondError(ctx, invalidFormatError())
+		return
+	}
+	chunkSize := transferChunkSize()
+	publishedSite := publishedSiteFilter(ctx)
+
+	ctx.Header("Content-Type", contentType+"; charset=utf-8")
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="items.%s"`, format))
+	ctx.Status(http.StatusOK)
+
+	var csvWriter *csv.Writer
+	var jsonEncoder *json.Encoder
+	if format == formatCSV {
+		csvWriter = csv.NewWriter(ctx.Writer)
+		csvWriter.Write(csvHeader)
+	} else {
+		jsonEncoder = json.NewEncoder(ctx.Writer)
+	}
+
+	var lastID int64
+	total := 0
+	for {
+		// 客户端断开连接后停止导出
+		if ctx.Request.Context().Err() != nil {
+			logger.Printf(ctx, "导出商品被客户端中断，已导出%d条", total)
+			return
+		}
+		items, err := database.QueryItemsAfter(ctx.Request.Context(), lastID, chunkSize, publishedSite)
+		if err != nil {
+			// 响应头已经发送，只能记录日志并中断响应
+			logger.Printf(ctx, "导出商品失败，已导出%d条: %s", total, err.Error())
+			return
+		}
+		for _, item := range items {
+			if csvWriter != nil {
+				csvWriter.Write([]string{
+					strconv.FormatInt(item.ItemID, 10),
+					item.Name,
+					strconv.FormatFloat(item.Price, 'f', -1, 64),
+					item.CreatedAt.Format(time.RFC3339),
+					item.UpdatedAt.Format(time.RFC3339),
+				})
+			} else {
+				jsonEncoder.Encode(item)
+			}
+		}
+		if csvWriter != nil {
+			csvWriter.Flush()
+		}
+		ctx.Writer.Flush()
+		total += len(items)
+		if len(items) < chunkSize {
+			break
+		}
+		lastID = items[len(items)-1].ItemID
+	}
+	logger.Printf(ctx, "导出商品成功，格式：%s，共%d条", format, total)
+}
+
+// 导入商品信息（POST /:app_local/items/import?format=csv|ndjson&dry_run=true）
+// 逐行校验并分批写入，item_id存在时更新，否则新增；dry_run为true时只校验不写入
+// 某一批写入失败时不影响已提交的批次，响应中返回失败批次每一行的错误
+func ImportItems(ctx *gin.Context) {
+	format := ctx.Query("format")
+	if format == "" {
+		format = formatFromContentType(ctx.ContentType())
+	}
+	if _, ok := formatContentTypes[format]; !ok {
+		utils.RespondError(ctx, invalidFormatError())
+		return
+	}
+	dryRun, _ := strconv.ParseBool(ctx.DefaultQuery("dry_run", "false"))
+	site := ctx.Param("app_local")
+	chunkSize := transferChunkSize()
+	maxErrors := config.Configs.Transfer.MaxReportedErrors
+	audit := auditInfo(ctx)
+
+	var next func() (int, models.ImportItem, error)
+	if format == formatCSV {
+		reader, err := newCSVImportReader(ctx.Request.Body)
+		if err != nil {
+			utils.RespondError(ctx, err)
+			return
+		}
+		next = reader
+	} else {
+		next = newNDJSONImportReader(ctx.Request.Body)
+	}
+
+	total, imported, failed := 0, 0, 0
+	var lineErrors []models.ImportLineError
+	addLineError := func(line int, err error) {
+		failed++
+		if maxErrors <= 0 || len(lineErrors) < maxErrors {
+			lineErrors = append(lineErrors, utils.DealLineError(ctx, line, err))
+		}
+	}
+
+	batch := make([]models.Item, 0, chunkSize)
+	batchLines := make([]int, 0, chunkSize)
+	// 每一批在同一个事务中写入，失败时只回滚这一批，错误记录到这一批的每一行，继续处理后面的数据
+	// imported只统计已提交的行
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		written := len(batch)
+		if !dryRun {
+			rejected, err := upsertImportBatch(ctx, batch, audit)
+			if err != nil {
+				appErr := apperror.From(err)
+				if appErr.Kind == apperror.KindServer && appErr.Cause != nil {
+					logger.Printf(ctx, "导入商品第%d-%d行写入失败: %s", batchLines[0], batchLines[len(batchLines)-1], appErr.Error())
+				}
+				for _, line := range batchLines {
+					addLineError(line, err)
+				}
+				batch, batchLines = batch[:0], batchLines[:0]
+				return
+			}
+			for i, err := range rejected {
+				addLineError(batchLines[i], err)
+			}
+			written -= len(rejected)
+		}
+		imported += written
+		batch, batchLines = batch[:0], batchLines[:0]
+	}
+
+	for {
+		line, row, err := next()
+		if err == io.EOF {
+			break
+		}
+		var lineErr *importLineError
+		if errors.As(err, &lineErr) {
+			total++
+			addLineError(line, lineErr.err)
+			continue
+		}
+		if err != nil {
+			utils.RespondError(ctx, apperror.InvalidBody(err))
+			return
+		}
+		total++
+		if err := validation.ValidateImportItem(row); err != nil {
+			addLineError(line, err)
+			continue
+		}
+		batch = append(batch, models.Item{ItemID: row.ItemID, Name: row.Name, Price: row.Price, Site: site})
+		batchLines = append(batchLines, line)
+		if len(batch) >= chunkSize {
+			flush()
+		}
+	}
+	flush()
+
+	importInfo := make(map[string]interface{})
+	importInfo["dry_run"] = dryRun
+	importInfo["total"] = total
+	importInfo["imported"] = imported
+	importInfo["failed"] = failed
+	importInfo["errors"] = lineErrors
+	response := utils.DealSuccess(ctx, importInfo)
+	ctx.JSON(http.StatusOK, response)
+	logger.Printf(ctx, "导入商品，格式：%s，dry_run：%t，共%d行，成功%d行，失败%d行", format, dryRun, total, imported, failed)
+}
+
+// 写入一批导入的商品：先获取分布式锁，写入后删除被更新商品的缓存
+// 指定了item_id的商品按item_id更新，名称唯一键按商品保存的站点计算；未指定item_id且名称已存在的商品会被更新
+// 名称已被其他商品使用的行不写入，返回这些行在items中的下标和错误，其他行在同一个事务中写入
+func upsertImportBatch(ctx *gin.Context, items []models.Item, audit models.AuditInfo) (map[int]error, error) {
+	var itemIDs []int64
+	for _, item := range items {
+		if item.ItemID > 0 {
+			itemIDs = append(itemIDs, item.ItemID)
+		}
+	}
+	// 已存在的商品使用保存的站点（与UpdateItem相同），已删除的商品按导入的站点重新插入
+	itemSites, err := database.QueryItemSites(ctx.Request.Context(), itemIDs)
+	if err != nil {
+		return nil, apperror.Internal(i18n.QueryFailed, err)
+	}
+	set := make(map[string]struct{})
+	nameKeys := make([]string, 0, len(items))
+	for i := range items {
+		if site, ok := itemSites[items[i].ItemID]; ok {
+			items[i].Site = site
+		}
+		set[itemNameLockKey(items[i].Site, items[i].Name)] = struct{}{}
+		nameKeys = append(nameKeys, database.NameKey(items[i].Site, items[i].Name))
+		if items[i].ItemID > 0 {
+			set[itemIDLockKey(items[i].ItemID)] = struct{}{}
+		}
+	}
+	lockKeys := make([]string, 0, len(set))
+	for key := range set {
+		lockKeys = append(lockKeys, key)
+	}
+	sort.Strings(lockKeys)
+
+	unlock, err := lockAll(ctx, lockKeys)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	// 获取锁后检查名称唯一键：指定了item_id的行不能使用其他商品（包括同一批中前面的行）的名称
+	existing, err := database.QueryItemsByNameKeys(ctx.Request.Context(), nameKeys)
+	if err != nil {
+		return nil, apperror.Internal(i18n.QueryFailed, err)
+	}
+	owners := make(map[string]int64, len(existing))
+	for _, item := range existing {
+		owners[item.NameKey] = item.ItemID
+	}
+	rejected := make(map[int]error)
+	writes := make([]models.Item, 0, len(items))
+	for i, item := range items {
+		nameKey := nameKeys[i]
+		owner, ok := owners[nameKey]
+		if item.ItemID > 0 && ok && owner != item.ItemID {
+			rejected[i] = apperror.DuplicateName(item.Name, owner)
+			continue
+		}
+		if !ok {
+			// 同一批中新增的商品还没有item_id，用0占位，后面指定了item_id的行不能再使用这个名称
+			owners[nameKey] = item.ItemID
+		}
+		writes = append(writes, item)
+	}
+
+	if _, err := database.UpsertItems(ctx.Request.Context(), writes, audit); err != nil {
+		return nil, apperror.Internal(i18n.InsertFailed, err)
+	}
+
+	// 被更新的商品直接删除缓存，下次查询时从MySQL重新加载
+	updatedIDs := make([]int64, 0, len(writes))
+	for _, item := range writes {
+		updatedIDs = append(updatedIDs, item.ItemID)
+		caches.DeleteLocalCache(item.ItemID)
+		// 重新导入的商品不再视为已删除
+		delete(models.ItemDeleteTime, item.ItemID)
+		search.IndexItem(item)
+	}
+	if err := caches.DeleteRedisCaches(ctx.Request.Context(), updatedIDs); err != nil {
+		logger.Printf(ctx, "批量删除商品的Redis缓存失败: %s", err.Error())
+	}
+	return rejected, nil
+}
+
+// 某一行数据无法解析，记录错误后继续处理下一行
+type importLineError struct {
+	err error
+}
+
+func (e *importLineError) Error() string {
+	return e.err.Error()
+}
+
+// 创建CSV格式的逐行读取函数，第一行必须是表头，且包含name和price列
+func newCSVImportReader(body io.Reader) (func() (int, models.ImportItem, error), error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, apperror.InvalidBody(err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))] = i
+	}
+	_, hasName := columns["name"]
+	_, hasPrice := columns["price"]
+	if !hasName || !hasPrice {
+		return nil, apperror.Request(http.StatusBadRequest, i18n.InvalidBody, i18n.NewError(i18n.CSVHeaderRequired))
+	}
+	column := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	return func() (int, models.ImportItem, error) {
+		var row models.ImportItem
+		record, err := reader.Read()
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				return parseErr.StartLine, row, &importLineError{apperror.InvalidBody(err)}
+			}
+			return 0, row, err
+		}
+		line, _ := reader.FieldPos(0)
+
+		var fields []apperror.FieldError
+		if value := column(record, "item_id"); value != "" {
+			row.ItemID, err = strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				fields = append(fields, invalidTypeField("item_id", "int64"))
+			}
+		}
+		row.Name = column(record, "name")
+		row.Price, err = strconv.ParseFloat(column(record, "price"), 64)
+		if err != nil {
+			fields = append(fields, invalidTypeField("price", "float64"))
+		}
+		if len(fields) > 0 {
+			return line, row, &importLineError{apperror.InvalidFields(fields)}
+		}
+		return line, row, nil
+	}, nil
+}
+
+// 创建NDJSON格式的逐行读取函数，空行会被忽略
+func newNDJSONImportReader(body io.Reader) func() (int, models.ImportItem, error) {
+	scanner := bufio.NewScanner(body)
+	// 单行最大1MB
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	return func() (int, models.ImportItem, error) {
+		var row models.ImportItem
+		for scanner.Scan() {
+			line++
+			data := scanner.Bytes()
+			if len(strings.TrimSpace(string(data))) == 0 {
+				continue
+			}
+			if err := validation.DecodeStrict(data, &row); err != nil {
+				return line, row, &importLineError{err}
+			}
+			return line, row, nil
+		}
+		if err := scanner.Err(); err != nil {
+			return line, row, err
+		}
+		return line, row, io.EOF
+	}
+}
+
+// 字段类型错误
+func invalidTypeField(field, typeName string) apperror.FieldError {
+	return apperror.FieldError{
+		Field:  field,
+		Code:   i18n.FieldInvalidType,
+		Detail: i18n.NewError(i18n.FieldInvalidType, typeName),
+	}
+}
+
+// 根据Content-Type判断导入格式
+func formatFromContentType(contentType string) string {
+	for format, t := range formatContentTypes {
+		if contentType == t {
+			return format
+		}
+	}
+	return ""
+}
+
+// 不支持的导入导出格式
+func invalidFormatError() error {
+	return apperror.InvalidFields([]apperror.FieldError{{
+		Field:  "format",
+		Code:   i18n.FieldInvalidEnum,
+		Detail: i18n.NewError(i18n.FieldInvalidEnum, formatCSV+", "+formatNDJSON),
+	}})
+}
+
+// 导入导出时每批处理的行数
+func transferChunkSize() int {
+	if config.Configs.Transfer.ChunkSize > 0 {
+		return config.Configs.Transfer.ChunkSize
+	}
+	return 500
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"errors"
+	"miHttpServer/config"
+	"miHttpServer/database"
+	"miHttpServer/i18n"
+	"net/http"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestImportItemsBatchFailure(t *testing.T) {
+	chunkSize := config.Configs.Transfer.ChunkSize
+	config.Configs.Transfer.ChunkSize = 2
+	defer func() { config.Configs.Transfer.ChunkSize = chunkSize }()
+
+	// 第一批（第1、2行）写入成功
+	testSQL.ExpectQuery("SELECT .* FROM `item`").WillReturnRows(sqlmock.NewRows([]string{"item_id"}))
+	testSQL.ExpectBegin()
+	expectImportInsert(1)
+	expectImportInsert(2)
+	testSQL.ExpectCommit()
+	// 第二批（第3、5行）查询失败，第4行校验失败
+	testSQL.ExpectQuery("SELECT .* FROM `item`").WillReturnError(errors.New("connection reset"))
+
+	body := `{"name": "a", "price": 1}
+{"name": "b", "price": 2}
+{"name": "c", "price": 3}
+{"name": "", "price": 4}
+{"name": "e", "price": 5}
+`
+	recorder, response := serve(t, http.MethodPost, "/uk/items/import?format=ndjson", "application/x-ndjson", body)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("状态码为%d，应为200: %s", recorder.Code, recorder.Body.String())
+	}
+	data := response.Data.(map[string]interface{})
+	if data["total"] != float64(5) || data["imported"] != float64(2) || data["failed"] != float64(3) {
+		t.Errorf("total/imported/failed为%v/%v/%v，应为5/2/3", data["total"], data["imported"], data["failed"])
+	}
+
+	codes := make(map[float64]string)
+	for _, e := range data["errors"].([]interface{}) {
+		lineError := e.(map[string]interface{})
+		codes[lineError["line"].(float64)] = lineError["error_code"].(string)
+	}
+	want := map[float64]string{3: i18n.QueryFailed, 4: i18n.ValidationFailed, 5: i18n.QueryFailed}
+	for line, code := range want {
+		if codes[line] != code {
+			t.Errorf("第%v行的错误码为%q，应为%q", line, codes[line], code)
+		}
+	}
+	if err := testSQL.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+// 导入时插入一个新商品：按名称唯一键加锁查询不存在，插入商品和变更历史
+func expectImportInsert(itemID int64) {
+	testSQL.ExpectQuery("SELECT .* FROM `item` .* FOR UPDATE").WillReturnRows(sqlmock.NewRows([]string{"item_id"}))
+	testSQL.ExpectExec("INSERT INTO `item`").WillReturnResult(sqlmock.NewResult(itemID, 1))
+	testSQL.ExpectExec("INSERT INTO `item_audit`").WillReturnResult(sqlmock.NewResult(itemID, 1))
+}
+
+// item_id和名称分别属于不同的商品时，这一行返回名称冲突，不会更新任何一个商品，同一批的其他行正常写入
+func TestImportItemsNameOwnedByOtherItem(t *testing.T) {
+	testSQL.ExpectQuery("SELECT .* FROM `item` WHERE `item_id` IN").
+		WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"item_id", "site"}).AddRow(7, "uk"))
+	testSQL.ExpectQuery("SELECT .* FROM `item` WHERE `name_key` IN").
+		WillReturnRows(sqlmock.NewRows([]string{"item_id", "name", "price", "site", "name_key"}).
+			AddRow(9, "taken", 1, "uk", database.NameKey("uk", "taken")))
+	testSQL.ExpectBegin()
+	expectImportInsert(10)
+	testSQL.ExpectCommit()
+
+	body := `{"item_id": 7, "name": "taken", "price": 1}
+{"name": "fresh", "price": 2}
+`
+	recorder, response := serve(t, http.MethodPost, "/uk/items/import?format=ndjson", "application/x-ndjson", body)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("状态码为%d，应为200: %s", recorder.Code, recorder.Body.String())
+	}
+	data := response.Data.(map[string]interface{})
+	if data["imported"] != float64(1) || data["failed"] != float64(1) {
+		t.Errorf("imported/failed为%v/%v，应为1/1", data["imported"], data["failed"])
+	}
+	lineErrors := data["errors"].([]interface{})
+	if len(lineErrors) != 1 {
+		t.Fatalf("错误为%v，应只有第1行", lineErrors)
+	}
+	lineError := lineErrors[0].(map[string]interface{})
+	if lineError["line"] != float64(1) || lineError["error_code"] != i18n.ItemNameConflict {
+		t.Errorf("第%v行的错误码为%v，应为第1行%s", lineError["line"], lineError["error_code"], i18n.ItemNameConflict)
+	}
+	if err := testSQL.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+// 名称按站点唯一时，通过其他站点的URL导入已有商品，名称唯一键仍按商品保存的站点计算
+func TestImportItemsKeepsStoredSite(t *testing.T) {
+	uniqueNamePerSite := config.Configs.Item.UniqueNamePerSite
+	config.Configs.Item.UniqueNamePerSite = true
+	defer func() { config.Configs.Item.UniqueNamePerSite = uniqueNamePerSite }()
+
+	testSQL.ExpectQuery("SELECT .* FROM `item` WHERE `item_id` IN").
+		WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"item_id", "site"}).AddRow(7, "jp"))
+	testSQL.ExpectQuery("SELECT .* FROM `item` WHERE `name_key` IN").
+		WithArgs("jp/renamed").
+		WillReturnRows(sqlmock.NewRows([]string{"item_id"}))
+	testSQL.ExpectBegin()
+	testSQL.ExpectQuery("SELECT .* FROM `item` WHERE \\(item_id = \\?\\) .*FOR UPDATE").
+		WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"item_id", "name", "price", "site", "name_key"}).
+			AddRow(7, "old", 1, "jp", "jp/old"))
+	testSQL.ExpectExec("UPDATE `item` SET `name` = \\?, `price` = \\?, `name_key` = \\?").
+		WithArgs("renamed", float64(3), "jp/renamed", sqlmock.AnyArg(), int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	testSQL.ExpectExec("INSERT INTO `item_audit`").WillReturnResult(sqlmock.NewResult(1, 1))
+	testSQL.ExpectCommit()
+
+	recorder, response := serve(t, http.MethodPost, "/uk/items/import?format=ndjson", "application/x-ndjson", `{"item_id": 7, "name": "renamed", "price": 3}`)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("状态码为%d，应为200: %s", recorder.Code, recorder.Body.String())
+	}
+	if data := response.Data.(map[string]interface{}); data["imported"] != float64(1) {
+		t.Errorf("imported为%v，应为1: %v", data["imported"], data["errors"])
+	}
+	if err := testSQL.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,96 @@
+package health
+
+import (
+	"context"
+	"miHttpServer/database"
+	"sync"
+	"time"
+)
+
+// 服务的整体状态
+const (
+	StatusReady        = "ready"         // 所有依赖都可用
+	StatusDegraded     = "degraded"      // Redis不可用但MySQL可用，缓存和分布式锁失效，查询仍可从MySQL读取
+	StatusNotReady     = "not_ready"     // MySQL不可用
+	StatusStarting     = "starting"      // 服务正在启动，尚未完成初始化
+	StatusShuttingDown = "shutting_down" // 服务正在关闭
+)
+
+// 单个依赖的状态
+const (
+	DependencyUp   = "up"
+	DependencyDown = "down"
+)
+
+// 单个依赖的检查结果
+type Dependency struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// 就绪检查的结果
+type Report struct {
+	Status       string                `json:"status"`
+	Dependencies map[string]Dependency `json:"dependencies,omitempty"`
+}
+
+// 并发检查MySQL和Redis，每个依赖最多等待timeout
+// 服务启动完成前和正在关闭时不检查依赖，直接返回启动中或关闭中
+func Check(timeout time.Duration) Report {
+	if ShuttingDown() {
+		return Report{Status: StatusShuttingDown}
+	}
+	if !Ready() {
+		return Report{Status: StatusStarting}
+	}
+
+	checks := map[string]func(context.Context) error{
+		"mysql": database.PingMySQL,
+		"redis": database.PingRedis,
+	}
+	dependencies := make(map[string]Dependency, len(checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, ping := range checks {
+		wg.Add(1)
+		go func(name string, ping func(context.Context) error) {
+			defer wg.Done()
+			dependency := checkDependency(ping, timeout)
+			mu.Lock()
+			dependencies[name] = dependency
+			mu.Unlock()
+		}(name, ping)
+	}
+	wg.Wait()
+
+	status := StatusReady
+	if dependencies["mysql"].Status == DependencyDown {
+		status = StatusNotReady
+	} else if dependencies["redis"].Status == DependencyDown {
+		status = StatusDegraded
+	}
+	return Report{Status: status, Dependencies: dependencies}
+}
+
+// 检查一个依赖并记录耗时
+func checkDependency(ping func(context.Context) error, timeout time.Duration) Dependency {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := ping(ctx)
+	// ping没有按时返回时也视为不可用
+	if err == nil {
+		err = ctx.Err()
+	}
+	dependency := Dependency{
+		Status:    DependencyUp,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		dependency.Status = DependencyDown
+		dependency.Error = err.Error()
+	}
+	return dependency
+}
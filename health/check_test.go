@@ -0,0 +1,26 @@
+package health
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckLifecycle(t *testing.T) {
+	defer state.Store(stateStarting)
+
+	// 启动完成前和开始关闭后不检查依赖
+	if report := Check(time.Second); report.Status != StatusStarting || report.Dependencies != nil {
+		t.Errorf("启动中的状态为%+v，应为starting", report)
+	}
+	SetReady(false)
+	if report := Check(time.Second); report.Status != StatusShuttingDown || report.Dependencies != nil {
+		t.Errorf("关闭中的状态为%+v，应为shutting_down", report)
+	}
+	if Ready() {
+		t.Error("关闭中不应就绪")
+	}
+	SetReady(true)
+	if !Ready() || ShuttingDown() {
+		t.Error("SetReady(true)后应就绪")
+	}
+}
@@ -0,0 +1,32 @@
+package health
+
+import "sync/atomic"
+
+// 服务的生命周期：启动中 -> 就绪 -> 关闭中
+const (
+	stateStarting int32 = iota
+	stateReady
+	stateShuttingDown
+)
+
+// 服务当前的生命周期状态，进程启动时为启动中
+var state atomic.Int32
+
+// 设置就绪状态：启动完成后设为true，开始关闭时设为false（之后不再恢复为启动中）
+func SetReady(value bool) {
+	if value {
+		state.Store(stateReady)
+	} else {
+		state.Store(stateShuttingDown)
+	}
+}
+
+// 服务是否就绪（可以接收新请求）
+func Ready() bool {
+	return state.Load() == stateReady
+}
+
+// 服务是否正在关闭
+func ShuttingDown() bool {
+	return state.Load() == stateShuttingDown
+}
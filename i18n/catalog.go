@@ -0,0 +1,655 @@
+package i18n
+
+import "fmt"
+
+// 消息编号，作为稳定的错误码返回给客户端，不随翻译变化
+const (
+	Success                       = "SUCCESS"
+	InvalidJSON                   = "INVALID_JSON"
+	InvalidItemID                 = "INVALID_ITEM_ID"
+	ItemNotFound                  = "ITEM_NOT_FOUND"
+	ItemNotExist                  = "ITEM_NOT_EXIST"
+	LockError                     = "LOCK_ERROR"
+	LockTimeout                   = "LOCK_TIMEOUT"
+	RetryLater                    = "RETRY_LATER"
+	InsertFailed                  = "INSERT_FAILED"
+	UpdateFailed                  = "UPDATE_FAILED"
+	QueryFailed                   = "QUERY_FAILED"
+	DeleteFailed                  = "DELETE_FAILED"
+	RouteNotFound                 = "ROUTE_NOT_FOUND"
+	CheckURL                      = "CHECK_URL"
+	AppLocalEmpty                 = "APP_LOCAL_EMPTY"
+	AppLocalEmptyDetail           = "APP_LOCAL_EMPTY_DETAIL"
+	AppLocalInvalid               = "APP_LOCAL_INVALID"
+	AppLocalInvalidDetail         = "APP_LOCAL_INVALID_DETAIL"
+	InternalError                 = "INTERNAL_ERROR"
+	InternalErrorDetail           = "INTERNAL_ERROR_DETAIL"
+	ValidationFailed              = "VALIDATION_FAILED"
+	ValidationFailedDetail        = "VALIDATION_FAILED_DETAIL"
+	FieldRequired                 = "FIELD_REQUIRED"
+	FieldTooShort                 = "FIELD_TOO_SHORT"
+	FieldTooLong                  = "FIELD_TOO_LONG"
+	FieldNotFinite                = "FIELD_NOT_FINITE"
+	FieldOutOfRange               = "FIELD_OUT_OF_RANGE"
+	FieldTooManyDecimals          = "FIELD_TOO_MANY_DECIMALS"
+	FieldInvalidType              = "FIELD_INVALID_TYPE"
+	FieldUnknown                  = "FIELD_UNKNOWN"
+	SingleJSONValue               = "SINGLE_JSON_VALUE"
+	MergePatchObject              = "MERGE_PATCH_OBJECT"
+	UnsupportedMediaType          = "UNSUPPORTED_MEDIA_TYPE"
+	UnsupportedMediaTypeDetail    = "UNSUPPORTED_MEDIA_TYPE_DETAIL"
+	FieldInvalidEnum              = "FIELD_INVALID_ENUM"
+	FieldTooManyItems             = "FIELD_TOO_MANY_ITEMS"
+	CSVHeaderRequired             = "CSV_HEADER_REQUIRED"
+	InvalidBody                   = "INVALID_BODY"
+	ItemNameConflict              = "ITEM_NAME_CONFLICT"
+	ItemNameExist                 = "ITEM_NAME_EXIST"
+	InvalidIdempotencyKey         = "INVALID_IDEMPOTENCY_KEY"
+	InvalidIdempotencyKeyDetail   = "INVALID_IDEMPOTENCY_KEY_DETAIL"
+	IdempotencyKeyReused          = "IDEMPOTENCY_KEY_REUSED"
+	IdempotencyKeyReusedDetail    = "IDEMPOTENCY_KEY_REUSED_DETAIL"
+	IdempotencyInProgress         = "IDEMPOTENCY_IN_PROGRESS"
+	IdempotencyInProgressDetail   = "IDEMPOTENCY_IN_PROGRESS_DETAIL"
+	VersionNotFound               = "VERSION_NOT_FOUND"
+	VersionNotExist               = "VERSION_NOT_EXIST"
+	InsufficientStock             = "INSUFFICIENT_STOCK"
+	InsufficientStockDetail       = "INSUFFICIENT_STOCK_DETAIL"
+	ReservationNotFound           = "RESERVATION_NOT_FOUND"
+	ReservationNotExist           = "RESERVATION_NOT_EXIST"
+	ReservationConflict           = "RESERVATION_CONFLICT"
+	ReservationConflictDetail     = "RESERVATION_CONFLICT_DETAIL"
+	ReservationExpired            = "RESERVATION_EXPIRED"
+	ReservationExpiredDetail      = "RESERVATION_EXPIRED_DETAIL"
+	CategoryNotFound              = "CATEGORY_NOT_FOUND"
+	CategoryNotExist              = "CATEGORY_NOT_EXIST"
+	CategoryCycle                 = "CATEGORY_CYCLE"
+	CategoryTooDeep               = "CATEGORY_TOO_DEEP"
+	CategoryNotEmpty              = "CATEGORY_NOT_EMPTY"
+	CategoryNotEmptyDetail        = "CATEGORY_NOT_EMPTY_DETAIL"
+	FieldInvalidFormat            = "FIELD_INVALID_FORMAT"
+	MediaNotFound                 = "MEDIA_NOT_FOUND"
+	MediaNotExist                 = "MEDIA_NOT_EXIST"
+	MediaTooLarge                 = "MEDIA_TOO_LARGE"
+	MediaTooLargeDetail           = "MEDIA_TOO_LARGE_DETAIL"
+	InvalidMedia                  = "INVALID_MEDIA"
+	InvalidMediaDetail            = "INVALID_MEDIA_DETAIL"
+	MediaLimitExceeded            = "MEDIA_LIMIT_EXCEEDED"
+	MediaLimitExceededDetail      = "MEDIA_LIMIT_EXCEEDED_DETAIL"
+	PriceScheduleNotFound         = "PRICE_SCHEDULE_NOT_FOUND"
+	PriceScheduleNotExist         = "PRICE_SCHEDULE_NOT_EXIST"
+	PriceScheduleOverlap          = "PRICE_SCHEDULE_OVERLAP"
+	PriceScheduleOverlapDetail    = "PRICE_SCHEDULE_OVERLAP_DETAIL"
+	PriceScheduleFinished         = "PRICE_SCHEDULE_FINISHED"
+	PriceScheduleFinishedDetail   = "PRICE_SCHEDULE_FINISHED_DETAIL"
+	FieldNotAfter                 = "FIELD_NOT_AFTER"
+	Forbidden                     = "FORBIDDEN"
+	AdminRequired                 = "ADMIN_REQUIRED"
+	InvalidStatusTransition       = "INVALID_STATUS_TRANSITION"
+	InvalidStatusTransitionDetail = "INVALID_STATUS_TRANSITION_DETAIL"
+	SKUNotFound                   = "SKU_NOT_FOUND"
+	SKUNotExist                   = "SKU_NOT_EXIST"
+	SKUCodeConflict               = "SKU_CODE_CONFLICT"
+	SKUCodeExist                  = "SKU_CODE_EXIST"
+	SKULimitExceeded              = "SKU_LIMIT_EXCEEDED"
+	SKULimitExceededDetail        = "SKU_LIMIT_EXCEEDED_DETAIL"
+	// 原样输出参数，不做翻译（例如json解析错误）
+	Raw = "RAW"
+)
+
+// 消息目录：消息编号 -> 语言 -> 消息模板（fmt格式）
+var catalog = map[string]map[string]string{
+	Success: {
+		Chinese:  "成功",
+		English:  "success",
+		Japanese: "成功",
+		Russian:  "успешно",
+	},
+	InvalidJSON: {
+		Chinese:  "客户端传递的json非法",
+		English:  "invalid JSON in request body",
+		Japanese: "リクエストのJSONが不正です",
+		Russian:  "некорректный JSON в теле запроса",
+	},
+	InvalidItemID: {
+		Chinese:  "item_id非法",
+		English:  "invalid item_id",
+		Japanese: "item_idが不正です",
+		Russian:  "некорректный item_id",
+	},
+	ItemNotFound: {
+		Chinese:  "未找到相关记录",
+		English:  "record not found",
+		Japanese: "該当するレコードが見つかりません",
+		Russian:  "запись не найдена",
+	},
+	ItemNotExist: {
+		Chinese:  "item_id为%v的商品不存在",
+		English:  "item with item_id %v does not exist",
+		Japanese: "item_idが%vの商品は存在しません",
+		Russian:  "товар с item_id %v не существует",
+	},
+	LockError: {
+		Chinese:  "获取分布式锁错误",
+		English:  "failed to acquire distributed lock",
+		Japanese: "分散ロックの取得に失敗しました",
+		Russian:  "ошибка получения распределённой блокировки",
+	},
+	LockTimeout: {
+		Chinese:  "获取分布式锁超时",
+		English:  "timed out acquiring distributed lock",
+		Japanese: "分散ロックの取得がタイムアウトしました",
+		Russian:  "истекло время ожидания распределённой блокировки",
+	},
+	RetryLater: {
+		Chinese:  "请稍后再试",
+		English:  "please try again later",
+		Japanese: "しばらくしてから再度お試しください",
+		Russian:  "повторите попытку позже",
+	},
+	InsertFailed: {
+		Chinese:  "插入数据失败",
+		English:  "failed to insert data",
+		Japanese: "データの追加に失敗しました",
+		Russian:  "не удалось добавить данные",
+	},
+	UpdateFailed: {
+		Chinese:  "更新数据失败",
+		English:  "failed to update data",
+		Japanese: "データの更新に失敗しました",
+		Russian:  "не удалось обновить данные",
+	},
+	QueryFailed: {
+		Chinese:  "查询数据失败",
+		English:  "failed to query data",
+		Japanese: "データの取得に失敗しました",
+		Russian:  "не удалось получить данные",
+	},
+	DeleteFailed: {
+		Chinese:  "删除数据失败",
+		English:  "failed to delete data",
+		Japanese: "データの削除に失敗しました",
+		Russian:  "не удалось удалить данные",
+	},
+	RouteNotFound: {
+		Chinese:  "未找到相关路由",
+		English:  "route not found",
+		Japanese: "該当するルートが見つかりません",
+		Russian:  "маршрут не найден",
+	},
+	CheckURL: {
+		Chinese:  "请检查请求的URL是否正确",
+		English:  "please check that the request URL is correct",
+		Japanese: "リクエストURLが正しいか確認してください",
+		Russian:  "проверьте правильность URL запроса",
+	},
+	AppLocalEmpty: {
+		Chinese:  "请求参数app_local为空",
+		English:  "request parameter app_local is empty",
+		Japanese: "リクエストパラメータapp_localが空です",
+		Russian:  "параметр запроса app_local пуст",
+	},
+	AppLocalEmptyDetail: {
+		Chinese:  "缺少app_local参数，应为uk、jp和ru中的一个，例如http://localhost:8080/uk/item/",
+		English:  "missing app_local parameter, it should be one of uk, jp and ru, e.g. http://localhost:8080/uk/item/",
+		Japanese: "app_localパラメータがありません。uk、jp、ruのいずれかを指定してください（例：http://localhost:8080/uk/item/）",
+		Russian:  "отсутствует параметр app_local, допустимые значения: uk, jp, ru, например http://localhost:8080/uk/item/",
+	},
+	AppLocalInvalid: {
+		Chinese:  "请求参数app_local非法",
+		English:  "invalid request parameter app_local",
+		Japanese: "リクエストパラメータapp_localが不正です",
+		Russian:  "некорректный параметр запроса app_local",
+	},
+	AppLocalInvalidDetail: {
+		Chinese:  "app_local参数应为uk、jp和ru中的一个，例如http://localhost:8080/uk/item/",
+		English:  "app_local should be one of uk, jp and ru, e.g. http://localhost:8080/uk/item/",
+		Japanese: "app_localはuk、jp、ruのいずれかを指定してください（例：http://localhost:8080/uk/item/）",
+		Russian:  "app_local должен быть одним из uk, jp, ru, например http://localhost:8080/uk/item/",
+	},
+	InternalError: {
+		Chinese:  "服务器内部错误",
+		English:  "internal server error",
+		Japanese: "サーバー内部エラー",
+		Russian:  "внутренняя ошибка сервера",
+	},
+	InternalErrorDetail: {
+		Chinese:  "服务器处理请求时发生错误，请稍后再试",
+		English:  "an error occurred while processing the request, please try again later",
+		Japanese: "リクエストの処理中にエラーが発生しました。しばらくしてから再度お試しください",
+		Russian:  "при обработке запроса произошла ошибка, повторите попытку позже",
+	},
+	ValidationFailed: {
+		Chinese:  "请求参数校验失败",
+		English:  "request validation failed",
+		Japanese: "リクエストの検証に失敗しました",
+		Russian:  "ошибка проверки запроса",
+	},
+	ValidationFailedDetail: {
+		Chinese:  "共有%d处字段校验失败",
+		English:  "%d field error(s) found",
+		Japanese: "%d件のフィールドエラーがあります",
+		Russian:  "найдено ошибок в полях: %d",
+	},
+	FieldRequired: {
+		Chinese:  "不能为空",
+		English:  "must not be empty",
+		Japanese: "必須項目です",
+		Russian:  "не может быть пустым",
+	},
+	FieldTooShort: {
+		Chinese:  "长度不能少于%d个字符",
+		English:  "must be at least %d characters long",
+		Japanese: "%d文字以上で入力してください",
+		Russian:  "должно содержать не менее %d символов",
+	},
+	FieldTooLong: {
+		Chinese:  "长度不能超过%d个字符",
+		English:  "must be at most %d characters long",
+		Japanese: "%d文字以内で入力してください",
+		Russian:  "должно содержать не более %d символов",
+	},
+	FieldNotFinite: {
+		Chinese:  "必须是有效的数字",
+		English:  "must be a finite number",
+		Japanese: "有効な数値を入力してください",
+		Russian:  "должно быть конечным числом",
+	},
+	FieldOutOfRange: {
+		Chinese:  "取值必须在%v到%v之间",
+		English:  "must be between %v and %v",
+		Japanese: "%vから%vの範囲で入力してください",
+		Russian:  "должно быть в диапазоне от %v до %v",
+	},
+	FieldTooManyDecimals: {
+		Chinese:  "小数位数不能超过%d位",
+		English:  "must have at most %d decimal places",
+		Japanese: "小数点以下は%d桁以内で入力してください",
+		Russian:  "допускается не более %d знаков после запятой",
+	},
+	FieldInvalidType: {
+		Chinese:  "类型错误，应为%s",
+		English:  "invalid type, expected %s",
+		Japanese: "型が不正です（%sである必要があります）",
+		Russian:  "неверный тип, ожидается %s",
+	},
+	FieldUnknown: {
+		Chinese:  "不支持的字段",
+		English:  "unknown field",
+		Japanese: "サポートされていないフィールドです",
+		Russian:  "неизвестное поле",
+	},
+	SingleJSONValue: {
+		Chinese:  "请求体只能包含一个json对象",
+		English:  "request body must contain a single JSON object",
+		Japanese: "リクエスト本文にはJSONオブジェクトを1つだけ含めてください",
+		Russian:  "тело запроса должно содержать один JSON-объект",
+	},
+	MergePatchObject: {
+		Chinese:  "请求体必须是json对象（JSON Merge Patch）",
+		English:  "request body must be a JSON object (JSON Merge Patch)",
+		Japanese: "リクエスト本文はJSONオブジェクト（JSON Merge Patch）である必要があります",
+		Russian:  "тело запроса должно быть JSON-объектом (JSON Merge Patch)",
+	},
+	UnsupportedMediaType: {
+		Chinese:  "不支持的Content-Type",
+		English:  "unsupported Content-Type",
+		Japanese: "サポートされていないContent-Typeです",
+		Russian:  "неподдерживаемый Content-Type",
+	},
+	UnsupportedMediaTypeDetail: {
+		Chinese:  "Content-Type应为%s",
+		English:  "Content-Type must be %s",
+		Japanese: "Content-Typeは%sである必要があります",
+		Russian:  "Content-Type должен быть %s",
+	},
+	FieldInvalidEnum: {
+		Chinese:  "取值必须是%s之一",
+		English:  "must be one of %s",
+		Japanese: "%sのいずれかを指定してください",
+		Russian:  "должно быть одним из: %s",
+	},
+	FieldTooManyItems: {
+		Chinese:  "最多只能包含%d个元素",
+		English:  "must contain at most %d elements",
+		Japanese: "要素数は%d個以内にしてください",
+		Russian:  "должно содержать не более %d элементов",
+	},
+	InvalidBody: {
+		Chinese:  "请求体格式非法",
+		English:  "malformed request body",
+		Japanese: "リクエスト本文の形式が不正です",
+		Russian:  "некорректный формат тела запроса",
+	},
+	CSVHeaderRequired: {
+		Chinese:  "CSV第一行必须是表头，且包含name和price列",
+		English:  "the first CSV line must be a header containing the name and price columns",
+		Japanese: "CSVの1行目はnameとprice列を含むヘッダーである必要があります",
+		Russian:  "первая строка CSV должна быть заголовком со столбцами name и price",
+	},
+	ItemNameConflict: {
+		Chinese:  "商品名称已存在",
+		English:  "item name already exists",
+		Japanese: "商品名はすでに存在します",
+		Russian:  "товар с таким названием уже существует",
+	},
+	ItemNameExist: {
+		Chinese:  "名称为%s的商品已存在，item_id为%d",
+		English:  "an item named %s already exists with item_id %d",
+		Japanese: "%sという名前の商品はすでに存在します（item_id：%d）",
+		Russian:  "товар с названием %s уже существует, item_id %d",
+	},
+	InvalidIdempotencyKey: {
+		Chinese:  "Idempotency-Key非法",
+		English:  "invalid Idempotency-Key",
+		Japanese: "Idempotency-Keyが不正です",
+		Russian:  "некорректный Idempotency-Key",
+	},
+	InvalidIdempotencyKeyDetail: {
+		Chinese:  "Idempotency-Key的长度不能超过%d个字符",
+		English:  "Idempotency-Key must be at most %d characters long",
+		Japanese: "Idempotency-Keyは%d文字以内で指定してください",
+		Russian:  "Idempotency-Key должен содержать не более %d символов",
+	},
+	IdempotencyKeyReused: {
+		Chinese:  "Idempotency-Key已被其他请求使用",
+		English:  "Idempotency-Key has been used with a different request",
+		Japanese: "Idempotency-Keyは別のリクエストで使用されています",
+		Russian:  "Idempotency-Key уже использован с другим запросом",
+	},
+	IdempotencyKeyReusedDetail: {
+		Chinese:  "相同Idempotency-Key的请求内容必须完全相同，请使用新的Idempotency-Key",
+		English:  "requests with the same Idempotency-Key must be identical, please use a new Idempotency-Key",
+		Japanese: "同じIdempotency-Keyのリクエスト内容は同一である必要があります。新しいIdempotency-Keyを使用してください",
+		Russian:  "запросы с одинаковым Idempotency-Key должны совпадать, используйте новый Idempotency-Key",
+	},
+	IdempotencyInProgress: {
+		Chinese:  "相同Idempotency-Key的请求正在处理中",
+		English:  "a request with the same Idempotency-Key is being processed",
+		Japanese: "同じIdempotency-Keyのリクエストを処理中です",
+		Russian:  "запрос с таким же Idempotency-Key уже обрабатывается",
+	},
+	IdempotencyInProgressDetail: {
+		Chinese:  "请稍后使用相同的Idempotency-Key重试",
+		English:  "please retry later with the same Idempotency-Key",
+		Japanese: "しばらくしてから同じIdempotency-Keyで再試行してください",
+		Russian:  "повторите попытку позже с тем же Idempotency-Key",
+	},
+	VersionNotFound: {
+		Chinese:  "版本不存在",
+		English:  "version not found",
+		Japanese: "バージョンが見つかりません",
+		Russian:  "версия не найдена",
+	},
+	VersionNotExist: {
+		Chinese:  "商品%v不存在audit_id为%v的版本",
+		English:  "item %v has no version with audit_id %v",
+		Japanese: "商品%vにaudit_idが%vのバージョンは存在しません",
+		Russian:  "у товара %v нет версии с audit_id %v",
+	},
+	InsufficientStock: {
+		Chinese:  "库存不足",
+		English:  "insufficient stock",
+		Japanese: "在庫が不足しています",
+		Russian:  "недостаточно товара на складе",
+	},
+	InsufficientStockDetail: {
+		Chinese:  "商品%v当前可用库存为%v，无法预留%v件",
+		English:  "item %v has %v available, cannot reserve %v",
+		Japanese: "商品%vの利用可能な在庫は%vのため、%v個を確保できません",
+		Russian:  "у товара %v доступно %v, невозможно зарезервировать %v",
+	},
+	ReservationNotFound: {
+		Chinese:  "预留不存在",
+		English:  "reservation not found",
+		Japanese: "在庫確保が見つかりません",
+		Russian:  "резерв не найден",
+	},
+	ReservationNotExist: {
+		Chinese:  "reservation_id为%v的预留不存在",
+		English:  "reservation with reservation_id %v does not exist",
+		Japanese: "reservation_idが%vの在庫確保は存在しません",
+		Russian:  "резерв с reservation_id %v не существует",
+	},
+	ReservationConflict: {
+		Chinese:  "预留状态冲突",
+		English:  "reservation state conflict",
+		Japanese: "在庫確保の状態が競合しています",
+		Russian:  "конфликт состояния резерва",
+	},
+	ReservationConflictDetail: {
+		Chinese:  "预留%v当前状态为%v，不能再修改",
+		English:  "reservation %v is already %v and can no longer be changed",
+		Japanese: "在庫確保%vはすでに%vのため、変更できません",
+		Russian:  "резерв %v уже в состоянии %v и не может быть изменён",
+	},
+	ReservationExpired: {
+		Chinese:  "预留已过期",
+		English:  "reservation expired",
+		Japanese: "在庫確保の期限が切れています",
+		Russian:  "срок резерва истёк",
+	},
+	ReservationExpiredDetail: {
+		Chinese:  "预留%v已过期，库存已释放",
+		English:  "reservation %v has expired and its stock was released",
+		Japanese: "在庫確保%vは期限切れのため、在庫は解放されました",
+		Russian:  "срок резерва %v истёк, товар возвращён на склад",
+	},
+	CategoryNotFound: {
+		Chinese:  "分类不存在",
+		English:  "category not found",
+		Japanese: "カテゴリが見つかりません",
+		Russian:  "категория не найдена",
+	},
+	CategoryNotExist: {
+		Chinese:  "category_id为%v的分类不存在",
+		English:  "category with category_id %v does not exist",
+		Japanese: "category_idが%vのカテゴリは存在しません",
+		Russian:  "категория с category_id %v не существует",
+	},
+	CategoryCycle: {
+		Chinese:  "不能将分类移动到自身或其子分类下",
+		English:  "a category cannot be moved under itself or its descendants",
+		Japanese: "カテゴリを自身またはその子孫の下に移動することはできません",
+		Russian:  "категорию нельзя переместить в саму себя или в её потомков",
+	},
+	CategoryTooDeep: {
+		Chinese:  "分类层级过深，路径不能超过%v个字符",
+		English:  "category hierarchy is too deep, the path cannot exceed %v characters",
+		Japanese: "カテゴリの階層が深すぎます。パスは%v文字以内にしてください",
+		Russian:  "слишком глубокая иерархия категорий, путь не может превышать %v символов",
+	},
+	CategoryNotEmpty: {
+		Chinese:  "分类不为空",
+		English:  "category not empty",
+		Japanese: "カテゴリが空ではありません",
+		Russian:  "категория не пуста",
+	},
+	CategoryNotEmptyDetail: {
+		Chinese:  "分类%v下还有子分类，不能删除",
+		English:  "category %v still has child categories and cannot be deleted",
+		Japanese: "カテゴリ%vには子カテゴリがあるため削除できません",
+		Russian:  "категория %v содержит дочерние категории и не может быть удалена",
+	},
+	FieldInvalidFormat: {
+		Chinese:  "格式错误，应匹配%s",
+		English:  "invalid format, must match %s",
+		Japanese: "形式が正しくありません。%sに一致する必要があります",
+		Russian:  "неверный формат, должен соответствовать %s",
+	},
+	MediaNotFound: {
+		Chinese:  "媒体文件不存在",
+		English:  "media not found",
+		Japanese: "メディアファイルが見つかりません",
+		Russian:  "медиафайл не найден",
+	},
+	MediaNotExist: {
+		Chinese:  "商品%d不存在媒体文件%s",
+		English:  "item %d has no media %s",
+		Japanese: "商品%dにメディアファイル%sは存在しません",
+		Russian:  "у товара %d нет медиафайла %s",
+	},
+	MediaTooLarge: {
+		Chinese:  "文件太大",
+		English:  "file too large",
+		Japanese: "ファイルが大きすぎます",
+		Russian:  "файл слишком большой",
+	},
+	MediaTooLargeDetail: {
+		Chinese:  "文件大小不能超过%d字节",
+		English:  "file size must not exceed %d bytes",
+		Japanese: "ファイルサイズは%dバイト以下にしてください",
+		Russian:  "размер файла не должен превышать %d байт",
+	},
+	InvalidMedia: {
+		Chinese:  "文件内容非法",
+		English:  "invalid file content",
+		Japanese: "ファイルの内容が不正です",
+		Russian:  "некорректное содержимое файла",
+	},
+	InvalidMediaDetail: {
+		Chinese:  "无法解析图片：%s",
+		English:  "cannot decode image: %s",
+		Japanese: "画像を解析できません：%s",
+		Russian:  "не удалось декодировать изображение: %s",
+	},
+	MediaLimitExceeded: {
+		Chinese:  "媒体文件数量超过限制",
+		English:  "too many media files",
+		Japanese: "メディアファイルの数が上限を超えています",
+		Russian:  "превышено количество медиафайлов",
+	},
+	MediaLimitExceededDetail: {
+		Chinese:  "每个商品最多只能有%d个媒体文件",
+		English:  "an item can have at most %d media files",
+		Japanese: "1つの商品のメディアファイルは%d個までです",
+		Russian:  "у товара может быть не более %d медиафайлов",
+	},
+	PriceScheduleNotFound: {
+		Chinese:  "定时价格不存在",
+		English:  "price schedule not found",
+		Japanese: "価格スケジュールが見つかりません",
+		Russian:  "расписание цены не найдено",
+	},
+	PriceScheduleNotExist: {
+		Chinese:  "商品%d在站点%s不存在定时价格%d",
+		English:  "item %d has no price schedule %[3]d on site %[2]s",
+		Japanese: "商品%dにはサイト%sの価格スケジュール%dは存在しません",
+		Russian:  "у товара %d на сайте %s нет расписания цены %d",
+	},
+	PriceScheduleOverlap: {
+		Chinese:  "定时价格时间段重叠",
+		English:  "price schedule overlaps",
+		Japanese: "価格スケジュールの期間が重複しています",
+		Russian:  "периоды расписания цены пересекаются",
+	},
+	PriceScheduleOverlapDetail: {
+		Chinese:  "商品%d在站点%s已有时间段重叠的定时价格",
+		English:  "item %d already has an overlapping price schedule on site %s",
+		Japanese: "商品%dにはサイト%sで期間が重複する価格スケジュールが既にあります",
+		Russian:  "у товара %d на сайте %s уже есть пересекающееся расписание цены",
+	},
+	PriceScheduleFinished: {
+		Chinese:  "定时价格已结束",
+		English:  "price schedule finished",
+		Japanese: "価格スケジュールは終了しています",
+		Russian:  "расписание цены завершено",
+	},
+	PriceScheduleFinishedDetail: {
+		Chinese:  "定时价格%d的状态为%s，不能取消",
+		English:  "price schedule %d is %s and cannot be cancelled",
+		Japanese: "価格スケジュール%dのステータスは%sのため、キャンセルできません",
+		Russian:  "расписание цены %d в статусе %s и не может быть отменено",
+	},
+	FieldNotAfter: {
+		Chinese:  "必须晚于%s",
+		English:  "must be later than %s",
+		Japanese: "%sより後である必要があります",
+		Russian:  "должно быть позже, чем %s",
+	},
+	Forbidden: {
+		Chinese:  "没有权限",
+		English:  "forbidden",
+		Japanese: "権限がありません",
+		Russian:  "доступ запрещён",
+	},
+	AdminRequired: {
+		Chinese:  "只有管理员可以执行此操作，请在请求头X-Admin-Token中传递管理员令牌",
+		English:  "only administrators can perform this operation; pass an admin token in the X-Admin-Token header",
+		Japanese: "この操作は管理者のみ実行できます。X-Admin-Tokenヘッダーに管理者トークンを指定してください",
+		Russian:  "операция доступна только администраторам; передайте токен администратора в заголовке X-Admin-Token",
+	},
+	InvalidStatusTransition: {
+		Chinese:  "不允许的状态转换",
+		English:  "invalid status transition",
+		Japanese: "許可されていないステータス遷移です",
+		Russian:  "недопустимый переход статуса",
+	},
+	InvalidStatusTransitionDetail: {
+		Chinese:  "商品%d在站点%s的状态不能从%s转换为%s",
+		English:  "item %d on site %s cannot transition from %s to %s",
+		Japanese: "サイト%[2]sの商品%[1]dのステータスは%[3]sから%[4]sに変更できません",
+		Russian:  "статус товара %d на сайте %s нельзя изменить с %s на %s",
+	},
+	SKUNotFound: {
+		Chinese:  "SKU不存在",
+		English:  "SKU not found",
+		Japanese: "SKUが見つかりません",
+		Russian:  "SKU не найден",
+	},
+	SKUNotExist: {
+		Chinese:  "商品%d不存在SKU %d",
+		English:  "item %d has no SKU %d",
+		Japanese: "商品%dにSKU %dは存在しません",
+		Russian:  "у товара %d нет SKU %d",
+	},
+	SKUCodeConflict: {
+		Chinese:  "SKU编码已存在",
+		English:  "SKU code already exists",
+		Japanese: "SKUコードは既に存在します",
+		Russian:  "код SKU уже существует",
+	},
+	SKUCodeExist: {
+		Chinese:  "SKU编码%s已被使用",
+		English:  "SKU code %s is already in use",
+		Japanese: "SKUコード%sは既に使用されています",
+		Russian:  "код SKU %s уже используется",
+	},
+	SKULimitExceeded: {
+		Chinese:  "SKU数量超过限制",
+		English:  "too many SKUs",
+		Japanese: "SKUの数が上限を超えています",
+		Russian:  "превышено количество SKU",
+	},
+	SKULimitExceededDetail: {
+		Chinese:  "每个商品最多只能有%d个SKU",
+		English:  "an item can have at most %d SKUs",
+		Japanese: "1つの商品のSKUは%d個までです",
+		Russian:  "у товара может быть не более %d SKU",
+	},
+	Raw: {
+		Chinese:  "%v",
+		English:  "%v",
+		Japanese: "%v",
+		Russian:  "%v",
+	},
+}
+
+// 将消息编号翻译为指定语言
+// 语言不存在时回退到默认语言，消息编号不存在时直接返回编号
+func Translate(lang, id string, args ...interface{}) string {
+	messages, ok := catalog[id]
+	if !ok {
+		return id
+	}
+	format, ok := messages[lang]
+	if !ok {
+		format, ok = messages[DefaultLanguage()]
+		if !ok {
+			format = messages[Chinese]
+		}
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
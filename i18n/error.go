@@ -0,0 +1,22 @@
+package i18n
+
+// 可翻译的错误，响应时根据请求语言翻译错误详情
+type Error struct {
+	ID   string
+	Args []interface{}
+}
+
+// 创建可翻译的错误
+func NewError(id string, args ...interface{}) *Error {
+	return &Error{ID: id, Args: args}
+}
+
+// 实现error接口，默认返回默认语言的错误信息（用于日志）
+func (e *Error) Error() string {
+	return Translate(DefaultLanguage(), e.ID, e.Args...)
+}
+
+// 翻译为指定语言
+func (e *Error) Localize(lang string) string {
+	return Translate(lang, e.ID, e.Args...)
+}
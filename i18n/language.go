@@ -0,0 +1,110 @@
+package i18n
+
+import (
+	"miHttpServer/config"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 支持的语言
+const (
+	Chinese  = "zh"
+	English  = "en"
+	Japanese = "ja"
+	Russian  = "ru"
+)
+
+// 在gin.Context中保存语言的键
+const languageKey = "language"
+
+// 判断是否为支持的语言
+func IsSupported(lang string) bool {
+	_, ok := catalog[Success][lang]
+	return ok
+}
+
+// 获取默认语言，配置文件未设置或设置错误时使用中文
+func DefaultLanguage() string {
+	lang := config.Configs.I18n.DefaultLanguage
+	if IsSupported(lang) {
+		return lang
+	}
+	return Chinese
+}
+
+// 获取站点的默认语言
+func SiteLanguage(appLocal string) string {
+	if lang, ok := config.Configs.I18n.SiteLanguages[appLocal]; ok && IsSupported(lang) {
+		return lang
+	}
+	return DefaultLanguage()
+}
+
+// 根据Accept-Language请求头和站点选择响应语言
+// 请求头中有支持的语言时优先使用，否则使用站点的默认语言
+func ResolveLanguage(acceptLanguage, appLocal string) string {
+	if lang := parseAcceptLanguage(acceptLanguage); lang != "" {
+		return lang
+	}
+	return SiteLanguage(appLocal)
+}
+
+// 获取本次请求的响应语言，结果会保存到ctx中
+func FromContext(ctx *gin.Context) string {
+	if ctx == nil {
+		return DefaultLanguage()
+	}
+	if lang := ctx.GetString(languageKey); lang != "" {
+		return lang
+	}
+	lang := ResolveLanguage(ctx.GetHeader("Accept-Language"), ctx.Param("app_local"))
+	ctx.Set(languageKey, lang)
+	return lang
+}
+
+// 解析Accept-Language请求头，返回权重最高的支持的语言，没有则返回空字符串
+// 例如 "en-GB,en;q=0.9,ja;q=0.8"
+func parseAcceptLanguage(header string) string {
+	type weighted struct {
+		lang string
+		q    float64
+	}
+	var candidates []weighted
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		tag, q := part, 1.0
+		if i := strings.Index(part, ";"); i >= 0 {
+			tag = strings.TrimSpace(part[:i])
+			param := strings.TrimSpace(part[i+1:])
+			if strings.HasPrefix(param, "q=") {
+				v, err := strconv.ParseFloat(param[2:], 64)
+				if err != nil {
+					continue
+				}
+				q = v
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		// 只比较主语言标签，例如zh-CN和zh-TW都视为zh
+		primary := strings.ToLower(strings.SplitN(tag, "-", 2)[0])
+		if IsSupported(primary) {
+			candidates = append(candidates, weighted{primary, q})
+		}
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+	// 权重相同时保持请求头中的先后顺序
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].q > candidates[j].q
+	})
+	return candidates[0].lang
+}
@@ -0,0 +1,51 @@
+package i18n
+
+import (
+	"miHttpServer/config"
+	"testing"
+)
+
+func TestResolveLanguage(t *testing.T) {
+	i18nConfig := config.Configs.I18n
+	config.Configs.I18n = config.I18nConfig{
+		DefaultLanguage: English,
+		SiteLanguages:   map[string]string{"jp": Japanese, "ru": Russian, "xx": "fr"},
+	}
+	defer func() { config.Configs.I18n = i18nConfig }()
+
+	tests := []struct {
+		name           string
+		acceptLanguage string
+		appLocal       string
+		want           string
+	}{
+		{name: "没有请求头时使用站点的默认语言", acceptLanguage: "", appLocal: "jp", want: Japanese},
+		{name: "站点没有配置时使用默认语言", acceptLanguage: "", appLocal: "uk", want: English},
+		{name: "站点配置了不支持的语言", acceptLanguage: "", appLocal: "xx", want: English},
+		{name: "请求头优先于站点", acceptLanguage: "ru", appLocal: "jp", want: Russian},
+		{name: "只比较主语言标签", acceptLanguage: "zh-TW", appLocal: "jp", want: Chinese},
+		{name: "主语言标签不区分大小写", acceptLanguage: "JA-jp", appLocal: "ru", want: Japanese},
+		{name: "按权重选择", acceptLanguage: "en;q=0.5, ja;q=0.9", appLocal: "ru", want: Japanese},
+		{name: "权重相同时按先后顺序", acceptLanguage: "ru;q=0.8, en;q=0.8", appLocal: "jp", want: Russian},
+		{name: "跳过不支持的语言", acceptLanguage: "fr-FR, de;q=0.9, en;q=0.1", appLocal: "jp", want: English},
+		{name: "q=0表示不接受", acceptLanguage: "en;q=0", appLocal: "jp", want: Japanese},
+		{name: "权重格式错误时忽略该语言", acceptLanguage: "en;q=abc, ru;q=0.2", appLocal: "jp", want: Russian},
+		{name: "全部不支持时使用站点的默认语言", acceptLanguage: "fr, *", appLocal: "ru", want: Russian},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ResolveLanguage(tt.acceptLanguage, tt.appLocal); got != tt.want {
+				t.Errorf("ResolveLanguage(%q, %q) = %q，应为%q", tt.acceptLanguage, tt.appLocal, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultLanguageFallback(t *testing.T) {
+	defaultLanguage := config.Configs.I18n.DefaultLanguage
+	config.Configs.I18n.DefaultLanguage = "fr"
+	defer func() { config.Configs.I18n.DefaultLanguage = defaultLanguage }()
+	if got := DefaultLanguage(); got != Chinese {
+		t.Errorf("配置了不支持的默认语言时为%q，应为中文", got)
+	}
+}
@@ -41,7 +41,8 @@ func SetMySQLLogger(engine *xorm.Engine) *os.File {
 	if err != nil {
 		log.Fatalln("数据库日志文件创建失败:", err)
 	} else {
-		engine.SetLogger(xormLog.NewSimpleLogger(file))
+		simpleLogger := xormLog.NewSimpleLogger(file)
+		engine.SetLogger(requestSQLLogger{ContextLogger: xormLog.NewLoggerAdapter(simpleLogger), logger: simpleLogger})
 	}
 	// 日志相关设置
 	engine.ShowSQL(true) // 开启SQL语句记录
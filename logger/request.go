@@ -0,0 +1,61 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	xormLog "xorm.io/xorm/log"
+)
+
+type requestIDKey struct{}
+
+// 在context中保存请求ID
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// 获取context中的请求ID，没有时返回空字符串
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// 写入运行日志，ctx中有请求ID时在开头加上[请求ID]，便于查找同一个请求的全部日志
+func Printf(ctx context.Context, format string, v ...interface{}) {
+	log.Output(2, withRequestID(ctx, fmt.Sprintf(format, v...)))
+}
+
+// 写入运行日志，同Printf
+func Println(ctx context.Context, v ...interface{}) {
+	log.Output(2, withRequestID(ctx, fmt.Sprintln(v...)))
+}
+
+func withRequestID(ctx context.Context, message string) string {
+	if requestID := RequestID(ctx); requestID != "" {
+		return "[" + requestID + "] " + message
+	}
+	return message
+}
+
+// 在SQL日志中加上请求ID的xorm日志，只有通过Session.Context传入请求的context时才有请求ID
+type requestSQLLogger struct {
+	xormLog.ContextLogger
+	logger xormLog.Logger
+}
+
+func (l requestSQLLogger) AfterSQL(ctx xormLog.LogContext) {
+	requestID := RequestID(ctx.Ctx)
+	if requestID == "" {
+		l.ContextLogger.AfterSQL(ctx)
+		return
+	}
+	if ctx.ExecuteTime > 0 {
+		l.logger.Infof("[SQL] [%s] %s %v - %v", requestID, ctx.SQL, ctx.Args, ctx.ExecuteTime)
+	} else {
+		l.logger.Infof("[SQL] [%s] %s %v", requestID, ctx.SQL, ctx.Args)
+	}
+}
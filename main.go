@@ -1,17 +1,30 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
+	"miHttpServer/apperror"
 	"miHttpServer/caches"
 	"miHttpServer/config"
 	"miHttpServer/database"
 	"miHttpServer/handlers"
+	"miHttpServer/health"
+	"miHttpServer/i18n"
 	"miHttpServer/logger"
 	"miHttpServer/middlewares"
+	"miHttpServer/models"
+	"miHttpServer/scheduler"
+	"miHttpServer/search"
+	"miHttpServer/storage"
+	"miHttpServer/tracing"
 	"miHttpServer/utils"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -34,58 +47,220 @@ func main() {
 	// 解析配置文件
 	utils.ParseYaml()
 
+	// 设置追踪的导出方式
+	tracingConfig := config.Configs.Tracing
+	if err := tracing.Init(tracingConfig.Exporter, tracingConfig.File, tracingConfig.ServiceName); err != nil {
+		log.Fatalln("设置追踪失败:", err)
+	}
+
 	// 创建一个服务（不使用默认的中间件）
 	ginServer := gin.New()
+	// ctx.Value读取不到时从ctx.Request的context中读取，处理函数可以直接把ctx作为context.Context传入（例如记录带请求ID的日志）
+	ginServer.ContextWithFallback = true
+	// 设置请求ID（最先执行，后面的中间件和日志都可以使用）
+	ginServer.Use(middlewares.RequestID())
 	// 使用自定义的控制台日志格式
 	ginServer.Use(gin.LoggerWithFormatter(middlewares.CustomConsoleLogger))
 	// 使用自定义的文件日志格式
 	ginServer.Use(middlewares.CustomFileLogger(ginLogFile))
+	// 记录请求数和处理时间的监控指标（在Recovery之前，panic的请求也会记录为500）
+	ginServer.Use(middlewares.Metrics())
+	// 记录每个请求的追踪（在Recovery之前，panic的请求也会记录为错误）
+	ginServer.Use(middlewares.Tracing())
 	// 防止服务器产生panic而崩溃，同时返回一个500的HTTP状态码
 	ginServer.Use(gin.Recovery())
 	// 请求头根据url添加app_local参数
 	ginServer.Use(middlewares.SetAppLocal())
+	// 根据请求头X-Admin-Token识别管理员
+	ginServer.Use(middlewares.Admin())
 	// 连接MySQL
 	err := database.InitMySQL()
 	if err != nil {
 		log.Fatalln("连接数据库失败:", err)
 	}
-	// 关闭MySQL连接
-	defer database.CloseMySQL()
+
+	// 从MySQL建立商品搜索索引
+	if n, err := search.Rebuild(context.Background()); err != nil {
+		log.Println("建立商品搜索索引失败:", err)
+	} else {
+		log.Printf("建立商品搜索索引成功，共%d个商品", n)
+	}
 
 	// 连接redis
 	database.InitRedis()
-	// 关闭redis连接
-	defer database.CloseRedis()
 
 	// 初始化本地缓存
-	caches.LocalCache = caches.NewLRUCache(config.Configs.LocalCache.Capacity)
+	caches.LocalCache = caches.NewLRUCache[models.ItemCache](config.Configs.LocalCache.Capacity)
+	caches.CategoryLocalCache = caches.NewLRUCache[models.CategoryCache](config.Configs.LocalCache.Capacity)
 	log.Println("初始化本地缓存成功")
 
+	// 初始化媒体文件存储
+	storage.Blobs, err = storage.NewLocalStore(config.Configs.Media.LocalDir, config.Configs.Media.URLPrefix)
+	if err != nil {
+		log.Fatalln("初始化媒体文件存储失败:", err)
+	}
+
+	// 定期释放过期的库存预留
+	stopSweeper := database.StartReservationSweeper(time.Duration(config.Configs.Stock.SweepIntervalSec) * time.Second)
+
+	// 定期检查定时价格的开始和结束，删除对应商品的缓存
+	stopPriceScheduler := scheduler.StartPriceScheduler(time.Duration(config.Configs.Price.SchedulerIntervalSec) * time.Second)
+
 	// 增加商品信息（从JSON获取）
-	ginServer.PUT("/:app_local/item", handlers.AddItem)
-	ginServer.POST("/:app_local/item", handlers.AddItem)
+	// 支持Idempotency-Key请求头，重试时不会重复创建商品
+	ginServer.PUT("/:app_local/item", middlewares.Idempotency(), handlers.AddItem)
+	ginServer.POST("/:app_local/item", middlewares.Idempotency(), handlers.AddItem)
 
 	// 修改商品信息
 	ginServer.POST("/:app_local/item/:item_id", handlers.UpdateItem)
 
+	// 部分修改商品信息（JSON Merge Patch）
+	ginServer.PATCH("/:app_local/item/:item_id", handlers.PatchItem)
+
 	// 查询商品信息
 	ginServer.GET("/:app_local/item/:item_id", handlers.QueryItem)
 
+	// 分页查询商品的变更历史（包含未发布的版本和操作人，只允许管理员）
+	ginServer.GET("/:app_local/item/:item_id/history", middlewares.RequireAdmin(), handlers.QueryItemHistory)
+
+	// 比较商品的两个版本（只允许管理员）
+	ginServer.GET("/:app_local/item/:item_id/diff", middlewares.RequireAdmin(), handlers.DiffItemVersions)
+
+	// 查询和设置商品在站点的库存
+	ginServer.GET("/:app_local/item/:item_id/stock", handlers.QueryStock)
+	ginServer.PUT("/:app_local/item/:item_id/stock", handlers.SetStock)
+
+	// 预留库存，预留后需要确认或释放
+	ginServer.POST("/:app_local/item/:item_id/stock/reservations", handlers.ReserveStock)
+	ginServer.POST("/:app_local/reservations/:reservation_id/confirm", handlers.ConfirmReservation)
+	ginServer.POST("/:app_local/reservations/:reservation_id/release", handlers.ReleaseReservation)
+
 	// 删除商品信息
 	ginServer.DELETE("/:app_local/item/:item_id", handlers.DeleteItem)
 
+	// 增加、查询、修改和删除商品的SKU
+	ginServer.POST("/:app_local/item/:item_id/skus", handlers.AddSKU)
+	ginServer.GET("/:app_local/item/:item_id/skus", handlers.QuerySKUs)
+	ginServer.GET("/:app_local/item/:item_id/skus/:sku_id", handlers.QuerySKU)
+	ginServer.POST("/:app_local/item/:item_id/skus/:sku_id", handlers.UpdateSKU)
+	ginServer.DELETE("/:app_local/item/:item_id/skus/:sku_id", handlers.DeleteSKU)
+
+	// 修改商品在站点的发布状态（草稿、已发布、已归档），只允许管理员
+	ginServer.POST("/:app_local/item/:item_id/status", middlewares.RequireAdmin(), handlers.TransitionItemStatus)
+
+	// 增加、查询和取消商品在站点的定时价格（促销价）
+	ginServer.POST("/:app_local/item/:item_id/prices", handlers.AddPriceSchedule)
+	ginServer.GET("/:app_local/item/:item_id/prices", handlers.QueryPriceSchedules)
+	ginServer.DELETE("/:app_local/item/:item_id/prices/:schedule_id", handlers.CancelPriceSchedule)
+
+	// 上传、查询和删除商品的媒体文件（图片会生成缩略图）
+	ginServer.POST("/:app_local/item/:item_id/media", handlers.UploadItemMedia)
+	ginServer.GET("/:app_local/item/:item_id/media", handlers.QueryItemMedia)
+	ginServer.DELETE("/:app_local/item/:item_id/media/:media_id", handlers.DeleteItemMedia)
+
+	// 下载本地存储的媒体文件
+	ginServer.GET(config.Configs.Media.URLPrefix+"/*key", handlers.ServeMedia)
+
+	// 批量查询商品信息（GET /:app_local/items?ids=1,2,3）
+	ginServer.GET("/:app_local/items", handlers.BatchQueryItems)
+
+	// 导出全部商品信息（CSV或NDJSON）
+	ginServer.GET("/:app_local/items/export", handlers.ExportItems)
+
+	// 导入商品信息（CSV或NDJSON，支持dry_run）
+	ginServer.POST("/:app_local/items/import", handlers.ImportItems)
+
+	// 按商品名称全文搜索
+	ginServer.GET("/:app_local/items/search", handlers.SearchItems)
+
+	// 从MySQL重建搜索索引（只允许管理员）
+	ginServer.POST("/:app_local/items/search/rebuild", middlewares.RequireAdmin(), handlers.RebuildSearchIndex)
+
+	// 批量增加/修改/删除商品信息（POST /:app_local/items:batch）
+	ginServer.POST("/:app_local/items:method", handlers.ItemsMethod)
+
+	// 增加分类（parent_id为0表示根分类）
+	ginServer.PUT("/:app_local/category", handlers.AddCategory)
+	ginServer.POST("/:app_local/category", handlers.AddCategory)
+
+	// 修改、查询和删除分类
+	ginServer.POST("/:app_local/category/:category_id", handlers.UpdateCategory)
+	ginServer.GET("/:app_local/category/:category_id", handlers.QueryCategory)
+	ginServer.DELETE("/:app_local/category/:category_id", handlers.DeleteCategory)
+
+	// 查询分类树
+	ginServer.GET("/:app_local/categories", handlers.QueryCategoryTree)
+
+	// 分页查询分类（默认包含子孙分类）下的商品
+	ginServer.GET("/:app_local/category/:category_id/items", handlers.QueryCategoryItems)
+
+	// 查询和设置商品所属的分类
+	ginServer.GET("/:app_local/item/:item_id/categories", handlers.QueryItemCategories)
+	ginServer.PUT("/:app_local/item/:item_id/categories", handlers.SetItemCategories)
+
 	// 未匹配到任何路由的请求
 	ginServer.NoRoute(func(ctx *gin.Context) {
-		response := utils.DealRequestError(
-			"未找到相关路由",
-			errors.New("请检查请求的URL是否正确"),
-		)
-		ctx.JSON(http.StatusNotFound, response)
+		utils.RespondError(ctx, apperror.Request(
+			http.StatusNotFound,
+			i18n.RouteNotFound,
+			i18n.NewError(i18n.CheckURL),
+		))
 	})
 
+	// Prometheus监控指标
+	ginServer.GET("/metrics", handlers.Metrics)
+	// 存活检查
+	ginServer.GET("/healthz", handlers.Healthz)
+	// 就绪检查，检查MySQL和Redis，关闭服务时首先失败
+	ginServer.GET("/readyz", handlers.Readyz)
+
 	port := fmt.Sprintf(":%d", config.Configs.Server.Port)
-	err = ginServer.Run(port)
-	if err != nil {
-		log.Fatal("项目启动失败:", err)
+	server := &http.Server{Addr: port, Handler: ginServer}
+	go func() {
+		err := server.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal("项目启动失败:", err)
+		}
+	}()
+	health.SetReady(true)
+	log.Printf("服务启动成功，端口%s", port)
+
+	// 等待关闭信号（Ctrl+C或部署时的SIGTERM）
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-quit
+	log.Printf("收到信号%s，开始关闭服务", sig)
+	shutdown(server, stopSweeper, stopPriceScheduler)
+}
+
+// 按顺序关闭服务：就绪检查失败 -> 停止接收新请求并等待处理中的请求完成 -> 停止后台任务 -> 关闭Redis和MySQL连接池
+// 处理中的请求在完成后才会释放分布式锁，所以连接池必须在请求全部结束后关闭
+func shutdown(server *http.Server, stopWorkers ...func()) {
+	// 先让就绪检查失败，等待负载均衡器停止转发新请求
+	health.SetReady(false)
+	time.Sleep(time.Duration(config.Configs.Server.ReadinessDelaySec) * time.Second)
+
+	// 停止接收新请求，等待处理中的请求完成，超时后强制关闭连接
+	timeout := time.Duration(config.Configs.Server.ShutdownTimeoutSec) * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("等待请求完成超时（%s），强制关闭连接: %s", timeout, err)
+		server.Close()
+	} else {
+		log.Println("处理中的请求已全部完成")
+	}
+
+	// 停止后台任务，等待正在进行的处理完成
+	for _, stop := range stopWorkers {
+		stop()
 	}
+	log.Println("后台任务已停止")
+
+	// 本地缓存和搜索索引只在内存中，不需要保存；后台任务停止后不会再访问Redis和MySQL
+	database.CloseRedis()
+	database.CloseMySQL()
+	// 所有跨度都已结束，关闭追踪文件
+	tracing.Shutdown()
+	log.Println("服务已关闭")
 }
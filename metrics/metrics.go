@@ -0,0 +1,47 @@
+package metrics
+
+// HTTP请求
+var (
+	HTTPRequests = NewCounterVec("http_requests_total",
+		"HTTP请求数", "route", "method", "status", "app_local")
+	HTTPRequestDuration = NewHistogramVec("http_request_duration_seconds",
+		"HTTP请求的处理时间（秒）", DefaultBuckets, "route", "method", "status", "app_local")
+)
+
+// 缓存，cache为缓存的数据（item、category），tier为缓存层级（local、redis），result为hit、miss或error
+var CacheRequests = NewCounterVec("cache_requests_total",
+	"按层级统计的缓存查询次数", "cache", "tier", "result")
+
+// 分布式锁，result为acquired、timeout或error
+var (
+	LockAcquires = NewCounterVec("lock_acquire_total",
+		"获取分布式锁的次数", "result")
+	LockAttempts = NewCounterVec("lock_attempts_total",
+		"获取分布式锁时执行SET NX的次数（包括重试）")
+	LockTimeouts = NewCounterVec("lock_timeouts_total",
+		"等待分布式锁超时的次数")
+	LockWaitDuration = NewHistogramVec("lock_wait_seconds",
+		"获取分布式锁的等待时间（秒）", DefaultBuckets, "result")
+)
+
+// MySQL查询，operation为SQL语句的类型（select、insert、update、delete等）
+var MySQLQueryDuration = NewHistogramVec("mysql_query_duration_seconds",
+	"MySQL语句的执行时间（秒）", DefaultBuckets, "operation")
+
+// Redis连接池，state为active（已建立的全部连接）或idle（空闲连接）
+var RedisPoolConnections = NewGaugeFunc("redis_pool_connections",
+	"Redis连接池的连接数", "state")
+
+// 记录一次缓存查询
+func CacheResult(cache, tier string, hit bool) {
+	if hit {
+		CacheRequests.Inc(cache, tier, "hit")
+	} else {
+		CacheRequests.Inc(cache, tier, "miss")
+	}
+}
+
+// 记录一次失败的缓存查询
+func CacheError(cache, tier string) {
+	CacheRequests.Inc(cache, tier, "error")
+}
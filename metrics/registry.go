@@ -0,0 +1,250 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 可以按Prometheus文本格式输出的指标
+type collector interface {
+	write(w io.Writer)
+}
+
+// 已注册的全部指标，按注册顺序输出
+var (
+	collectors []collector
+	registerMu sync.Mutex
+)
+
+func register(c collector) {
+	registerMu.Lock()
+	defer registerMu.Unlock()
+	collectors = append(collectors, c)
+}
+
+// 按Prometheus文本格式（version 0.0.4）输出全部指标
+func WriteText(w io.Writer) {
+	registerMu.Lock()
+	list := append([]collector(nil), collectors...)
+	registerMu.Unlock()
+	for _, c := range list {
+		c.write(w)
+	}
+}
+
+// 指标的名称、说明和标签名
+type desc struct {
+	name   string
+	help   string
+	labels []string
+}
+
+func (d desc) writeHeader(w io.Writer, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", d.name, escapeHelp(d.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.name, kind)
+}
+
+// 将标签值拼接为map的键
+func seriesKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+// 标签的文本格式，extra为额外的标签（例如直方图的le）
+func formatLabels(names, values []string, extra ...string) string {
+	if len(names) == 0 && len(extra) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(names)+len(extra)/2)
+	for i, name := range names {
+		pairs = append(pairs, name+`="`+escapeLabel(values[i])+`"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+escapeLabel(extra[i+1])+`"`)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// 按标签值排序的键，保证每次输出的顺序相同
+func sortedKeys[V any](series map[string]V) []string {
+	keys := make([]string, 0, len(series))
+	for key := range series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// 计数器，按标签值分别累加
+type CounterVec struct {
+	desc
+	mu     sync.Mutex
+	series map[string]*counterSeries
+}
+
+type counterSeries struct {
+	values []string
+	value  float64
+}
+
+// 创建并注册计数器
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{desc: desc{name, help, labels}, series: make(map[string]*counterSeries)}
+	register(c)
+	return c
+}
+
+// 计数加1，values的顺序与创建时的标签名相同
+func (c *CounterVec) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// 计数增加delta
+func (c *CounterVec) Add(delta float64, values ...string) {
+	key := seriesKey(values)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.series[key]
+	if !ok {
+		s = &counterSeries{values: append([]string(nil), values...)}
+		c.series[key] = s
+	}
+	s.value += delta
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writeHeader(w, "counter")
+	for _, key := range sortedKeys(c.series) {
+		s := c.series[key]
+		fmt.Fprintf(w, "%s%s %s\n", c.name, formatLabels(c.labels, s.values), formatFloat(s.value))
+	}
+}
+
+// 默认的直方图分桶（秒），与Prometheus客户端库相同
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// 直方图，按标签值分别统计观测值的分布
+type HistogramVec struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	series  map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	values []string
+	counts []uint64 // 每个分桶（不累加）的观测次数
+	sum    float64
+	count  uint64
+}
+
+// 创建并注册直方图，buckets为升序的分桶上限
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{
+		desc:    desc{name, help, labels},
+		buckets: buckets,
+		series:  make(map[string]*histogramSeries),
+	}
+	register(h)
+	return h
+}
+
+// 记录一次观测值
+func (h *HistogramVec) Observe(value float64, values ...string) {
+	key := seriesKey(values)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{values: append([]string(nil), values...), counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	if i := sort.SearchFloat64s(h.buckets, value); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.sum += value
+	s.count++
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.writeHeader(w, "histogram")
+	for _, key := range sortedKeys(h.series) {
+		s := h.series[key]
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, s.values, "le", formatFloat(upper)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, s.values, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, formatLabels(h.labels, s.values), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, formatLabels(h.labels, s.values), s.count)
+	}
+}
+
+// 输出时才读取当前值的仪表盘，collect返回标签值到当前值的对应关系（只能有一个标签）
+type GaugeFunc struct {
+	desc
+	mu      sync.Mutex
+	collect func() map[string]float64
+}
+
+// 创建并注册仪表盘，collect为nil时不输出数据
+func NewGaugeFunc(name, help, label string) *GaugeFunc {
+	g := &GaugeFunc{desc: desc{name, help, []string{label}}}
+	register(g)
+	return g
+}
+
+// 设置读取当前值的函数
+func (g *GaugeFunc) Set(collect func() map[string]float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.collect = collect
+}
+
+func (g *GaugeFunc) write(w io.Writer) {
+	g.mu.Lock()
+	collect := g.collect
+	g.mu.Unlock()
+	g.writeHeader(w, "gauge")
+	if collect == nil {
+		return
+	}
+	values := collect()
+	for _, key := range sortedKeys(values) {
+		fmt.Fprintf(w, "%s%s %s\n", g.name, formatLabels(g.labels, []string{key}), formatFloat(values[key]))
+	}
+}
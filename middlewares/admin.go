@@ -0,0 +1,49 @@
+package middlewares
+
+import (
+	"crypto/subtle"
+	"miHttpServer/apperror"
+	"miHttpServer/config"
+	"miHttpServer/i18n"
+	"miHttpServer/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 管理员标记在gin.Context中的键
+const adminKey = "is_admin"
+
+// 识别管理员：请求头X-Admin-Token与配置的管理员令牌之一相同时标记为管理员
+func Admin() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		token := ctx.GetHeader("X-Admin-Token")
+		if token != "" {
+			for _, adminToken := range config.Configs.Admin.Tokens {
+				// 使用固定时间比较，避免通过响应时间猜测令牌
+				if subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1 {
+					ctx.Set(adminKey, true)
+					break
+				}
+			}
+		}
+		ctx.Next()
+	}
+}
+
+// 只允许管理员访问，需要在Admin之后使用
+func RequireAdmin() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !IsAdmin(ctx) {
+			utils.RespondError(ctx, apperror.Request(http.StatusForbidden, i18n.Forbidden, i18n.NewError(i18n.AdminRequired)))
+			ctx.Abort()
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// 请求是否来自管理员
+func IsAdmin(ctx *gin.Context) bool {
+	return ctx.GetBool(adminKey)
+}
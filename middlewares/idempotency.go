@@ -0,0 +1,172 @@
+package middlewares
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"miHttpServer/apperror"
+	"miHttpServer/config"
+	"miHttpServer/database"
+	"miHttpServer/logger"
+	"miHttpServer/models"
+	"miHttpServer/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 记录响应内容的ResponseWriter
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// 保存到幂等记录中，重放时恢复的响应头
+// 不保存X-Request-ID，重放的响应使用本次请求的请求ID，便于按请求ID查找日志
+var idempotentHeaders = []string{"Retry-After"}
+
+// 支持Idempotency-Key请求头，保证重试创建请求时不会重复创建
+// 第一次请求的响应保存到Redis中，相同幂等键和相同请求内容的重试直接返回保存的响应；
+// 相同幂等键但请求内容不同返回422；第一次请求还在处理中时返回409
+// 只保存最终的响应，服务端错误和可重试的错误（例如获取锁超时）删除记录，允许客户端使用相同的幂等键重试
+func Idempotency() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		idempotencyKey := ctx.GetHeader("Idempotency-Key")
+		if idempotencyKey == "" {
+			ctx.Next()
+			return
+		}
+		cfg := config.Configs.Idempotency
+		if cfg.MaxKeyLength > 0 && len(idempotencyKey) > cfg.MaxKeyLength {
+			utils.RespondError(ctx, apperror.InvalidIdempotencyKey(cfg.MaxKeyLength))
+			ctx.Abort()
+			return
+		}
+
+		// 读取请求体计算哈希，再放回去供后面的handler读取
+		data, err := ctx.GetRawData()
+		if err != nil {
+			utils.RespondError(ctx, apperror.InvalidJSON(err))
+			ctx.Abort()
+			return
+		}
+		ctx.Request.Body = io.NopCloser(bytes.NewReader(data))
+		hash := sha256.New()
+		hash.Write([]byte(ctx.Request.Method + " " + ctx.Request.URL.Path + "\n"))
+		hash.Write(data)
+		requestHash := hex.EncodeToString(hash.Sum(nil))
+
+		// 幂等键按路由区分，不同接口可以使用相同的幂等键
+		recordKey := ctx.Request.Method + ":" + ctx.Request.URL.Path + ":" + idempotencyKey
+		retryAfter := config.Configs.Lock.RetryAfterSec
+
+		// 先写入处理中的记录，写入成功说明是第一次请求
+		inFlight, _ := json.Marshal(models.IdempotencyRecord{
+			State:       models.IdempotencyInFlight,
+			RequestHash: requestHash,
+		})
+		ok, err := database.SetIdempotencyRecord(ctx.Request.Context(), recordKey, inFlight, cfg.InFlightExpireSec, true)
+		if err != nil {
+			utils.RespondError(ctx, apperror.LockUnavailable(retryAfter, err))
+			ctx.Abort()
+			return
+		}
+		if !ok {
+			replayIdempotentResponse(ctx, recordKey, requestHash, retryAfter)
+			ctx.Abort()
+			return
+		}
+
+		deleteRecord := func() {
+			if err := database.DeleteIdempotencyRecord(ctx.Request.Context(), recordKey); err != nil {
+				logger.Printf(ctx, "删除幂等记录%s失败: %s", recordKey, err)
+			}
+		}
+		// handler发生panic时删除处理中的记录，再交给Recovery处理，否则重试会一直返回处理中
+		defer func() {
+			if r := recover(); r != nil {
+				deleteRecord()
+				panic(r)
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: ctx.Writer}
+		ctx.Writer = recorder
+		ctx.Next()
+
+		if retryableResponse(recorder) {
+			deleteRecord()
+			return
+		}
+		headers := make(map[string]string, len(idempotentHeaders))
+		for _, name := range idempotentHeaders {
+			if value := recorder.Header().Get(name); value != "" {
+				headers[name] = value
+			}
+		}
+		completed, _ := json.Marshal(models.IdempotencyRecord{
+			State:       models.IdempotencyCompleted,
+			RequestHash: requestHash,
+			StatusCode:  recorder.Status(),
+			ContentType: recorder.Header().Get("Content-Type"),
+			Body:        recorder.body.Bytes(),
+			Headers:     headers,
+		})
+		if _, err := database.SetIdempotencyRecord(ctx.Request.Context(), recordKey, completed, cfg.ExpireSec, false); err != nil {
+			logger.Printf(ctx, "保存幂等记录%s失败: %s", recordKey, err)
+		}
+	}
+}
+
+// 判断响应是否可以重试：服务端错误、请求过多和带Retry-After响应头的错误（例如获取锁超时的409）
+// 这些响应不是最终结果，不能保存
+func retryableResponse(w gin.ResponseWriter) bool {
+	status := w.Status()
+	return status >= http.StatusInternalServerError ||
+		status == http.StatusTooManyRequests ||
+		w.Header().Get("Retry-After") != ""
+}
+
+// 幂等键已存在时，根据记录返回保存的响应或错误
+func replayIdempotentResponse(ctx *gin.Context, recordKey, requestHash string, retryAfter int) {
+	data, exist, err := database.GetIdempotencyRecord(ctx.Request.Context(), recordKey)
+	if err != nil {
+		utils.RespondError(ctx, apperror.LockUnavailable(retryAfter, err))
+		return
+	}
+	if !exist {
+		// 记录在两次访问之间过期，视为仍在处理中，让客户端重试
+		utils.RespondError(ctx, apperror.IdempotencyInProgress(retryAfter))
+		return
+	}
+	var record models.IdempotencyRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		utils.RespondError(ctx, err)
+		return
+	}
+	if record.RequestHash != requestHash {
+		utils.RespondError(ctx, apperror.IdempotencyKeyReused())
+		return
+	}
+	if record.State != models.IdempotencyCompleted {
+		utils.RespondError(ctx, apperror.IdempotencyInProgress(retryAfter))
+		return
+	}
+	for name, value := range record.Headers {
+		ctx.Header(name, value)
+	}
+	ctx.Header("Idempotent-Replayed", "true")
+	ctx.Data(record.StatusCode, record.ContentType, record.Body)
+}
@@ -0,0 +1,127 @@
+package middlewares
+
+import (
+	"log"
+	"miHttpServer/config"
+	"miHttpServer/database"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+)
+
+// 使用miniredis保存幂等记录
+func TestMain(m *testing.M) {
+	redisServer, err := miniredis.Run()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	config.Configs.Redis.Protocal = "tcp"
+	config.Configs.Redis.Address = redisServer.Addr()
+	config.Configs.Redis.MaxIdle = 2
+	config.Configs.Idempotency = config.IdempotencyConfig{ExpireSec: 60, InFlightExpireSec: 60, MaxKeyLength: 64}
+	config.Configs.Lock.RetryAfterSec = 1
+	database.InitRedis()
+	gin.SetMode(gin.TestMode)
+
+	code := m.Run()
+	database.CloseRedis()
+	redisServer.Close()
+	os.Exit(code)
+}
+
+// 创建只有一个使用幂等键的路由的服务，返回handler被调用的次数
+func newIdempotentRouter(handler func(ctx *gin.Context, calls int)) (*gin.Engine, *int) {
+	calls := 0
+	router := gin.New()
+	router.Use(gin.CustomRecovery(func(ctx *gin.Context, _ any) {
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+	}), RequestID())
+	router.POST("/uk/item", Idempotency(), func(ctx *gin.Context) {
+		calls++
+		handler(ctx, calls)
+	})
+	return router, &calls
+}
+
+func postWithKey(router *gin.Engine, key string) *httptest.ResponseRecorder {
+	return postWithRequestID(router, key, "")
+}
+
+func postWithRequestID(router *gin.Engine, key, requestID string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPost, "/uk/item", strings.NewReader(`{"name": "a", "price": 1}`))
+	request.Header.Set("Idempotency-Key", key)
+	if requestID != "" {
+		request.Header.Set(RequestIDHeader, requestID)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestIdempotencyRetryableResponseNotStored(t *testing.T) {
+	router, calls := newIdempotentRouter(func(ctx *gin.Context, calls int) {
+		if calls == 1 {
+			// 获取锁超时
+			ctx.Header("Retry-After", "1")
+			ctx.JSON(http.StatusConflict, gin.H{"error_code": "LOCK_TIMEOUT"})
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{"code": 0})
+	})
+
+	if recorder := postWithKey(router, "retryable"); recorder.Code != http.StatusConflict {
+		t.Fatalf("第一次请求的状态码为%d，应为409", recorder.Code)
+	}
+	recorder := postWithKey(router, "retryable")
+	if recorder.Code != http.StatusOK || *calls != 2 {
+		t.Fatalf("重试的状态码为%d，handler调用%d次，应为200和2次", recorder.Code, *calls)
+	}
+	if recorder.Header().Get("Idempotent-Replayed") != "" {
+		t.Error("可重试的响应不应被重放")
+	}
+}
+
+func TestIdempotencyPanicDeletesRecord(t *testing.T) {
+	router, calls := newIdempotentRouter(func(ctx *gin.Context, calls int) {
+		if calls == 1 {
+			panic("handler failed")
+		}
+		ctx.JSON(http.StatusOK, gin.H{"code": 0})
+	})
+
+	if recorder := postWithKey(router, "panic"); recorder.Code != http.StatusInternalServerError {
+		t.Fatalf("第一次请求的状态码为%d，应为500", recorder.Code)
+	}
+	// 处理中的记录已删除，重试不会返回处理中的409
+	recorder := postWithKey(router, "panic")
+	if recorder.Code != http.StatusOK || *calls != 2 {
+		t.Fatalf("重试的状态码为%d，handler调用%d次，应为200和2次", recorder.Code, *calls)
+	}
+}
+
+func TestIdempotencyReplayKeepsCurrentRequestID(t *testing.T) {
+	router, calls := newIdempotentRouter(func(ctx *gin.Context, calls int) {
+		ctx.JSON(http.StatusOK, gin.H{"code": 0, "request_id": ctx.GetString("request_id")})
+	})
+
+	postWithRequestID(router, "replay", "first-request")
+	recorder := postWithRequestID(router, "replay", "second-request")
+	if *calls != 1 {
+		t.Fatalf("handler调用%d次，应为1次", *calls)
+	}
+	if recorder.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("重试没有返回保存的响应")
+	}
+	// 响应体是保存的第一次请求的响应，响应头是本次请求的请求ID
+	if got := recorder.Header().Get(RequestIDHeader); got != "second-request" {
+		t.Errorf("X-Request-ID为%q，应为second-request", got)
+	}
+	if !strings.Contains(recorder.Body.String(), `"request_id":"first-request"`) {
+		t.Errorf("重放的响应体为%s，应为第一次请求的响应", recorder.Body.String())
+	}
+}
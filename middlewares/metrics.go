@@ -0,0 +1,38 @@
+package middlewares
+
+import (
+	"miHttpServer/metrics"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 记录HTTP请求数和处理时间的监控指标
+// route使用路由模板（例如/:app_local/item/:item_id）而不是实际路径，避免标签值过多；未匹配任何路由时为unmatched
+// app_local只记录支持的站点，其他值（被SetAppLocal拒绝的请求）记录为other
+func Metrics() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Next()
+
+		// 未匹配路由时ctx.Param可能返回部分匹配的路径参数，不作为标签
+		route, appLocal := ctx.FullPath(), ""
+		if route == "" {
+			route = "unmatched"
+		} else {
+			appLocal = appLocalLabel(ctx.Param("app_local"))
+		}
+		status := strconv.Itoa(ctx.Writer.Status())
+		metrics.HTTPRequests.Inc(route, ctx.Request.Method, status, appLocal)
+		metrics.HTTPRequestDuration.Observe(time.Since(start).Seconds(), route, ctx.Request.Method, status, appLocal)
+	}
+}
+
+// app_local标签的值，不支持的站点统一为other，避免任意路径参数产生新的时间序列
+func appLocalLabel(appLocal string) string {
+	if appLocal == "" || validAppLocal(appLocal) {
+		return appLocal
+	}
+	return "other"
+}
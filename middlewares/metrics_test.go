@@ -0,0 +1,37 @@
+package middlewares
+
+import (
+	"bytes"
+	"miHttpServer/metrics"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMetricsAppLocalLabel(t *testing.T) {
+	router := gin.New()
+	router.Use(Metrics(), SetAppLocal())
+	router.GET("/:app_local/metrics-test", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+
+	for _, appLocal := range []string{"jp", "xx", "unknown-site"} {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/"+appLocal+"/metrics-test", nil))
+	}
+
+	var buf bytes.Buffer
+	metrics.WriteText(&buf)
+	text := buf.String()
+	for _, want := range []string{`app_local="jp"`, `app_local="other"`} {
+		if !strings.Contains(text, want) {
+			t.Errorf("监控指标中没有%s", want)
+		}
+	}
+	for _, unwanted := range []string{`app_local="xx"`, `app_local="unknown-site"`} {
+		if strings.Contains(text, unwanted) {
+			t.Errorf("不支持的站点%s不应作为标签值", unwanted)
+		}
+	}
+}
@@ -2,8 +2,12 @@ package middlewares
 
 import (
 	"fmt"
-	"miHttpServer/models"
+	"miHttpServer/apperror"
+	"miHttpServer/i18n"
+	"miHttpServer/utils"
+	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -12,7 +16,7 @@ import (
 // 自定义控制台日志输出格式
 func CustomConsoleLogger(params gin.LogFormatterParams) string {
 	return fmt.Sprintf(
-		"[miHttpServerGin] %s |%s %d %s|  %s %s %s	 %s\n",
+		"[miHttpServerGin] %s |%s %d %s|  %s %s %s	 %s	 %s\n",
 		params.TimeStamp.Format("2006-01-02 15:04:05"),
 		params.StatusCodeColor(),
 		params.StatusCode,
@@ -21,6 +25,7 @@ func CustomConsoleLogger(params gin.LogFormatterParams) string {
 		params.Method,
 		params.ResetColor(),
 		params.Path,
+		params.Keys["request_id"],
 	)
 }
 
@@ -36,32 +41,44 @@ func CustomFileLogger(ginLogFile *os.File) gin.HandlerFunc {
 		}
 		// 记录日志到文件
 		ginLogFile.WriteString(fmt.Sprintf(
-			"[miHttpServerGin] %s |%d|  %s	 %s\n",
+			"[miHttpServerGin] %s |%d|  %s	 %s	 %s\n",
 			params.TimeStamp.Format("2006-01-02 15:04:05"),
 			params.StatusCode,
 			params.Method,
 			params.Path,
+			ctx.GetString("request_id"),
 		))
 	}
 }
 
+// 支持的站点
+func validAppLocal(appLocal string) bool {
+	return appLocal == "uk" || appLocal == "jp" || appLocal == "ru"
+}
+
 // 根据请求URL的app_local参数设置请求头
 func SetAppLocal() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		response := models.ResponseData{}
+		// 路径中不包含app_local的路由（例如下载媒体文件）不需要检查
+		if fullPath := ctx.FullPath(); fullPath != "" && !strings.Contains(fullPath, ":app_local") {
+			ctx.Next()
+			return
+		}
 		appLocal := ctx.Param("app_local")
 		if appLocal == "" {
-			response.Code = 1
-			response.Msg = "请求参数app_local为空"
-			response.Data = "缺少app_local参数，应为uk、jp和ru中的一个，例如http://localhost:8080/uk/item/"
-			ctx.JSON(400, response)
+			utils.RespondError(ctx, apperror.Request(
+				http.StatusBadRequest,
+				i18n.AppLocalEmpty,
+				i18n.NewError(i18n.AppLocalEmptyDetail),
+			))
 			ctx.Abort()
 			return
-		} else if appLocal != "uk" && appLocal != "jp" && appLocal != "ru" {
-			response.Code = 1
-			response.Msg = "请求参数app_local非法"
-			response.Data = "app_local参数应为uk、jp和ru中的一个，例如http://localhost:8080/uk/item/"
-			ctx.JSON(400, response)
+		} else if !validAppLocal(appLocal) {
+			utils.RespondError(ctx, apperror.Request(
+				http.StatusBadRequest,
+				i18n.AppLocalInvalid,
+				i18n.NewError(i18n.AppLocalInvalidDetail),
+			))
 			ctx.Abort()
 			return
 		} else {
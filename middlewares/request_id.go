@@ -0,0 +1,42 @@
+package middlewares
+
+import (
+	"miHttpServer/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// 请求ID的请求头和响应头
+const RequestIDHeader = "X-Request-ID"
+
+// 请求ID的最大长度（与变更历史中的request_id字段一致）
+const maxRequestIDLength = 64
+
+// 为每个请求设置请求ID：请求头X-Request-ID有效时沿用调用方的ID，否则生成新的UUID
+// 请求ID保存在ctx.Request的context和ctx.Keys中，并通过响应头X-Request-ID返回
+func RequestID() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		requestID := ctx.GetHeader(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = uuid.New().String()
+		}
+		ctx.Set("request_id", requestID)
+		ctx.Request = ctx.Request.WithContext(logger.WithRequestID(ctx.Request.Context(), requestID))
+		ctx.Header(RequestIDHeader, requestID)
+		ctx.Next()
+	}
+}
+
+// 请求ID只允许字母、数字和-_.:，防止写入日志和响应头时被注入其他内容
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range requestID {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.' || c == ':') {
+			return false
+		}
+	}
+	return true
+}
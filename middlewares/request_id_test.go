@@ -0,0 +1,67 @@
+package middlewares
+
+import (
+	"miHttpServer/logger"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func TestValidRequestID(t *testing.T) {
+	tests := []struct {
+		name      string
+		requestID string
+		want      bool
+	}{
+		{name: "UUID", requestID: uuid.New().String(), want: true},
+		{name: "允许的符号", requestID: "trace_1.2:3-4", want: true},
+		{name: "最大长度", requestID: strings.Repeat("a", maxRequestIDLength), want: true},
+		{name: "空", requestID: "", want: false},
+		{name: "超过最大长度", requestID: strings.Repeat("a", maxRequestIDLength+1), want: false},
+		{name: "换行", requestID: "abc\r\nX-Admin: 1", want: false},
+		{name: "空格", requestID: "a b", want: false},
+		{name: "中文", requestID: "请求1", want: false},
+		{name: "斜杠", requestID: "a/b", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validRequestID(tt.requestID); got != tt.want {
+				t.Errorf("validRequestID(%q) = %v，应为%v", tt.requestID, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestIDHeader(t *testing.T) {
+	router := gin.New()
+	router.Use(RequestID())
+	router.GET("/", func(ctx *gin.Context) {
+		// 请求ID同时保存在ctx.Keys和请求的context中
+		if ctx.GetString("request_id") != logger.RequestID(ctx.Request.Context()) {
+			t.Errorf("ctx.Keys中的请求ID为%q，context中为%q", ctx.GetString("request_id"), logger.RequestID(ctx.Request.Context()))
+		}
+	})
+	serveWithID := func(requestID string) string {
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		if requestID != "" {
+			request.Header.Set(RequestIDHeader, requestID)
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		return recorder.Header().Get(RequestIDHeader)
+	}
+
+	if got := serveWithID("caller-id-1"); got != "caller-id-1" {
+		t.Errorf("有效的请求ID应沿用，响应头为%q", got)
+	}
+	for _, requestID := range []string{"", "bad id"} {
+		got := serveWithID(requestID)
+		if _, err := uuid.Parse(got); err != nil {
+			t.Errorf("请求ID为%q时应生成新的UUID，响应头为%q", requestID, got)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package middlewares
+
+import (
+	"miHttpServer/tracing"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// 为每个请求开始一个服务端跨度，请求头traceparent有效时延续调用方的追踪
+// 跨度保存在ctx.Request的context中，缓存、分布式锁、Redis和MySQL的跨度都是它的子跨度
+func Tracing() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		route, appLocal := ctx.FullPath(), ""
+		if route == "" {
+			route = "unmatched"
+		} else {
+			appLocal = ctx.Param("app_local")
+		}
+		spanCtx, span := tracing.StartServer(ctx.Request.Context(), ctx.Request.Method+" "+route, ctx.Request.Header)
+		if !span.IsRecording() {
+			ctx.Next()
+			return
+		}
+		ctx.Request = ctx.Request.WithContext(spanCtx)
+		span.SetAttributes(
+			attribute.String("http.method", ctx.Request.Method),
+			attribute.String("http.route", route),
+			attribute.String("http.target", ctx.Request.URL.RequestURI()),
+		)
+		if requestID := ctx.GetString("request_id"); requestID != "" {
+			span.SetAttributes(attribute.String("request_id", requestID))
+		}
+		if appLocal != "" {
+			span.SetAttributes(attribute.String("app_local", appLocal))
+		}
+
+		ctx.Next()
+
+		status := ctx.Writer.Status()
+		span.SetAttributes(attribute.Int("http.status_code", status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+		span.End()
+	}
+}
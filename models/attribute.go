@@ -0,0 +1,33 @@
+package models
+
+// 属性值的类型
+const (
+	AttributeString = "string"
+	AttributeNumber = "number"
+	AttributeBool   = "bool"
+)
+
+// 商品的自定义属性（键值对），和MySQL表同步的结构体
+// 属性值统一保存为字符串，value_type记录原始类型，查询时还原
+type ItemAttribute struct {
+	ItemID    int64  `xorm:"'item_id' pk" json:"item_id"`
+	Key       string `xorm:"varchar(64) pk index(idx_attr_value) 'attr_key'" json:"key"`
+	ValueType string `xorm:"varchar(8) 'value_type'" json:"value_type"`
+	Value     string `xorm:"varchar(255) index(idx_attr_value) 'attr_value'" json:"value"`
+}
+
+// 商品的标签，和MySQL表同步的结构体
+type ItemTag struct {
+	ItemID int64  `xorm:"'item_id' pk" json:"item_id"`
+	Tag    string `xorm:"varchar(64) pk index 'tag'" json:"tag"`
+}
+
+// 按属性和标签筛选商品的条件，多个条件之间为“且”的关系
+type ItemFilter struct {
+	// 属性名 -> 属性值（字符串形式）
+	Attributes map[string]string
+	// 商品必须包含所有标签
+	Tags []string
+	// 不为空时只返回在该站点已发布的商品
+	PublishedSite string
+}
@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+// 商品变更操作类型
+const (
+	AuditAdd     = "add"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+	// 修改商品在站点的发布状态，记录的site为状态所属的站点
+	AuditStatus = "status"
+	// 增加、修改和删除商品的SKU
+	AuditSKUAdd    = "sku_add"
+	AuditSKUUpdate = "sku_update"
+	AuditSKUDelete = "sku_delete"
+)
+
+// 商品变更历史，和MySQL表同步的结构体
+// 每次增加、修改、删除、恢复商品时，在同一个事务中写入一条记录
+type ItemAudit struct {
+	AuditID   int64         `xorm:"'audit_id' pk autoincr" json:"audit_id"`
+	ItemID    int64         `xorm:"'item_id' index" json:"item_id"`
+	Operation string        `xorm:"varchar(16) 'operation'" json:"operation"`
+	Actor     string        `xorm:"varchar(64) 'actor'" json:"actor"`
+	Site      string        `xorm:"varchar(8) 'site'" json:"site"`
+	RequestID string        `xorm:"varchar(64) 'request_id'" json:"request_id"`
+	Before    *ItemSnapshot `xorm:"json text 'before'" json:"before"`
+	After     *ItemSnapshot `xorm:"json text 'after'" json:"after"`
+	CreatedAt time.Time     `xorm:"created index" json:"created_at"`
+}
+
+// 变更前后的商品快照，新增时before为空，删除时after为空
+type ItemSnapshot struct {
+	ItemID int64   `json:"item_id"`
+	Name   string  `json:"name"`
+	Price  float64 `json:"price"`
+	Site   string  `json:"site"`
+	// 修改发布状态时记录变更前后在该站点的状态，其他操作为空
+	Status string `json:"status,omitempty"`
+	// 增加、修改和删除SKU时记录变更前后的SKU，其他操作为空
+	SKU *ItemSKU `json:"sku,omitempty"`
+}
+
+// 发起变更的请求信息，随写操作一起传入数据库层
+type AuditInfo struct {
+	Actor     string
+	Site      string
+	RequestID string
+}
+
+// 根据商品生成快照
+func NewItemSnapshot(item Item) *ItemSnapshot {
+	return &ItemSnapshot{
+		ItemID: item.ItemID,
+		Name:   item.Name,
+		Price:  item.Price,
+		Site:   item.Site,
+	}
+}
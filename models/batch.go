@@ -0,0 +1,36 @@
+package models
+
+// 批量操作的类型
+const (
+	BatchCreate = "create"
+	BatchUpdate = "update"
+	BatchDelete = "delete"
+)
+
+// 批量操作请求的结构体
+type BatchRequest struct {
+	// 为true时所有操作在同一个事务中执行，任意操作失败则全部回滚
+	// 为false时逐个执行，返回每个操作的结果
+	Atomic     bool             `json:"atomic"`
+	Operations []BatchOperation `json:"operations"`
+}
+
+// 单个批量操作
+type BatchOperation struct {
+	Op     string  `json:"op"`
+	ItemID int64   `json:"item_id"`
+	Name   string  `json:"name"`
+	Price  float64 `json:"price"`
+}
+
+// 单个批量操作的结果
+type BatchResult struct {
+	Index     int                    `json:"index"`
+	Op        string                 `json:"op"`
+	ItemID    int64                  `json:"item_id,omitempty"`
+	Success   bool                   `json:"success"`
+	ErrorCode string                 `json:"error_code,omitempty"`
+	Message   string                 `json:"message,omitempty"`
+	Errors    []FieldErrorData       `json:"errors,omitempty"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+}
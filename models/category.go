@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// 商品分类，和MySQL表同步的结构体
+// path为从根分类到当前分类的category_id路径，例如/1/5/9/，用于查询所有子孙分类
+// path有索引，utf8mb4下最长768个字符（3072字节，InnoDB索引的长度上限）
+type Category struct {
+	CategoryID int64     `xorm:"'category_id' pk autoincr" json:"category_id"`
+	ParentID   int64     `xorm:"'parent_id' index notnull default 0" json:"parent_id"`
+	Name       string    `xorm:"varchar(255)" json:"name"`
+	Path       string    `xorm:"varchar(768) index 'path'" json:"path"`
+	CreatedAt  time.Time `xorm:"created" json:"created_at"`
+	UpdatedAt  time.Time `xorm:"updated" json:"updated_at"`
+}
+
+// 商品和分类的多对多关系，和MySQL表同步的结构体
+type ItemCategory struct {
+	ItemID     int64 `xorm:"'item_id' pk" json:"item_id"`
+	CategoryID int64 `xorm:"'category_id' pk index" json:"category_id"`
+}
+
+// 分类的缓存结构体（本地缓存和Redis缓存）
+type CategoryCache struct {
+	CategoryID int64  `json:"category_id"`
+	ParentID   int64  `json:"parent_id"`
+	Name       string `json:"name"`
+	Path       string `json:"path"`
+}
+
+// 增加和修改分类时请求的json，parent_id为0表示根分类
+type CategoryRequest struct {
+	Name     string `json:"name"`
+	ParentID int64  `json:"parent_id"`
+}
+
+// 设置商品所属分类时请求的json
+type ItemCategoriesRequest struct {
+	CategoryIDs []int64 `json:"category_ids"`
+}
+
+// 根据分类生成缓存数据
+func NewCategoryCache(category Category) CategoryCache {
+	return CategoryCache{
+		CategoryID: category.CategoryID,
+		ParentID:   category.ParentID,
+		Name:       category.Name,
+		Path:       category.Path,
+	}
+}
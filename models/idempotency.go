@@ -0,0 +1,20 @@
+package models
+
+// 幂等记录的状态
+const (
+	// 请求正在处理中
+	IdempotencyInFlight = "in_flight"
+	// 请求已处理完成，保存了响应
+	IdempotencyCompleted = "completed"
+)
+
+// 保存在Redis中的幂等记录
+type IdempotencyRecord struct {
+	State       string `json:"state"`
+	RequestHash string `json:"request_hash"`
+	StatusCode  int    `json:"status_code,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+	// 重放时需要恢复的响应头（Retry-After、X-Request-ID）
+	Headers map[string]string `json:"headers,omitempty"`
+}
@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // 和MySQL表同步的结构体
 type Item struct {
@@ -9,13 +12,88 @@ type Item struct {
 	Price     float64   `xorm:"decimal(10,2)" json:"price"`
 	CreatedAt time.Time `xorm:"created" json:"created_at"`
 	UpdatedAt time.Time `xorm:"updated" json:"updated_at"`
+	// 创建商品的站点（app_local）
+	Site string `xorm:"varchar(8) 'site'" json:"site"`
+	// 名称唯一键：名称全局唯一时为名称，按站点唯一时为“站点/名称”
+	NameKey string `xorm:"varchar(270) unique 'name_key'" json:"-"`
+	// 自定义属性和标签，分别保存在item_attribute和item_tag表中
+	Attributes map[string]interface{} `xorm:"-" json:"attributes,omitempty"`
+	Tags       []string               `xorm:"-" json:"tags,omitempty"`
+	// 媒体文件，保存在item_media表中
+	Media []ItemMedia `xorm:"-" json:"media,omitempty"`
+	// 各站点当前生效的促销价（site -> 促销价），保存在price_schedule表中
+	SalePrices map[string]SalePrice `xorm:"-" json:"-"`
+	// 各站点的发布状态（site -> 状态），没有记录的站点为草稿，保存在item_status表中
+	Statuses map[string]string `xorm:"-" json:"-"`
+	// SKU，保存在item_sku表中
+	SKUs []ItemSKU `xorm:"-" json:"skus,omitempty"`
 }
 
 // Redis缓存的结构体
 type ItemCache struct {
-	ItemID int64   `json:"item_id"`
-	Name   string  `json:"name"`
-	Price  float64 `json:"price"`
+	ItemID     int64                  `json:"item_id"`
+	Name       string                 `json:"name"`
+	Price      float64                `json:"price"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	Tags       []string               `json:"tags,omitempty"`
+	Media      []ItemMedia            `json:"media,omitempty"`
+	SalePrices map[string]SalePrice   `json:"sale_prices,omitempty"`
+	Statuses   map[string]string      `json:"statuses,omitempty"`
+	SKUs       []ItemSKU              `json:"skus,omitempty"`
+}
+
+// 根据商品生成缓存数据
+func NewItemCache(item Item) ItemCache {
+	return ItemCache{
+		ItemID:     item.ItemID,
+		Name:       item.Name,
+		Price:      item.Price,
+		Attributes: item.Attributes,
+		Tags:       item.Tags,
+		Media:      item.Media,
+		SalePrices: item.SalePrices,
+		Statuses:   item.Statuses,
+		SKUs:       item.SKUs,
+	}
+}
+
+// 商品在站点的发布状态，没有记录时为草稿
+func (itemCache ItemCache) StatusOf(site string) string {
+	if status, ok := itemCache.Statuses[site]; ok {
+		return status
+	}
+	return StatusDraft
+}
+
+// 商品在站点的实际售价：有生效中的促销价时为促销价，否则为原价
+// 促销结束后缓存会被后台任务删除，这里再按结束时间检查一次，避免任务延迟时返回过期的促销价
+func (itemCache ItemCache) EffectivePrice(site string, now time.Time) (float64, *SalePrice) {
+	if sale, ok := itemCache.SalePrices[site]; ok && now.Before(sale.EndAt) {
+		return sale.Price, &sale
+	}
+	return itemCache.Price, nil
+}
+
+// 响应中的商品信息，没有属性、标签、媒体文件和SKU时不返回这些字段
+func (itemCache ItemCache) StoreInfo() map[string]interface{} {
+	storeInfo := map[string]interface{}{
+		"item_id": itemCache.ItemID,
+		"name":    itemCache.Name,
+		"price":   itemCache.Price,
+	}
+	if len(itemCache.Attributes) > 0 {
+		storeInfo["attributes"] = itemCache.Attributes
+	}
+	if len(itemCache.Tags) > 0 {
+		storeInfo["tags"] = itemCache.Tags
+	}
+	if len(itemCache.Media) > 0 {
+		storeInfo["media"] = itemCache.Media
+	}
+	if len(itemCache.SKUs) > 0 {
+		storeInfo["skus"] = itemCache.SKUs
+	}
+	return storeInfo
 }
 
 // 响应数据结构体
@@ -26,12 +104,73 @@ type ResponseData struct {
 	Code int         `json:"code"`
 	Msg  string      `json:"msg"`
 	Data interface{} `json:"data"`
+	// 细粒度错误码，成功时不返回
+	ErrorCode string `json:"error_code,omitempty"`
+	// 请求ID，与响应头X-Request-ID相同
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// RFC 7807 problem details 响应结构体（application/problem+json）
+type ProblemDetails struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// 扩展字段：细粒度错误码
+	Code string `json:"code"`
+	// 扩展字段：字段级别的校验错误
+	Errors []FieldErrorData `json:"errors,omitempty"`
+	// 扩展字段：请求ID，与响应头X-Request-ID相同
+	RequestID string `json:"request_id,omitempty"`
+	// 其他扩展字段，序列化时与标准字段平铺在同一层
+	Extensions map[string]interface{} `json:"-"`
+}
+
+// 序列化时将扩展字段平铺到顶层
+func (p ProblemDetails) MarshalJSON() ([]byte, error) {
+	// 定义别名类型，避免递归调用MarshalJSON
+	type problem ProblemDetails
+	data, err := json.Marshal(problem(p))
+	if err != nil || len(p.Extensions) == 0 {
+		return data, err
+	}
+	fields := make(map[string]interface{}, len(p.Extensions))
+	for key, value := range p.Extensions {
+		fields[key] = value
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return json.Marshal(fields)
+}
+
+// 字段校验错误的响应结构体
+type FieldErrorData struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
 }
 
 // 增加和更新商品信息时请求的结构体
 type RequestData struct {
 	Name  string  `json:"name"`
 	Price float64 `json:"price"`
+	// 不传时不修改已有的属性和标签，传空对象或空数组表示清空
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	Tags       []string               `json:"tags,omitempty"`
+}
+
+// 部分更新商品时请求的结构体（JSON Merge Patch），nil表示不修改该字段
+type ItemPatch struct {
+	Name  *string
+	Price *float64
+	// 合并到已有的属性，值为nil的属性被删除；nil表示不修改属性
+	Attributes map[string]interface{}
+	// attributes为null时先删除全部属性
+	ClearAttributes bool
+	// 替换全部标签（数组整体替换），nil表示不修改标签
+	Tags []string
 }
 
 // 删除商品时保存item_id和删除时间
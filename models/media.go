@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// 商品的媒体文件（图片等附件），和MySQL表同步的结构体
+// 文件内容保存在BlobStore中，表中只保存文件的key
+type ItemMedia struct {
+	MediaID      string    `xorm:"varchar(36) pk 'media_id'" json:"media_id"`
+	ItemID       int64     `xorm:"'item_id' index" json:"item_id"`
+	ContentType  string    `xorm:"varchar(64) 'content_type'" json:"content_type"`
+	Size         int64     `xorm:"'size'" json:"size"`
+	Width        int       `xorm:"'width'" json:"width"`
+	Height       int       `xorm:"'height'" json:"height"`
+	BlobKey      string    `xorm:"varchar(255) 'blob_key'" json:"-"`
+	ThumbnailKey string    `xorm:"varchar(255) 'thumbnail_key'" json:"-"`
+	CreatedAt    time.Time `xorm:"created" json:"created_at"`
+	// 文件和缩略图的访问地址，查询时根据key生成
+	URL          string `xorm:"-" json:"url"`
+	ThumbnailURL string `xorm:"-" json:"thumbnail_url,omitempty"`
+}
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// 定时价格的状态
+const (
+	PriceScheduled = "scheduled"
+	PriceActive    = "active"
+	PriceEnded     = "ended"
+	PriceCancelled = "cancelled"
+)
+
+// 商品在站点的定时价格（促销价），和MySQL表同步的结构体
+// 在[start_at, end_at)期间商品在该站点的实际售价为price，状态由后台任务按时间更新
+type PriceSchedule struct {
+	ScheduleID int64     `xorm:"'schedule_id' pk autoincr" json:"schedule_id"`
+	ItemID     int64     `xorm:"'item_id' index" json:"item_id"`
+	Site       string    `xorm:"varchar(8) 'site'" json:"site"`
+	Price      float64   `xorm:"decimal(10,2)" json:"price"`
+	StartAt    time.Time `xorm:"index 'start_at'" json:"start_at"`
+	EndAt      time.Time `xorm:"index 'end_at'" json:"end_at"`
+	Status     string    `xorm:"varchar(16) index 'status'" json:"status"`
+	CreatedAt  time.Time `xorm:"created" json:"created_at"`
+	UpdatedAt  time.Time `xorm:"updated" json:"updated_at"`
+}
+
+// 增加定时价格时请求的json，时间为站点的当地时间
+type PriceScheduleRequest struct {
+	Price   float64 `json:"price"`
+	StartAt string  `json:"start_at"`
+	EndAt   string  `json:"end_at"`
+}
+
+// 商品在站点当前生效的促销价，保存在商品缓存中
+type SalePrice struct {
+	ScheduleID int64     `json:"schedule_id"`
+	Price      float64   `json:"price"`
+	EndAt      time.Time `json:"end_at"`
+}
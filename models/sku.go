@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// 商品的SKU（规格，例如不同尺码和颜色），和MySQL表同步的结构体
+// 每个SKU有自己的编码、条码、价格和规格属性，随商品一起缓存
+// SKU没有单独的库存，库存和预留仍按商品和站点管理（见ItemStock）
+type ItemSKU struct {
+	SKUID  int64 `xorm:"'sku_id' pk autoincr" json:"sku_id"`
+	ItemID int64 `xorm:"'item_id' index" json:"item_id"`
+	// SKU编码，全局唯一
+	Code string `xorm:"varchar(64) unique 'sku_code'" json:"code"`
+	// 商品条码（例如EAN-13），可以为空
+	Barcode string  `xorm:"varchar(14) 'barcode'" json:"barcode,omitempty"`
+	Price   float64 `xorm:"decimal(10,2)" json:"price"`
+	// 规格属性，属性值只能是字符串、数字或布尔值
+	Attributes map[string]interface{} `xorm:"json text 'attributes'" json:"attributes,omitempty"`
+	CreatedAt  time.Time              `xorm:"created" json:"created_at"`
+	UpdatedAt  time.Time              `xorm:"updated" json:"updated_at"`
+}
+
+// 增加和修改SKU时请求的json
+type SKURequest struct {
+	Code       string                 `json:"code"`
+	Barcode    string                 `json:"barcode"`
+	Price      float64                `json:"price"`
+	Attributes map[string]interface{} `json:"attributes"`
+}
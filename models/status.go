@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// 商品在站点的发布状态
+const (
+	StatusDraft     = "draft"
+	StatusPublished = "published"
+	StatusArchived  = "archived"
+)
+
+// 允许的状态转换：当前状态 -> 可以转换到的状态
+var StatusTransitions = map[string][]string{
+	StatusDraft:     {StatusPublished, StatusArchived},
+	StatusPublished: {StatusDraft, StatusArchived},
+	StatusArchived:  {StatusDraft},
+}
+
+// 商品在各站点的发布状态，和MySQL表同步的结构体
+// 没有记录的站点视为草稿，只有已发布的商品对普通调用方可见
+type ItemStatus struct {
+	ItemID    int64     `xorm:"'item_id' pk" json:"item_id"`
+	Site      string    `xorm:"varchar(8) pk 'site'" json:"site"`
+	Status    string    `xorm:"varchar(16) index 'status'" json:"status"`
+	UpdatedAt time.Time `xorm:"updated" json:"updated_at"`
+}
+
+// 修改商品状态时请求的json
+type StatusRequest struct {
+	Status string `json:"status"`
+}
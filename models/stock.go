@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// 库存预留的状态
+const (
+	ReservationPending   = "pending"
+	ReservationConfirmed = "confirmed"
+	ReservationReleased  = "released"
+	ReservationExpired   = "expired"
+)
+
+// 商品在各站点的库存，和MySQL表同步的结构体
+// available为可售数量，reserved为已预留但未确认的数量
+type ItemStock struct {
+	ItemID    int64     `xorm:"'item_id' pk" json:"item_id"`
+	Site      string    `xorm:"varchar(8) pk 'site'" json:"site"`
+	Available int64     `xorm:"'available' notnull default 0" json:"available"`
+	Reserved  int64     `xorm:"'reserved' notnull default 0" json:"reserved"`
+	UpdatedAt time.Time `xorm:"updated" json:"updated_at"`
+}
+
+// 库存预留记录，和MySQL表同步的结构体
+// 预留后在过期时间之前确认（扣减库存）或释放（归还库存），过期未处理的预留会被自动释放
+type StockReservation struct {
+	ReservationID string    `xorm:"varchar(36) pk 'reservation_id'" json:"reservation_id"`
+	ItemID        int64     `xorm:"'item_id' index" json:"item_id"`
+	Site          string    `xorm:"varchar(8) 'site'" json:"site"`
+	Quantity      int64     `xorm:"'quantity'" json:"quantity"`
+	Status        string    `xorm:"varchar(16) index 'status'" json:"status"`
+	ExpiresAt     time.Time `xorm:"index 'expires_at'" json:"expires_at"`
+	CreatedAt     time.Time `xorm:"created" json:"created_at"`
+	UpdatedAt     time.Time `xorm:"updated" json:"updated_at"`
+}
+
+// 设置库存时请求的json
+type StockRequest struct {
+	Quantity int64 `json:"quantity"`
+}
+
+// 预留库存时请求的json，ttl_sec为0时使用配置的默认过期时间
+type ReservationRequest struct {
+	Quantity int64 `json:"quantity"`
+	TTLSec   int   `json:"ttl_sec"`
+}
@@ -0,0 +1,16 @@
+package models
+
+// 导入商品时每一行的数据，item_id为0表示新增商品
+type ImportItem struct {
+	ItemID int64   `json:"item_id"`
+	Name   string  `json:"name"`
+	Price  float64 `json:"price"`
+}
+
+// 导入商品时某一行的错误
+type ImportLineError struct {
+	Line      int              `json:"line"`
+	ErrorCode string           `json:"error_code"`
+	Message   string           `json:"message"`
+	Errors    []FieldErrorData `json:"errors,omitempty"`
+}
@@ -0,0 +1,82 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"miHttpServer/caches"
+	"miHttpServer/config"
+	"miHttpServer/database"
+	"time"
+)
+
+// 取消定时价格的事务提交可能晚于写入的updated_at，各实例的时钟也可能有偏差
+// 检查被取消的定时价格时向前多查一段时间，重复删除缓存没有影响
+const cancelLookback = 30 * time.Second
+
+// 启动后台任务，定期更新定时价格的状态，并在价格开始、结束或被取消时删除商品的缓存
+// 返回停止任务的函数，等待正在进行的处理完成后返回
+// 每个服务实例都会运行，保证各实例的本地缓存都会被删除
+func StartPriceScheduler(interval time.Duration) func() {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	// 启动前开始或结束的定时价格，在Redis缓存的有效期内仍可能留有旧的缓存，启动时一起处理
+	last := time.Now().Add(-time.Duration(config.Configs.Redis.Expire) * time.Second)
+	last = applyPriceSchedules(last, time.Now())
+
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-ticker.C:
+				last = applyPriceSchedules(last, time.Now())
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// 处理在(from, to]期间开始、结束或被取消的定时价格，返回下次检查的起始时间
+// 删除缓存失败时返回from，下次重新处理
+func applyPriceSchedules(from, to time.Time) time.Time {
+	// 后台任务不属于任何请求，SQL语句不记录跨度
+	ctx := context.Background()
+	activated, ended, err := database.UpdatePriceScheduleStatuses(ctx, to)
+	if err != nil {
+		log.Println("更新定时价格的状态失败:", err)
+	} else if activated > 0 || ended > 0 {
+		log.Printf("定时价格开始生效%d个，结束%d个", activated, ended)
+	}
+
+	itemIDs, err := database.QueryPriceBoundaryItemIDs(ctx, from, to)
+	if err != nil {
+		log.Println("查询开始或结束的定时价格失败:", err)
+		return from
+	}
+	cancelledIDs, err := database.QueryCancelledPriceItemIDs(ctx, from.Add(-cancelLookback), to)
+	if err != nil {
+		log.Println("查询被取消的定时价格失败:", err)
+		return from
+	}
+	itemIDs = append(itemIDs, cancelledIDs...)
+	if len(itemIDs) == 0 {
+		return to
+	}
+	for _, itemID := range itemIDs {
+		caches.DeleteLocalCache(itemID)
+	}
+	if err := caches.DeleteRedisCaches(ctx, itemIDs); err != nil {
+		log.Println("删除定时价格对应商品的Redis缓存失败:", err)
+		return from
+	}
+	return to
+}
@@ -0,0 +1,227 @@
+package search
+
+import (
+	"html"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// BM25排序的参数
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// 索引中的文档（商品）
+type document struct {
+	name  string
+	price float64
+	// 文档的词数（用于BM25的长度归一化）
+	length int
+}
+
+// 搜索结果
+type Result struct {
+	ItemID int64   `json:"item_id"`
+	Name   string  `json:"name"`
+	Price  float64 `json:"price"`
+	Score  float64 `json:"score"`
+	// 命中的词用高亮标签包裹，其余部分做HTML转义
+	Highlight string `json:"highlight"`
+}
+
+// 内存中的倒排索引，并发安全
+type Index struct {
+	mutex sync.RWMutex
+	// 词 -> item_id -> 词频
+	postings map[string]map[int64]int
+	// item_id -> 文档
+	documents map[int64]document
+	// 所有文档的总词数，用于计算平均长度
+	totalLength int
+	// item_id -> 商品已发布的站点，修改商品名称和价格时保持不变
+	publishedSites map[int64][]string
+}
+
+// 创建空的倒排索引
+func NewIndex() *Index {
+	return &Index{
+		postings:       make(map[string]map[int64]int),
+		documents:      make(map[int64]document),
+		publishedSites: make(map[int64][]string),
+	}
+}
+
+// 添加或替换商品的索引
+func (index *Index) Add(itemID int64, name string, price float64) {
+	tokens := Tokenize(name)
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+	index.remove(itemID)
+	for _, token := range tokens {
+		posting, ok := index.postings[token.Term]
+		if !ok {
+			posting = make(map[int64]int)
+			index.postings[token.Term] = posting
+		}
+		posting[itemID]++
+	}
+	index.documents[itemID] = document{name: name, price: price, length: len(tokens)}
+	index.totalLength += len(tokens)
+}
+
+// 删除商品的索引
+func (index *Index) Remove(itemID int64) {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+	index.remove(itemID)
+	delete(index.publishedSites, itemID)
+}
+
+// 设置商品已发布的站点
+func (index *Index) SetPublishedSites(itemID int64, sites []string) {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+	if len(sites) == 0 {
+		delete(index.publishedSites, itemID)
+		return
+	}
+	index.publishedSites[itemID] = sites
+}
+
+func (index *Index) remove(itemID int64) {
+	doc, ok := index.documents[itemID]
+	if !ok {
+		return
+	}
+	for _, token := range Tokenize(doc.name) {
+		if posting, ok := index.postings[token.Term]; ok {
+			delete(posting, itemID)
+			if len(posting) == 0 {
+				delete(index.postings, token.Term)
+			}
+		}
+	}
+	delete(index.documents, itemID)
+	index.totalLength -= doc.length
+}
+
+// 索引中的商品数量
+func (index *Index) Len() int {
+	index.mutex.RLock()
+	defer index.mutex.RUnlock()
+	return len(index.documents)
+}
+
+// 搜索商品，按BM25得分降序排列（得分相同时按item_id升序），返回分页后的结果和命中总数
+// site不为空时只返回在该站点已发布的商品
+func (index *Index) Search(query, site string, offset, limit int, highlightPre, highlightPost string) ([]Result, int) {
+	terms := uniqueTerms(TokenizeQuery(query))
+	if len(terms) == 0 {
+		return []Result{}, 0
+	}
+
+	index.mutex.RLock()
+	defer index.mutex.RUnlock()
+	n := float64(len(index.documents))
+	avgLength := 1.0
+	if n > 0 && index.totalLength > 0 {
+		avgLength = float64(index.totalLength) / n
+	}
+	scores := make(map[int64]float64)
+	for term := range terms {
+		posting := index.postings[term]
+		if len(posting) == 0 {
+			continue
+		}
+		df := float64(len(posting))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for itemID, tf := range posting {
+			if site != "" && !slices.Contains(index.publishedSites[itemID], site) {
+				continue
+			}
+			length := float64(index.documents[itemID].length)
+			freq := float64(tf)
+			scores[itemID] += idf * freq * (bm25K1 + 1) / (freq + bm25K1*(1-bm25B+bm25B*length/avgLength))
+		}
+	}
+
+	itemIDs := make([]int64, 0, len(scores))
+	for itemID := range scores {
+		itemIDs = append(itemIDs, itemID)
+	}
+	sort.Slice(itemIDs, func(i, j int) bool {
+		if scores[itemIDs[i]] != scores[itemIDs[j]] {
+			return scores[itemIDs[i]] > scores[itemIDs[j]]
+		}
+		return itemIDs[i] < itemIDs[j]
+	})
+	total := len(itemIDs)
+	if offset >= total {
+		return []Result{}, total
+	}
+	itemIDs = itemIDs[offset:min(offset+limit, total)]
+
+	results := make([]Result, 0, len(itemIDs))
+	for _, itemID := range itemIDs {
+		doc := index.documents[itemID]
+		results = append(results, Result{
+			ItemID:    itemID,
+			Name:      doc.name,
+			Price:     doc.price,
+			Score:     math.Round(scores[itemID]*10000) / 10000,
+			Highlight: highlight(doc.name, terms, highlightPre, highlightPost),
+		})
+	}
+	return results, total
+}
+
+// 查询语句中不重复的词
+func uniqueTerms(tokens []Token) map[string]struct{} {
+	terms := make(map[string]struct{}, len(tokens))
+	for _, token := range tokens {
+		terms[token.Term] = struct{}{}
+	}
+	return terms
+}
+
+// 将文本中命中查询词的部分用高亮标签包裹，相邻或重叠的部分（例如中文的二元组）合并为一段
+func highlight(text string, terms map[string]struct{}, pre, post string) string {
+	runes := []rune(text)
+	matched := make([]bool, len(runes))
+	for _, token := range Tokenize(text) {
+		if _, ok := terms[token.Term]; ok {
+			for i := token.Start; i < token.End; i++ {
+				matched[i] = true
+			}
+		}
+	}
+	var builder strings.Builder
+	for i := 0; i < len(runes); {
+		j := i
+		for j < len(runes) && matched[j] == matched[i] {
+			j++
+		}
+		segment := html.EscapeString(string(runes[i:j]))
+		if matched[i] {
+			builder.WriteString(pre + segment + post)
+		} else {
+			builder.WriteString(segment)
+		}
+		i = j
+	}
+	return builder.String()
+}
+
+// 用other的内容替换当前索引（重建索引时使用）
+func (index *Index) replace(other *Index) {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+	index.postings = other.postings
+	index.documents = other.documents
+	index.totalLength = other.totalLength
+	index.publishedSites = other.publishedSites
+}
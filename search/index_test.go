@@ -0,0 +1,121 @@
+package search
+
+import (
+	"testing"
+)
+
+// 搜索结果的item_id
+func resultIDs(results []Result) []int64 {
+	ids := make([]int64, 0, len(results))
+	for _, result := range results {
+		ids = append(ids, result.ItemID)
+	}
+	return ids
+}
+
+func newTestIndex() *Index {
+	index := NewIndex()
+	index.Add(1, "小米手环 8", 249)
+	index.Add(2, "小米手环 8 NFC 小米手环", 299)
+	index.Add(3, "Redmi Buds 5", 199)
+	index.Add(4, "Xiaomi Smart Band 8 Pro 手环", 399)
+	return index
+}
+
+func TestSearchRanking(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  []int64
+	}{
+		// 词频更高的文档得分更高，只命中部分二元组的文档排在最后
+		{name: "词频", query: "小米手环", want: []int64{2, 1, 4}},
+		// 只包含常见词的文档排在包含稀有词的文档之后
+		{name: "逆文档频率", query: "手环 pro", want: []int64{4, 2, 1}},
+		// 商品1和4的词数相同，得分相同时按item_id升序
+		{name: "得分相同", query: "8", want: []int64{1, 4, 2}},
+		{name: "没有命中", query: "耳机", want: []int64{}},
+		{name: "只有标点", query: "!!", want: []int64{}},
+	}
+	index := newTestIndex()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, total := index.Search(tt.query, "", 0, 10, "<em>", "</em>")
+			got := resultIDs(results)
+			if total != len(tt.want) || len(got) != len(tt.want) {
+				t.Fatalf("结果为%v（共%d个），应为%v", got, total, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("结果为%v，应为%v", got, tt.want)
+				}
+			}
+			for i := 1; i < len(results); i++ {
+				if results[i].Score > results[i-1].Score {
+					t.Errorf("结果没有按得分降序: %v", results)
+				}
+			}
+		})
+	}
+}
+
+func TestSearchHighlightAndPaging(t *testing.T) {
+	index := newTestIndex()
+	results, total := index.Search("小米", "", 1, 1, "<em>", "</em>")
+	if total != 2 || len(results) != 1 {
+		t.Fatalf("结果为%v（共%d个），应为1个（共2个）", results, total)
+	}
+	if want := "<em>小米</em>手环 8"; results[0].Highlight != want {
+		t.Errorf("高亮为%q，应为%q", results[0].Highlight, want)
+	}
+}
+
+func TestIndexRemove(t *testing.T) {
+	index := newTestIndex()
+	index.SetPublishedSites(3, []string{"uk"})
+	index.Remove(3)
+	index.Remove(404)
+
+	if index.Len() != 3 {
+		t.Errorf("索引中有%d个商品，应为3个", index.Len())
+	}
+	if results, total := index.Search("redmi buds", "", 0, 10, "", ""); total != 0 {
+		t.Errorf("删除后仍能搜到: %v", results)
+	}
+	for _, term := range []string{"redmi", "buds", "5"} {
+		if _, ok := index.postings[term]; ok {
+			t.Errorf("删除后倒排索引中仍有%q", term)
+		}
+	}
+	if _, ok := index.publishedSites[3]; ok {
+		t.Error("删除后仍保留已发布的站点")
+	}
+
+	// 删除其他商品后总词数与只索引剩余商品时一致
+	fresh := NewIndex()
+	fresh.Add(1, "小米手环 8", 249)
+	fresh.Add(2, "小米手环 8 NFC 小米手环", 299)
+	fresh.Add(4, "Xiaomi Smart Band 8 Pro 手环", 399)
+	if index.totalLength != fresh.totalLength {
+		t.Errorf("总词数为%d，应为%d", index.totalLength, fresh.totalLength)
+	}
+
+	// 重新添加时替换原来的索引
+	index.Add(1, "Redmi Buds", 99)
+	if _, total := index.Search("小米", "", 0, 10, "", ""); total != 1 {
+		t.Errorf("替换后搜索小米命中%d个，应为1个", total)
+	}
+	if results, _ := index.Search("buds", "", 0, 10, "", ""); len(results) != 1 || results[0].ItemID != 1 {
+		t.Errorf("替换后搜索buds的结果为%v", results)
+	}
+}
+
+func TestSearchPublishedSite(t *testing.T) {
+	index := newTestIndex()
+	index.SetPublishedSites(1, []string{"uk", "jp"})
+	index.SetPublishedSites(2, []string{"jp"})
+	results, total := index.Search("小米", "uk", 0, 10, "", "")
+	if total != 1 || results[0].ItemID != 1 {
+		t.Errorf("uk站点的结果为%v，应只有商品1", resultIDs(results))
+	}
+}
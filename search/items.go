@@ -0,0 +1,100 @@
+package search
+
+import (
+	"context"
+	"miHttpServer/database"
+	"miHttpServer/models"
+	"sync"
+)
+
+// 商品名称的倒排索引
+var Items = NewIndex()
+
+// 重建索引时每次从MySQL读取的商品数量
+const rebuildChunkSize = 1000
+
+var (
+	// 同一时间只允许一个重建任务
+	rebuildMutex sync.Mutex
+	// 保护pending
+	pendingMutex sync.Mutex
+	// 重建期间对索引的修改，重建完成后在新索引上重放，避免丢失重建期间的修改
+	pending []func(*Index)
+)
+
+// 增加或修改商品后更新索引
+func IndexItem(item models.Item) {
+	apply(func(index *Index) { index.Add(item.ItemID, item.Name, item.Price) })
+}
+
+// 删除商品后删除索引
+func RemoveItem(itemID int64) {
+	apply(func(index *Index) { index.Remove(itemID) })
+}
+
+// 商品的发布状态修改后更新已发布的站点
+func SetPublishedSites(itemID int64, sites []string) {
+	apply(func(index *Index) { index.SetPublishedSites(itemID, sites) })
+}
+
+func apply(op func(*Index)) {
+	pendingMutex.Lock()
+	defer pendingMutex.Unlock()
+	op(Items)
+	if pending != nil {
+		pending = append(pending, op)
+	}
+}
+
+// 从MySQL重建索引，返回索引的商品数量
+// 重建期间索引仍然可以查询和修改，完成后一次性替换
+func Rebuild(ctx context.Context) (int, error) {
+	rebuildMutex.Lock()
+	defer rebuildMutex.Unlock()
+
+	pendingMutex.Lock()
+	pending = []func(*Index){}
+	pendingMutex.Unlock()
+
+	// 重建失败时不再记录修改，保留原来的索引
+	fail := func(err error) (int, error) {
+		pendingMutex.Lock()
+		pending = nil
+		pendingMutex.Unlock()
+		return 0, err
+	}
+
+	fresh := NewIndex()
+	var lastID int64
+	for {
+		items, err := database.QueryItemsAfter(ctx, lastID, rebuildChunkSize, "")
+		if err != nil {
+			return fail(err)
+		}
+		itemIDs := make([]int64, 0, len(items))
+		for _, item := range items {
+			fresh.Add(item.ItemID, item.Name, item.Price)
+			itemIDs = append(itemIDs, item.ItemID)
+		}
+		publishedSites, err := database.QueryPublishedSites(ctx, itemIDs)
+		if err != nil {
+			return fail(err)
+		}
+		for itemID, sites := range publishedSites {
+			fresh.SetPublishedSites(itemID, sites)
+		}
+		if len(items) < rebuildChunkSize {
+			break
+		}
+		lastID = items[len(items)-1].ItemID
+	}
+
+	pendingMutex.Lock()
+	defer pendingMutex.Unlock()
+	for _, op := range pending {
+		op(fresh)
+	}
+	pending = nil
+	Items.replace(fresh)
+	return fresh.Len(), nil
+}
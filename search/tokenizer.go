@@ -0,0 +1,88 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// 分词结果，Start和End为词在原文中的字符（rune）位置，用于高亮
+type Token struct {
+	Term  string
+	Start int
+	End   int
+}
+
+// 对文本分词，用于建立索引
+// 拉丁字母、西里尔字母和数字按单词切分；中文和日文没有空格分隔，连续的汉字/假名生成单字和二元组（bigram）
+func Tokenize(text string) []Token {
+	return tokenize(text, true)
+}
+
+// 对查询语句分词：中日文只有一个字时使用单字，否则只使用二元组，保证查询更精确
+func TokenizeQuery(text string) []Token {
+	return tokenize(text, false)
+}
+
+func tokenize(text string, withUnigrams bool) []Token {
+	runes := []rune(text)
+	var tokens []Token
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case isCJK(r):
+			j := i
+			for j < len(runes) && isCJK(runes[j]) {
+				j++
+			}
+			tokens = append(tokens, cjkTokens(runes[i:j], i, withUnigrams)...)
+			i = j
+		case isWordRune(r):
+			j := i
+			for j < len(runes) && isWordRune(runes[j]) && !isCJK(runes[j]) {
+				j++
+			}
+			tokens = append(tokens, Token{Term: normalize(runes[i:j]), Start: i, End: j})
+			i = j
+		default:
+			i++
+		}
+	}
+	return tokens
+}
+
+// 连续的中日文字符生成二元组，只有一个字时生成单字
+func cjkTokens(run []rune, offset int, withUnigrams bool) []Token {
+	var tokens []Token
+	if len(run) == 1 || withUnigrams {
+		for i, r := range run {
+			tokens = append(tokens, Token{Term: string(r), Start: offset + i, End: offset + i + 1})
+		}
+	}
+	for i := 0; i+1 < len(run); i++ {
+		tokens = append(tokens, Token{Term: string(run[i : i+2]), Start: offset + i, End: offset + i + 2})
+	}
+	return tokens
+}
+
+// 统一为小写，西里尔字母的ё统一为е（俄语中经常省略ё的两点）
+func normalize(word []rune) string {
+	var builder strings.Builder
+	for _, r := range word {
+		r = unicode.ToLower(r)
+		if r == 'ё' {
+			r = 'е'
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
+
+// 是否为中文汉字、日文假名（包括长音符号）
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana) || r == 'ー'
+}
+
+// 是否为组成单词的字符
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,93 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+// 只比较分词结果中的词
+func terms(tokens []Token) []string {
+	result := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		result = append(result, token.Term)
+	}
+	return result
+}
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		index []string
+		query []string
+	}{
+		{
+			name:  "英文单词转为小写",
+			text:  "Mi Band 8",
+			index: []string{"mi", "band", "8"},
+			query: []string{"mi", "band", "8"},
+		},
+		{
+			name:  "中文和英文混合",
+			text:  "小米Band手环",
+			index: []string{"小", "米", "小米", "band", "手", "环", "手环"},
+			query: []string{"小米", "band", "手环"},
+		},
+		{
+			name:  "标点和空白只作为分隔",
+			text:  "Redmi-Note, 13（Pro）!",
+			index: []string{"redmi", "note", "13", "pro"},
+			query: []string{"redmi", "note", "13", "pro"},
+		},
+		{
+			name:  "单个汉字查询时使用单字",
+			text:  "米",
+			index: []string{"米"},
+			query: []string{"米"},
+		},
+		{
+			name:  "日文假名和长音符号",
+			text:  "スマートウォッチ",
+			index: []string{"ス", "マ", "ー", "ト", "ウ", "ォ", "ッ", "チ", "スマ", "マー", "ート", "トウ", "ウォ", "ォッ", "ッチ"},
+			query: []string{"スマ", "マー", "ート", "トウ", "ウォ", "ォッ", "ッチ"},
+		},
+		{
+			name:  "西里尔字母的ё统一为е",
+			text:  "Чёрный",
+			index: []string{"черный"},
+			query: []string{"черный"},
+		},
+		{
+			name:  "只有标点",
+			text:  "-- !!",
+			index: []string{},
+			query: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := terms(Tokenize(tt.text)); !reflect.DeepEqual(got, tt.index) {
+				t.Errorf("Tokenize(%q) = %q，应为%q", tt.text, got, tt.index)
+			}
+			if got := terms(TokenizeQuery(tt.text)); !reflect.DeepEqual(got, tt.query) {
+				t.Errorf("TokenizeQuery(%q) = %q，应为%q", tt.text, got, tt.query)
+			}
+		})
+	}
+}
+
+func TestTokenizePositions(t *testing.T) {
+	// 位置按字符计算，用于高亮
+	tokens := Tokenize("新款 小米")
+	want := []Token{
+		{Term: "新", Start: 0, End: 1},
+		{Term: "款", Start: 1, End: 2},
+		{Term: "新款", Start: 0, End: 2},
+		{Term: "小", Start: 3, End: 4},
+		{Term: "米", Start: 4, End: 5},
+		{Term: "小米", Start: 3, End: 5},
+	}
+	if !reflect.DeepEqual(tokens, want) {
+		t.Errorf("分词结果为%v，应为%v", tokens, want)
+	}
+}
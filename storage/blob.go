@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"path"
+	"strings"
+)
+
+// 保存媒体文件的存储接口，key为“/”分隔的相对路径（例如items/1/xxx.jpg）
+// 目前只有本地文件系统的实现，以后可以增加对象存储等实现
+type BlobStore interface {
+	// 保存文件，key已存在时覆盖
+	Put(key string, content io.Reader) error
+	// 读取文件，文件不存在时返回ErrBlobNotFound
+	Open(key string) (io.ReadSeekCloser, error)
+	// 删除文件，文件不存在时不返回错误
+	Delete(key string) error
+	// 删除以prefix为前缀的所有文件，prefix必须以“/”结尾
+	DeletePrefix(prefix string) error
+	// 文件的访问地址
+	URL(key string) string
+}
+
+// 全局的媒体文件存储
+var Blobs BlobStore
+
+// 文件不存在
+var ErrBlobNotFound = errors.New("blob not found")
+
+// key非法（为空、绝对路径或包含“..”）
+var ErrInvalidKey = errors.New("invalid blob key")
+
+// 检查key是否为合法的相对路径
+func validKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return false
+	}
+	key = strings.TrimSuffix(key, "/")
+	if key == "" || key == "." || key == ".." || strings.HasPrefix(key, "../") {
+		return false
+	}
+	return path.Clean(key) == key
+}
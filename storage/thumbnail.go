@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+
+	// 注册gif解码器
+	_ "image/gif"
+)
+
+// 解码图片允许的最大像素数，防止很小的文件解码后占用大量内存
+const maxImagePixels = 40 * 1000 * 1000
+
+// 图片的像素数超过限制
+var ErrImageTooLarge = errors.New("image dimensions too large")
+
+// 生成的缩略图
+type Thumbnail struct {
+	Data        []byte
+	ContentType string
+	// 缩略图对应的文件扩展名
+	Ext string
+	// 原图的宽高
+	Width  int
+	Height int
+}
+
+// 生成缩略图：等比缩放到宽高都不超过maxSize（原图更小时不放大）
+// jpeg原图生成jpeg缩略图，其他格式生成png缩略图以保留透明度
+func MakeThumbnail(data []byte, maxSize int) (*Thumbnail, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if config.Width*config.Height > maxImagePixels {
+		return nil, ErrImageTooLarge
+	}
+	src, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	dst := resize(src, width, height, maxSize)
+
+	var buf bytes.Buffer
+	thumbnail := &Thumbnail{Width: width, Height: height}
+	if format == "jpeg" {
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85})
+		thumbnail.ContentType, thumbnail.Ext = "image/jpeg", ".jpg"
+	} else {
+		err = png.Encode(&buf, dst)
+		thumbnail.ContentType, thumbnail.Ext = "image/png", ".png"
+	}
+	if err != nil {
+		return nil, err
+	}
+	thumbnail.Data = buf.Bytes()
+	return thumbnail, nil
+}
+
+// 按区域平均的方式缩小图片，目标图片的每个像素取原图对应区域所有像素的平均值
+func resize(src image.Image, width, height, maxSize int) image.Image {
+	dstWidth, dstHeight := width, height
+	if maxSize > 0 && (width > maxSize || height > maxSize) {
+		if width >= height {
+			dstWidth, dstHeight = maxSize, max(1, height*maxSize/width)
+		} else {
+			dstWidth, dstHeight = max(1, width*maxSize/height), maxSize
+		}
+	}
+	bounds := src.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+	for y := 0; y < dstHeight; y++ {
+		y0 := bounds.Min.Y + y*height/dstHeight
+		y1 := max(y0+1, bounds.Min.Y+(y+1)*height/dstHeight)
+		for x := 0; x < dstWidth; x++ {
+			x0 := bounds.Min.X + x*width/dstWidth
+			x1 := max(x0+1, bounds.Min.X+(x+1)*width/dstWidth)
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					// RGBA返回预乘透明度的16位颜色
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(b / n),
+				A: uint16(a / n),
+			})
+		}
+	}
+	return dst
+}
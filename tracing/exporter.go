@@ -0,0 +1,223 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// 导出方式
+const (
+	ExporterNone   = "none"   // 不记录追踪
+	ExporterStdout = "stdout" // 输出到标准输出
+	ExporterFile   = "file"   // 写入文件
+)
+
+// 当前的TracerProvider，未开启追踪时为nil
+var current atomic.Pointer[sdktrace.TracerProvider]
+
+// 按配置设置全局的TracerProvider和W3C Trace Context传播方式，kind为none时不记录追踪，为file时追加写入path
+func Init(kind, path, serviceName string) error {
+	var exporter *otlpJSONExporter
+	switch kind {
+	case "", ExporterNone:
+	case ExporterStdout:
+		exporter = &otlpJSONExporter{w: os.Stdout}
+	case ExporterFile:
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		exporter = &otlpJSONExporter{w: file, closer: file}
+	default:
+		return fmt.Errorf("不支持的追踪导出方式：%s", kind)
+	}
+	Shutdown()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	if exporter == nil {
+		return nil
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+	current.Store(provider)
+	return nil
+}
+
+// 停止记录追踪，导出剩余的跨度并关闭导出的文件
+func Shutdown() {
+	provider := current.Swap(nil)
+	if provider == nil {
+		return
+	}
+	otel.SetTracerProvider(noop.NewTracerProvider())
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := provider.Shutdown(ctx); err != nil {
+		log.Printf("关闭追踪失败: %s", err)
+	}
+}
+
+// 按OTLP/JSON格式导出跨度，每批跨度写一行ExportTraceServiceRequest，可以直接被OpenTelemetry Collector的otlpjsonfile接收器读取
+// OpenTelemetry的otlptrace导出器只能发送到Collector（gRPC或HTTP），离线写入文件需要自己按OTLP/JSON编码
+type otlpJSONExporter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer
+}
+
+func (e *otlpJSONExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if len(spans) == 0 {
+		return nil
+	}
+	line, err := json.Marshal(otlpRequestOf(spans))
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, err = e.w.Write(append(line, '\n'))
+	return err
+}
+
+func (e *otlpJSONExporter) Shutdown(ctx context.Context) error {
+	if e.closer == nil {
+		return nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.closer.Close()
+}
+
+// OTLP/JSON的结构，只包含用到的字段
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string          `json:"traceId"`
+		SpanID            string          `json:"spanId"`
+		ParentSpanID      string          `json:"parentSpanId,omitempty"`
+		Name              string          `json:"name"`
+		Kind              int             `json:"kind"`
+		StartTimeUnixNano string          `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+		Attributes        []otlpAttribute `json:"attributes,omitempty"`
+		Events            []otlpEvent     `json:"events,omitempty"`
+		Status            otlpStatus      `json:"status"`
+	}
+	otlpEvent struct {
+		Name         string          `json:"name"`
+		TimeUnixNano string          `json:"timeUnixNano"`
+		Attributes   []otlpAttribute `json:"attributes,omitempty"`
+	}
+	otlpAttribute struct {
+		Key   string                 `json:"key"`
+		Value map[string]interface{} `json:"value"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code,omitempty"` // 0未设置，1成功，2错误
+		Message string `json:"message,omitempty"`
+	}
+)
+
+// 同一批跨度来自同一个TracerProvider，资源相同，按instrumentation scope分组
+func otlpRequestOf(spans []sdktrace.ReadOnlySpan) otlpRequest {
+	var scopes []otlpScopeSpans
+	index := make(map[string]int)
+	for _, span := range spans {
+		name := span.InstrumentationScope().Name
+		i, ok := index[name]
+		if !ok {
+			i = len(scopes)
+			index[name] = i
+			scopes = append(scopes, otlpScopeSpans{Scope: otlpScope{Name: name}})
+		}
+		scopes[i].Spans = append(scopes[i].Spans, otlpSpanOf(span))
+	}
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: otlpAttrs(spans[0].Resource().Attributes())},
+		ScopeSpans: scopes,
+	}}}
+}
+
+// SpanKind的取值与OTLP相同，状态码不同需要转换
+func otlpSpanOf(span sdktrace.ReadOnlySpan) otlpSpan {
+	s := otlpSpan{
+		TraceID:           span.SpanContext().TraceID().String(),
+		SpanID:            span.SpanContext().SpanID().String(),
+		Name:              span.Name(),
+		Kind:              int(span.SpanKind()),
+		StartTimeUnixNano: strconv.FormatInt(span.StartTime().UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(span.EndTime().UnixNano(), 10),
+		Attributes:        otlpAttrs(span.Attributes()),
+	}
+	if span.Parent().SpanID().IsValid() {
+		s.ParentSpanID = span.Parent().SpanID().String()
+	}
+	for _, event := range span.Events() {
+		s.Events = append(s.Events, otlpEvent{
+			Name:         event.Name,
+			TimeUnixNano: strconv.FormatInt(event.Time.UnixNano(), 10),
+			Attributes:   otlpAttrs(event.Attributes),
+		})
+	}
+	switch span.Status().Code {
+	case codes.Ok:
+		s.Status = otlpStatus{Code: 1}
+	case codes.Error:
+		s.Status = otlpStatus{Code: 2, Message: span.Status().Description}
+	}
+	return s
+}
+
+// OTLP/JSON中整数按字符串输出，数组等其他类型按字符串输出
+func otlpAttrs(attrs []attribute.KeyValue) []otlpAttribute {
+	result := make([]otlpAttribute, 0, len(attrs))
+	for _, attr := range attrs {
+		var v map[string]interface{}
+		switch attr.Value.Type() {
+		case attribute.BOOL:
+			v = map[string]interface{}{"boolValue": attr.Value.AsBool()}
+		case attribute.INT64:
+			v = map[string]interface{}{"intValue": strconv.FormatInt(attr.Value.AsInt64(), 10)}
+		case attribute.FLOAT64:
+			v = map[string]interface{}{"doubleValue": attr.Value.AsFloat64()}
+		default:
+			v = map[string]interface{}{"stringValue": attr.Value.Emit()}
+		}
+		result = append(result, otlpAttribute{Key: string(attr.Key), Value: v})
+	}
+	return result
+}
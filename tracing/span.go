@@ -0,0 +1,51 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// 跨度所属的instrumentation scope
+const instrumentationName = "miHttpServer"
+
+// 不记录的跨度，所有方法都是空操作
+var noopSpan = trace.SpanFromContext(context.Background())
+
+// 从全局TracerProvider获取，未开启追踪时为空实现，开始的跨度不记录也不导出
+func tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// 开始一个内部跨度（缓存、分布式锁等），context中有跨度时作为其子跨度，否则开始新的追踪
+func Start(ctx context.Context, name string) (context.Context, trace.Span) {
+	return tracer().Start(ctx, name, trace.WithSpanKind(trace.SpanKindInternal))
+}
+
+// 开始一个客户端跨度（Redis命令、SQL语句），只在context中有正在记录的跨度时记录
+// 后台任务和启动时执行的命令不属于任何请求，不记录，避免产生大量只有一个跨度的追踪
+func StartClient(ctx context.Context, name string) (context.Context, trace.Span) {
+	if !trace.SpanFromContext(ctx).IsRecording() {
+		return ctx, noopSpan
+	}
+	return tracer().Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+}
+
+// 开始处理HTTP请求的服务端跨度，请求头中的traceparent有效时延续调用方的追踪
+func StartServer(ctx context.Context, name string, header http.Header) (context.Context, trace.Span) {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(header))
+	return tracer().Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer))
+}
+
+// 记录错误并把跨度的状态设置为错误，err为nil时不处理
+func SetError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -1,24 +1,169 @@
 package utils
 
 import (
+	"errors"
+	"miHttpServer/apperror"
 	"miHttpServer/config"
+	"miHttpServer/i18n"
+	"miHttpServer/logger"
 	"miHttpServer/models"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
 )
 
 // 处理请求错误
-func DealRequestError(msg string, err error) models.ResponseData {
+func DealRequestError(ctx *gin.Context, msgID string, err error) models.ResponseData {
 	var response models.ResponseData
+	lang := i18n.FromContext(ctx)
 	response.Code = config.Configs.Code.RequestError
-	response.Msg = msg
-	response.Data = err.Error()
+	response.Msg = i18n.Translate(lang, msgID)
+	response.Data = localizeError(lang, err)
+	response.RequestID = requestID(ctx)
 	return response
 }
 
 // 处理服务器内部处理错误
-func DealServerError(msg string, err error) models.ResponseData {
+func DealServerError(ctx *gin.Context, msgID string, err error) models.ResponseData {
 	var response models.ResponseData
+	lang := i18n.FromContext(ctx)
 	response.Code = config.Configs.Code.ServerError
-	response.Msg = msg
-	response.Data = err.Error()
+	response.Msg = i18n.Translate(lang, msgID)
+	response.Data = localizeError(lang, err)
+	response.RequestID = requestID(ctx)
+	return response
+}
+
+// 翻译错误详情，非i18n.Error的错误直接返回原始信息
+func localizeError(lang string, err error) string {
+	var localized *i18n.Error
+	if errors.As(err, &localized) {
+		return localized.Localize(lang)
+	}
+	return err.Error()
+}
+
+// 处理应用错误，返回原有格式的响应结构体
+func DealAppError(ctx *gin.Context, appErr *apperror.Error) models.ResponseData {
+	var response models.ResponseData
+	if appErr.Kind == apperror.KindServer {
+		response = DealServerError(ctx, appErr.Code, appErr.Detail)
+	} else {
+		response = DealRequestError(ctx, appErr.Code, appErr.Detail)
+	}
+	response.ErrorCode = appErr.Code
+	if len(appErr.Fields) > 0 || len(appErr.Meta) > 0 {
+		// 有字段错误或附加信息时，data中同时返回错误概述和详细信息
+		data := map[string]interface{}{"detail": response.Data}
+		for key, value := range appErr.Meta {
+			data[key] = value
+		}
+		if len(appErr.Fields) > 0 {
+			data["errors"] = localizeFieldErrors(i18n.FromContext(ctx), appErr.Fields)
+		}
+		response.Data = data
+	}
 	return response
 }
+
+// 处理应用错误，返回RFC 7807格式的响应结构体
+func DealProblem(ctx *gin.Context, appErr *apperror.Error) models.ProblemDetails {
+	lang := i18n.FromContext(ctx)
+	problem := models.ProblemDetails{
+		Type:      config.Configs.Response.ProblemTypeBase + strings.ToLower(strings.ReplaceAll(appErr.Code, "_", "-")),
+		Title:     i18n.Translate(lang, appErr.Code),
+		Status:    appErr.Status,
+		Code:      appErr.Code,
+		RequestID: requestID(ctx),
+	}
+	if appErr.Detail != nil {
+		problem.Detail = appErr.Detail.Localize(lang)
+	}
+	if ctx != nil && ctx.Request != nil {
+		problem.Instance = ctx.Request.URL.Path
+	}
+	problem.Errors = localizeFieldErrors(lang, appErr.Fields)
+	problem.Extensions = appErr.Meta
+	return problem
+}
+
+// 将错误写入批量操作的结果中
+func DealBatchError(ctx *gin.Context, result models.BatchResult, err error) models.BatchResult {
+	appErr := apperror.From(err)
+	if appErr.Kind == apperror.KindServer && appErr.Cause != nil {
+		logger.Printf(ctx, "批量操作%d(%s)处理失败: %s", result.Index, result.Op, appErr.Error())
+	}
+	lang := i18n.FromContext(ctx)
+	result.Success = false
+	result.ErrorCode = appErr.Code
+	result.Message = i18n.Translate(lang, appErr.Code)
+	if appErr.Detail != nil {
+		result.Message = appErr.Detail.Localize(lang)
+	}
+	result.Errors = localizeFieldErrors(lang, appErr.Fields)
+	if len(appErr.Meta) > 0 {
+		result.Data = appErr.Meta
+	}
+	return result
+}
+
+// 将错误转换为导入时某一行的错误
+func DealLineError(ctx *gin.Context, line int, err error) models.ImportLineError {
+	appErr := apperror.From(err)
+	lang := i18n.FromContext(ctx)
+	lineError := models.ImportLineError{
+		Line:      line,
+		ErrorCode: appErr.Code,
+		Message:   i18n.Translate(lang, appErr.Code),
+		Errors:    localizeFieldErrors(lang, appErr.Fields),
+	}
+	if appErr.Detail != nil {
+		lineError.Message = appErr.Detail.Localize(lang)
+	}
+	return lineError
+}
+
+// 翻译字段校验错误
+func localizeFieldErrors(lang string, fields []apperror.FieldError) []models.FieldErrorData {
+	if len(fields) == 0 {
+		return nil
+	}
+	result := make([]models.FieldErrorData, 0, len(fields))
+	for _, field := range fields {
+		result = append(result, models.FieldErrorData{
+			Field:   field.Field,
+			Code:    field.Code,
+			Message: field.Detail.Localize(lang),
+		})
+	}
+	return result
+}
+
+// 响应错误：根据配置和Accept请求头选择原有格式或application/problem+json格式
+// 服务端错误的内部原因只记录日志
+func RespondError(ctx *gin.Context, err error) {
+	appErr := apperror.From(err)
+	if appErr.Kind == apperror.KindServer && appErr.Cause != nil {
+		logger.Printf(ctx, "%s %s 处理失败: %s", ctx.Request.Method, ctx.Request.URL.Path, appErr.Error())
+	}
+	if appErr.RetryAfter > 0 {
+		ctx.Header("Retry-After", strconv.Itoa(appErr.RetryAfter))
+	}
+	if wantsProblem(ctx) {
+		ctx.Header("Content-Type", problemContentType)
+		ctx.JSON(appErr.Status, DealProblem(ctx, appErr))
+		return
+	}
+	ctx.JSON(appErr.Status, DealAppError(ctx, appErr))
+}
+
+const problemContentType = "application/problem+json"
+
+// 判断是否使用problem details格式响应
+func wantsProblem(ctx *gin.Context) bool {
+	if config.Configs.Response.ProblemJSON {
+		return true
+	}
+	return strings.Contains(ctx.GetHeader("Accept"), problemContentType)
+}
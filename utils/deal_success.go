@@ -2,14 +2,26 @@ package utils
 
 import (
 	"miHttpServer/config"
+	"miHttpServer/i18n"
 	"miHttpServer/models"
+
+	"github.com/gin-gonic/gin"
 )
 
 // 处理增加/查询/修改/删除请求成功
-func DealSuccess(msg string, data map[string]interface{}) models.ResponseData {
+func DealSuccess(ctx *gin.Context, data map[string]interface{}) models.ResponseData {
 	var response models.ResponseData
 	response.Code = config.Configs.Code.Success
-	response.Msg = msg
+	response.Msg = i18n.Translate(i18n.FromContext(ctx), i18n.Success)
 	response.Data = data
+	response.RequestID = requestID(ctx)
 	return response
 }
+
+// 请求ID（由中间件设置），没有时返回空字符串
+func requestID(ctx *gin.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	return ctx.GetString("request_id")
+}
@@ -1,14 +1,17 @@
 package utils
 
 import (
+	"context"
 	"miHttpServer/config"
 	"miHttpServer/database"
+	"miHttpServer/logger"
 	"time"
 )
 
 // 获取分布式锁
-func GetLock(key, value string) (bool, error) {
+func GetLock(ctx context.Context, key, value string) (bool, error) {
 	locked, err := database.Lock(
+		ctx,
 		key,
 		value,
 		config.Configs.Lock.ExpireSec,
@@ -18,11 +21,38 @@ func GetLock(key, value string) (bool, error) {
 }
 
 // 释放分布式锁
-func ReleaseLock(key, value string) {
+// 重试3次仍失败时记录日志，锁会在过期后自动释放
+func ReleaseLock(ctx context.Context, key, value string) {
+	var err error
 	for i := 0; i < 3; i++ {
-		err := database.Unlock(key, value)
+		err = database.Unlock(ctx, key, value)
 		if err == nil {
-			break
+			return
 		}
 	}
+	logger.Printf(ctx, "释放分布式锁%s失败: %s", key, err)
+}
+
+// 同时获取多把分布式锁
+func GetLocks(ctx context.Context, keys []string, value string) (bool, error) {
+	locked, err := database.LockMulti(
+		ctx,
+		keys,
+		value,
+		config.Configs.Lock.ExpireSec,
+		time.Duration(config.Configs.Lock.WaitSec)*time.Second,
+	)
+	return locked, err
+}
+
+// 释放多把分布式锁
+func ReleaseLocks(ctx context.Context, keys []string, value string) {
+	var err error
+	for i := 0; i < 3; i++ {
+		err = database.UnlockMulti(ctx, keys, value)
+		if err == nil {
+			return
+		}
+	}
+	logger.Printf(ctx, "释放分布式锁%v失败: %s", keys, err)
 }
@@ -0,0 +1,112 @@
+package validation
+
+import (
+	"fmt"
+	"miHttpServer/apperror"
+	"miHttpServer/config"
+	"miHttpServer/i18n"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// 属性名和标签的格式
+var attributeKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// 属性值（字符串）和标签的最大长度
+const (
+	maxAttributeValueLength = 255
+	maxTagLength            = 64
+)
+
+// 校验商品的自定义属性，属性值只能是字符串、数字或布尔值
+func validateAttributes(attributes map[string]interface{}) []apperror.FieldError {
+	var fields []apperror.FieldError
+	maxAttributes := config.Configs.Item.MaxAttributes
+	if maxAttributes > 0 && len(attributes) > maxAttributes {
+		return append(fields, tooManyAttributesField(maxAttributes))
+	}
+	for key, value := range attributes {
+		field := "attributes." + key
+		if !attributeKeyPattern.MatchString(key) {
+			fields = append(fields, apperror.FieldError{
+				Field:  field,
+				Code:   i18n.FieldInvalidFormat,
+				Detail: i18n.NewError(i18n.FieldInvalidFormat, attributeKeyPattern.String()),
+			})
+			continue
+		}
+		switch v := value.(type) {
+		case bool, float64:
+		case string:
+			if utf8.RuneCountInString(v) > maxAttributeValueLength {
+				fields = append(fields, *fieldErrorAt(field, i18n.FieldTooLong, maxAttributeValueLength))
+			}
+		default:
+			fields = append(fields, *fieldErrorAt(field, i18n.FieldInvalidType, "string, number, bool"))
+		}
+	}
+	// 按字段名排序，保证响应顺序稳定
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Field < fields[j].Field })
+	return fields
+}
+
+// 属性数量超过上限的错误
+func tooManyAttributesField(maxAttributes int) apperror.FieldError {
+	return apperror.FieldError{
+		Field:  "attributes",
+		Code:   i18n.FieldTooManyItems,
+		Detail: i18n.NewError(i18n.FieldTooManyItems, maxAttributes),
+	}
+}
+
+// 部分更新合并后的属性数量超过上限时的错误
+func TooManyAttributes() error {
+	return apperror.InvalidFields([]apperror.FieldError{tooManyAttributesField(config.Configs.Item.MaxAttributes)})
+}
+
+// 去掉标签首尾的空白并去重，保持原来的顺序；nil表示不修改标签
+func NormalizeTags(tags []string) []string {
+	if tags == nil {
+		return nil
+	}
+	seen := make(map[string]struct{}, len(tags))
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if _, ok := seen[tag]; ok {
+			continue
+		}
+		seen[tag] = struct{}{}
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
+
+// 校验商品的标签
+func validateTags(tags []string) []apperror.FieldError {
+	var fields []apperror.FieldError
+	maxTags := config.Configs.Item.MaxTags
+	if maxTags > 0 && len(tags) > maxTags {
+		return append(fields, apperror.FieldError{
+			Field:  "tags",
+			Code:   i18n.FieldTooManyItems,
+			Detail: i18n.NewError(i18n.FieldTooManyItems, maxTags),
+		})
+	}
+	for i, tag := range tags {
+		if fieldErr := Length(1, maxTagLength)(tag); fieldErr != nil {
+			fieldErr.Field = fmt.Sprintf("tags[%d]", i)
+			fields = append(fields, *fieldErr)
+		}
+	}
+	return fields
+}
+
+// 创建指定字段的错误
+func fieldErrorAt(field, code string, args ...interface{}) *apperror.FieldError {
+	fieldErr := fieldError(code, args...)
+	fieldErr.Field = field
+	return fieldErr
+}
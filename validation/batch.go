@@ -0,0 +1,82 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"miHttpServer/apperror"
+	"miHttpServer/config"
+	"miHttpServer/i18n"
+	"miHttpServer/models"
+	"strings"
+)
+
+// 解析批量请求的json，并检查操作数量
+func DecodeBatch(data []byte) (models.BatchRequest, error) {
+	var request models.BatchRequest
+	if err := DecodeStrict(data, &request); err != nil {
+		return request, err
+	}
+	maxOperations := config.Configs.Batch.MaxOperations
+	if len(request.Operations) == 0 {
+		return request, apperror.InvalidFields([]apperror.FieldError{{
+			Field:  "operations",
+			Code:   i18n.FieldRequired,
+			Detail: i18n.NewError(i18n.FieldRequired),
+		}})
+	}
+	if maxOperations > 0 && len(request.Operations) > maxOperations {
+		return request, apperror.InvalidFields([]apperror.FieldError{{
+			Field:  "operations",
+			Code:   i18n.FieldTooManyItems,
+			Detail: i18n.NewError(i18n.FieldTooManyItems, maxOperations),
+		}})
+	}
+	return request, nil
+}
+
+// 校验单个批量操作
+func ValidateBatchOperation(op models.BatchOperation) error {
+	var fields []apperror.FieldError
+	switch op.Op {
+	case models.BatchCreate, models.BatchUpdate, models.BatchDelete:
+	default:
+		fields = append(fields, apperror.FieldError{
+			Field:  "op",
+			Code:   i18n.FieldInvalidEnum,
+			Detail: i18n.NewError(i18n.FieldInvalidEnum, strings.Join([]string{models.BatchCreate, models.BatchUpdate, models.BatchDelete}, ", ")),
+		})
+		return apperror.InvalidFields(fields)
+	}
+	if op.Op != models.BatchCreate && op.ItemID <= 0 {
+		fields = append(fields, apperror.FieldError{
+			Field:  "item_id",
+			Code:   i18n.FieldRequired,
+			Detail: i18n.NewError(i18n.FieldRequired),
+		})
+	}
+	if op.Op != models.BatchDelete {
+		err := ValidateItem(models.RequestData{Name: op.Name, Price: op.Price})
+		var appErr *apperror.Error
+		if errors.As(err, &appErr) {
+			fields = append(fields, appErr.Fields...)
+		}
+	}
+	if len(fields) > 0 {
+		return apperror.InvalidFields(fields)
+	}
+	return nil
+}
+
+// 给字段校验错误的字段名加上前缀，例如operations[2].name
+func PrefixFields(err error, prefix string) error {
+	var appErr *apperror.Error
+	if !errors.As(err, &appErr) || len(appErr.Fields) == 0 {
+		return err
+	}
+	fields := make([]apperror.FieldError, 0, len(appErr.Fields))
+	for _, field := range appErr.Fields {
+		field.Field = fmt.Sprintf("%s.%s", prefix, field.Field)
+		fields = append(fields, field)
+	}
+	return apperror.InvalidFields(fields)
+}
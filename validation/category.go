@@ -0,0 +1,76 @@
+package validation
+
+import (
+	"fmt"
+	"math"
+	"miHttpServer/apperror"
+	"miHttpServer/config"
+	"miHttpServer/i18n"
+	"miHttpServer/models"
+)
+
+// 分类字段的校验规则，名称长度限制与商品名称相同
+func CategoryRules() []Rule {
+	limits := config.Configs.Validation.Item
+	return []Rule{
+		{
+			Field:  "name",
+			Checks: []Check{Required(), Length(limits.NameMinLength, limits.NameMaxLength)},
+		},
+		{
+			Field:  "parent_id",
+			Checks: []Check{Range(0, math.MaxInt64)},
+		},
+	}
+}
+
+// 解析并校验增加和修改分类时请求的json
+func DecodeCategory(data []byte) (models.CategoryRequest, error) {
+	var request models.CategoryRequest
+	if err := DecodeStrict(data, &request); err != nil {
+		return request, err
+	}
+	values := map[string]interface{}{
+		"name":      request.Name,
+		"parent_id": float64(request.ParentID),
+	}
+	return request, Validate(values, CategoryRules(), false)
+}
+
+// 解析并校验设置商品分类时请求的json，重复的category_id只保留一个
+func DecodeItemCategories(data []byte) (models.ItemCategoriesRequest, error) {
+	var request models.ItemCategoriesRequest
+	if err := DecodeStrict(data, &request); err != nil {
+		return request, err
+	}
+	maxPerItem := config.Configs.Category.MaxPerItem
+	if maxPerItem > 0 && len(request.CategoryIDs) > maxPerItem {
+		return request, apperror.InvalidFields([]apperror.FieldError{{
+			Field:  "category_ids",
+			Code:   i18n.FieldTooManyItems,
+			Detail: i18n.NewError(i18n.FieldTooManyItems, maxPerItem),
+		}})
+	}
+	var fields []apperror.FieldError
+	seen := make(map[int64]struct{}, len(request.CategoryIDs))
+	categoryIDs := make([]int64, 0, len(request.CategoryIDs))
+	for i, categoryID := range request.CategoryIDs {
+		if categoryID <= 0 {
+			fields = append(fields, apperror.FieldError{
+				Field:  fmt.Sprintf("category_ids[%d]", i),
+				Code:   i18n.FieldOutOfRange,
+				Detail: i18n.NewError(i18n.FieldOutOfRange, 1, math.MaxInt64),
+			})
+			continue
+		}
+		if _, ok := seen[categoryID]; !ok {
+			seen[categoryID] = struct{}{}
+			categoryIDs = append(categoryIDs, categoryID)
+		}
+	}
+	if len(fields) > 0 {
+		return request, apperror.InvalidFields(fields)
+	}
+	request.CategoryIDs = categoryIDs
+	return request, nil
+}
@@ -0,0 +1,42 @@
+package validation
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"miHttpServer/apperror"
+	"miHttpServer/i18n"
+	"net/http"
+	"strings"
+)
+
+// 严格解析请求的json：不允许未知字段，也不允许多个json值
+// 字段类型不匹配和未知字段返回字段级别的校验错误，其余返回json非法
+func DecodeStrict(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(v)
+	if err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return apperror.InvalidFields([]apperror.FieldError{{
+				Field:  typeErr.Field,
+				Code:   i18n.FieldInvalidType,
+				Detail: i18n.NewError(i18n.FieldInvalidType, typeErr.Type.String()),
+			}})
+		}
+		// encoding/json没有导出未知字段的错误类型，只能根据错误信息判断
+		if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			return apperror.InvalidFields([]apperror.FieldError{{
+				Field:  strings.Trim(field, `"`),
+				Code:   i18n.FieldUnknown,
+				Detail: i18n.NewError(i18n.FieldUnknown),
+			}})
+		}
+		return apperror.InvalidJSON(err)
+	}
+	if decoder.More() {
+		return apperror.Request(http.StatusBadRequest, i18n.InvalidJSON, i18n.NewError(i18n.SingleJSONValue))
+	}
+	return nil
+}
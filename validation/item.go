@@ -0,0 +1,175 @@
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"miHttpServer/apperror"
+	"miHttpServer/config"
+	"miHttpServer/i18n"
+	"miHttpServer/models"
+	"net/http"
+	"reflect"
+	"sort"
+)
+
+// 商品字段的校验规则，限制条件来自配置文件
+func ItemRules() []Rule {
+	limits := config.Configs.Validation.Item
+	return []Rule{
+		{
+			Field:  "name",
+			Checks: []Check{Required(), Length(limits.NameMinLength, limits.NameMaxLength)},
+		},
+		{
+			Field:  "price",
+			Checks: []Check{Finite(), Range(limits.PriceMin, limits.PriceMax), Scale(limits.PriceScale)},
+		},
+	}
+}
+
+// 校验增加和更新商品时请求的数据
+func ValidateItem(requestStr models.RequestData) error {
+	values := map[string]interface{}{
+		"name":  requestStr.Name,
+		"price": requestStr.Price,
+	}
+	err := Validate(values, ItemRules(), false)
+	var fields []apperror.FieldError
+	var appErr *apperror.Error
+	if errors.As(err, &appErr) {
+		fields = append(fields, appErr.Fields...)
+	}
+	fields = append(fields, validateAttributes(requestStr.Attributes)...)
+	fields = append(fields, validateTags(requestStr.Tags)...)
+	if len(fields) > 0 {
+		return apperror.InvalidFields(fields)
+	}
+	return nil
+}
+
+// 解析并校验增加和更新商品时请求的json
+func DecodeItem(data []byte) (models.RequestData, error) {
+	var requestStr models.RequestData
+	if err := DecodeStrict(data, &requestStr); err != nil {
+		return requestStr, err
+	}
+	requestStr.Tags = NormalizeTags(requestStr.Tags)
+	return requestStr, ValidateItem(requestStr)
+}
+
+// 解析并校验部分更新商品时请求的json（RFC 7396 JSON Merge Patch）
+// 只校验请求中出现的字段，name和price不可为空，因此不允许设置为null
+// attributes与已有属性合并，属性值为null时删除该属性；tags整体替换；两者为null时清空
+func DecodeItemPatch(data []byte) (models.ItemPatch, error) {
+	var patch models.ItemPatch
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil || raw == nil {
+		// merge patch不是json对象时表示替换整个资源，这里不支持
+		return patch, apperror.Request(http.StatusBadRequest, i18n.InvalidJSON, i18n.NewError(i18n.MergePatchObject))
+	}
+
+	var fields []apperror.FieldError
+	values := make(map[string]interface{}, len(raw))
+	for field, value := range raw {
+		var target interface{}
+		switch field {
+		case "name":
+			patch.Name = new(string)
+			target = patch.Name
+		case "price":
+			patch.Price = new(float64)
+			target = patch.Price
+		case "attributes":
+			fields = append(fields, decodeAttributesPatch(&patch, value)...)
+			continue
+		case "tags":
+			fields = append(fields, decodeTagsPatch(&patch, value)...)
+			continue
+		default:
+			fields = append(fields, apperror.FieldError{
+				Field:  field,
+				Code:   i18n.FieldUnknown,
+				Detail: i18n.NewError(i18n.FieldUnknown),
+			})
+			continue
+		}
+		if string(value) == "null" {
+			fields = append(fields, apperror.FieldError{
+				Field:  field,
+				Code:   i18n.FieldRequired,
+				Detail: i18n.NewError(i18n.FieldRequired),
+			})
+			continue
+		}
+		if err := json.Unmarshal(value, target); err != nil {
+			fields = append(fields, apperror.FieldError{
+				Field:  field,
+				Code:   i18n.FieldInvalidType,
+				Detail: i18n.NewError(i18n.FieldInvalidType, reflect.TypeOf(target).Elem().String()),
+			})
+			continue
+		}
+		values[field] = reflect.ValueOf(target).Elem().Interface()
+	}
+	if len(fields) > 0 {
+		// 按字段名排序，保证响应顺序稳定
+		sort.Slice(fields, func(i, j int) bool { return fields[i].Field < fields[j].Field })
+		return patch, apperror.InvalidFields(fields)
+	}
+	return patch, Validate(values, ItemRules(), true)
+}
+
+// 解析部分更新的属性，null表示清空全部属性
+// 合并后的属性数量由更新时检查，这里只校验请求中设置的属性
+func decodeAttributesPatch(patch *models.ItemPatch, value json.RawMessage) []apperror.FieldError {
+	if string(value) == "null" {
+		patch.ClearAttributes = true
+		return nil
+	}
+	if err := json.Unmarshal(value, &patch.Attributes); err != nil || patch.Attributes == nil {
+		return []apperror.FieldError{*fieldErrorAt("attributes", i18n.FieldInvalidType, "object")}
+	}
+	set := make(map[string]interface{}, len(patch.Attributes))
+	for key, v := range patch.Attributes {
+		if v != nil {
+			set[key] = v
+		}
+	}
+	return validateAttributes(set)
+}
+
+// 解析部分更新的标签，null表示清空全部标签
+func decodeTagsPatch(patch *models.ItemPatch, value json.RawMessage) []apperror.FieldError {
+	if string(value) == "null" {
+		patch.Tags = []string{}
+		return nil
+	}
+	var tags []string
+	if err := json.Unmarshal(value, &tags); err != nil || tags == nil {
+		return []apperror.FieldError{*fieldErrorAt("tags", i18n.FieldInvalidType, "[]string")}
+	}
+	patch.Tags = NormalizeTags(tags)
+	return validateTags(patch.Tags)
+}
+
+// 校验导入商品时的一行数据
+func ValidateImportItem(row models.ImportItem) error {
+	var fields []apperror.FieldError
+	if row.ItemID < 0 {
+		fields = append(fields, apperror.FieldError{
+			Field:  "item_id",
+			Code:   i18n.FieldOutOfRange,
+			Detail: i18n.NewError(i18n.FieldOutOfRange, 0, math.MaxInt64),
+		})
+	}
+	err := ValidateItem(models.RequestData{Name: row.Name, Price: row.Price})
+	var appErr *apperror.Error
+	if errors.As(err, &appErr) {
+		fields = append(fields, appErr.Fields...)
+	}
+	if len(fields) > 0 {
+		return apperror.InvalidFields(fields)
+	}
+	return nil
+}
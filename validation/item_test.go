@@ -0,0 +1,208 @@
+package validation
+
+import (
+	"errors"
+	"log"
+	"miHttpServer/apperror"
+	"miHttpServer/config"
+	"miHttpServer/i18n"
+	"miHttpServer/models"
+	"miHttpServer/utils"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// 校验规则的限制条件来自配置文件
+func TestMain(m *testing.M) {
+	// 配置文件的路径相对于项目根目录
+	if err := os.Chdir(".."); err != nil {
+		log.Fatalln(err)
+	}
+	utils.ParseYaml()
+	os.Exit(m.Run())
+}
+
+// 返回错误中各字段的错误码（字段 -> 错误码）
+func fieldCodes(t *testing.T, err error) map[string]string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var appErr *apperror.Error
+	if !errors.As(err, &appErr) {
+		t.Fatalf("错误不是apperror.Error: %v", err)
+	}
+	codes := make(map[string]string, len(appErr.Fields))
+	for _, field := range appErr.Fields {
+		codes[field.Field] = field.Code
+	}
+	return codes
+}
+
+func TestDecodeItemPatchAttributesAndTags(t *testing.T) {
+	maxTags := config.Configs.Item.MaxTags
+	config.Configs.Item.MaxTags = 2
+	defer func() { config.Configs.Item.MaxTags = maxTags }()
+
+	tests := []struct {
+		name           string
+		body           string
+		wantFields     map[string]string
+		wantAttributes map[string]interface{}
+		wantClear      bool
+		wantTags       []string
+	}{
+		{
+			name:           "合并属性，null删除属性",
+			body:           `{"attributes": {"color": "red", "size": null, "weight": 1.5}}`,
+			wantAttributes: map[string]interface{}{"color": "red", "size": nil, "weight": 1.5},
+		},
+		{
+			name:      "attributes为null时清空属性",
+			body:      `{"attributes": null}`,
+			wantClear: true,
+		},
+		{
+			name:     "标签去掉空白并去重",
+			body:     `{"tags": [" a ", "a", "b"]}`,
+			wantTags: []string{"a", "b"},
+		},
+		{
+			name:     "tags为null时清空标签",
+			body:     `{"tags": null}`,
+			wantTags: []string{},
+		},
+		{
+			name:       "属性不是对象",
+			body:       `{"attributes": [1]}`,
+			wantFields: map[string]string{"attributes": i18n.FieldInvalidType},
+		},
+		{
+			name:       "属性名格式和属性值类型错误",
+			body:       `{"attributes": {"bad key": 1, "nested": {"a": 1}}}`,
+			wantFields: map[string]string{"attributes.bad key": i18n.FieldInvalidFormat, "attributes.nested": i18n.FieldInvalidType},
+		},
+		{
+			name:       "标签数量超过上限",
+			body:       `{"tags": ["a", "b", "c"]}`,
+			wantFields: map[string]string{"tags": i18n.FieldTooManyItems},
+		},
+		{
+			name:       "空标签",
+			body:       `{"tags": [" "]}`,
+			wantFields: map[string]string{"tags[0]": i18n.FieldTooShort},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patch, err := DecodeItemPatch([]byte(tt.body))
+			if got := fieldCodes(t, err); !reflect.DeepEqual(got, tt.wantFields) {
+				t.Fatalf("字段错误为%v，应为%v", got, tt.wantFields)
+			}
+			if tt.wantFields != nil {
+				return
+			}
+			if !reflect.DeepEqual(patch.Attributes, tt.wantAttributes) {
+				t.Errorf("attributes为%v，应为%v", patch.Attributes, tt.wantAttributes)
+			}
+			if patch.ClearAttributes != tt.wantClear {
+				t.Errorf("ClearAttributes为%v，应为%v", patch.ClearAttributes, tt.wantClear)
+			}
+			if !reflect.DeepEqual(patch.Tags, tt.wantTags) {
+				t.Errorf("tags为%#v，应为%#v", patch.Tags, tt.wantTags)
+			}
+			if patch.Name != nil || patch.Price != nil {
+				t.Errorf("未传的name和price应为nil")
+			}
+		})
+	}
+}
+
+// 返回错误的错误码，没有错误时返回空字符串
+func errorCode(t *testing.T, err error) string {
+	t.Helper()
+	if err == nil {
+		return ""
+	}
+	var appErr *apperror.Error
+	if !errors.As(err, &appErr) {
+		t.Fatalf("错误不是apperror.Error: %v", err)
+	}
+	return appErr.Code
+}
+
+func TestDecodeItem(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantCode   string
+		wantFields map[string]string
+	}{
+		{name: "合法", body: `{"name": "小米手环", "price": 249.99, "tags": ["a"]}`},
+		{name: "未知字段", body: `{"name": "a", "price": 1, "color": "red"}`, wantCode: i18n.ValidationFailed, wantFields: map[string]string{"color": i18n.FieldUnknown}},
+		{name: "字段类型错误", body: `{"name": "a", "price": "1"}`, wantCode: i18n.ValidationFailed, wantFields: map[string]string{"price": i18n.FieldInvalidType}},
+		{name: "多个json值", body: `{"name": "a", "price": 1} {}`, wantCode: i18n.InvalidJSON},
+		{name: "json格式错误", body: `{"name": "a",`, wantCode: i18n.InvalidJSON},
+		{
+			name:       "名称为空且价格超出范围",
+			body:       `{"name": " ", "price": -1}`,
+			wantCode:   i18n.ValidationFailed,
+			wantFields: map[string]string{"name": i18n.FieldRequired, "price": i18n.FieldOutOfRange},
+		},
+		{name: "价格小数位数过多", body: `{"name": "a", "price": 1.234}`, wantCode: i18n.ValidationFailed, wantFields: map[string]string{"price": i18n.FieldTooManyDecimals}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecodeItem([]byte(tt.body))
+			if got := errorCode(t, err); got != tt.wantCode {
+				t.Fatalf("错误码为%q，应为%q: %v", got, tt.wantCode, err)
+			}
+			if tt.wantFields == nil {
+				return
+			}
+			if got := fieldCodes(t, err); !reflect.DeepEqual(got, tt.wantFields) {
+				t.Errorf("字段错误为%v，应为%v", got, tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestDecodeItemPatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantCode   string
+		wantFields map[string]string
+	}{
+		{name: "只修改价格为零值", body: `{"price": 0}`},
+		{name: "不是json对象", body: `[1]`, wantCode: i18n.InvalidJSON},
+		{name: "null", body: `null`, wantCode: i18n.InvalidJSON},
+		{name: "名称不能为null", body: `{"name": null}`, wantCode: i18n.ValidationFailed, wantFields: map[string]string{"name": i18n.FieldRequired}},
+		{name: "未知字段", body: `{"site": "uk"}`, wantCode: i18n.ValidationFailed, wantFields: map[string]string{"site": i18n.FieldUnknown}},
+		{name: "字段类型错误", body: `{"price": true}`, wantCode: i18n.ValidationFailed, wantFields: map[string]string{"price": i18n.FieldInvalidType}},
+		{name: "只校验出现的字段", body: `{"name": ""}`, wantCode: i18n.ValidationFailed, wantFields: map[string]string{"name": i18n.FieldRequired}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecodeItemPatch([]byte(tt.body))
+			if got := errorCode(t, err); got != tt.wantCode {
+				t.Fatalf("错误码为%q，应为%q: %v", got, tt.wantCode, err)
+			}
+			if tt.wantFields == nil {
+				return
+			}
+			if got := fieldCodes(t, err); !reflect.DeepEqual(got, tt.wantFields) {
+				t.Errorf("字段错误为%v，应为%v", got, tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestPrefixFields(t *testing.T) {
+	err := PrefixFields(ValidateBatchOperation(models.BatchOperation{Op: models.BatchUpdate, Name: "a", Price: -1}), "operations[2]")
+	want := map[string]string{"operations[2].item_id": i18n.FieldRequired, "operations[2].price": i18n.FieldOutOfRange}
+	if got := fieldCodes(t, err); !reflect.DeepEqual(got, want) {
+		t.Errorf("字段错误为%v，应为%v", got, want)
+	}
+}
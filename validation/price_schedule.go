@@ -0,0 +1,70 @@
+package validation
+
+import (
+	"miHttpServer/apperror"
+	"miHttpServer/config"
+	"miHttpServer/i18n"
+	"miHttpServer/models"
+	"time"
+)
+
+// 定时价格的时间格式（站点的当地时间），也可以使用带时区的RFC3339格式
+var localTimeLayouts = []string{"2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02 15:04", "2006-01-02T15:04"}
+
+// 解析并校验增加定时价格时请求的json，开始和结束时间按站点的当地时间解析
+// 价格的限制与商品价格相同，结束时间必须晚于开始时间和当前时间
+func DecodePriceSchedule(data []byte, location *time.Location, now time.Time) (models.PriceSchedule, error) {
+	var schedule models.PriceSchedule
+	var request models.PriceScheduleRequest
+	if err := DecodeStrict(data, &request); err != nil {
+		return schedule, err
+	}
+	limits := config.Configs.Validation.Item
+	values := map[string]interface{}{"price": request.Price}
+	rules := []Rule{{
+		Field:  "price",
+		Checks: []Check{Finite(), Range(limits.PriceMin, limits.PriceMax), Scale(limits.PriceScale)},
+	}}
+	var fields []apperror.FieldError
+	if err := Validate(values, rules, false); err != nil {
+		fields = append(fields, apperror.From(err).Fields...)
+	}
+
+	startAt, startErr := parseLocalTime("start_at", request.StartAt, location)
+	endAt, endErr := parseLocalTime("end_at", request.EndAt, location)
+	for _, fieldErr := range []*apperror.FieldError{startErr, endErr} {
+		if fieldErr != nil {
+			fields = append(fields, *fieldErr)
+		}
+	}
+	if startErr == nil && endErr == nil {
+		if !endAt.After(startAt) {
+			fields = append(fields, *fieldErrorAt("end_at", i18n.FieldNotAfter, "start_at"))
+		} else if !endAt.After(now) {
+			fields = append(fields, *fieldErrorAt("end_at", i18n.FieldNotAfter, now.In(location).Format(localTimeLayouts[0])))
+		}
+	}
+	if len(fields) > 0 {
+		return schedule, apperror.InvalidFields(fields)
+	}
+	schedule.Price = request.Price
+	schedule.StartAt = startAt
+	schedule.EndAt = endAt
+	return schedule, nil
+}
+
+// 按站点的当地时间解析时间，带时区的RFC3339时间按其自身的时区解析
+func parseLocalTime(field, value string, location *time.Location) (time.Time, *apperror.FieldError) {
+	if value == "" {
+		return time.Time{}, fieldErrorAt(field, i18n.FieldRequired)
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	for _, layout := range localTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, location); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fieldErrorAt(field, i18n.FieldInvalidFormat, localTimeLayouts[0])
+}
@@ -0,0 +1,110 @@
+package validation
+
+import (
+	"math"
+	"miHttpServer/apperror"
+	"miHttpServer/i18n"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// 校验单个字段的值，返回nil表示校验通过
+type Check func(value interface{}) *apperror.FieldError
+
+// 字段的校验规则
+type Rule struct {
+	// 字段名（json字段名）
+	Field string
+	// 依次执行的校验，某个校验失败后不再执行后面的校验
+	Checks []Check
+}
+
+// 按规则校验字段，values中不存在的字段视为零值
+// partial为true时只校验values中存在的字段（用于部分更新）
+func Validate(values map[string]interface{}, rules []Rule, partial bool) error {
+	var fields []apperror.FieldError
+	for _, rule := range rules {
+		value, ok := values[rule.Field]
+		if !ok && partial {
+			continue
+		}
+		for _, check := range rule.Checks {
+			if fieldErr := check(value); fieldErr != nil {
+				fieldErr.Field = rule.Field
+				fields = append(fields, *fieldErr)
+				break
+			}
+		}
+	}
+	if len(fields) > 0 {
+		return apperror.InvalidFields(fields)
+	}
+	return nil
+}
+
+// 创建字段错误
+func fieldError(code string, args ...interface{}) *apperror.FieldError {
+	return &apperror.FieldError{Code: code, Detail: i18n.NewError(code, args...)}
+}
+
+// 字符串不能为空（忽略首尾空白）
+func Required() Check {
+	return func(value interface{}) *apperror.FieldError {
+		str, _ := value.(string)
+		if strings.TrimSpace(str) == "" {
+			return fieldError(i18n.FieldRequired)
+		}
+		return nil
+	}
+}
+
+// 字符串长度（字符数）必须在[min, max]之间，max小于等于0时不限制最大长度
+func Length(min, max int) Check {
+	return func(value interface{}) *apperror.FieldError {
+		str, _ := value.(string)
+		n := utf8.RuneCountInString(str)
+		if n < min {
+			return fieldError(i18n.FieldTooShort, min)
+		}
+		if max > 0 && n > max {
+			return fieldError(i18n.FieldTooLong, max)
+		}
+		return nil
+	}
+}
+
+// 数字必须是有限值（不能是NaN或Inf）
+func Finite() Check {
+	return func(value interface{}) *apperror.FieldError {
+		num, _ := value.(float64)
+		if math.IsNaN(num) || math.IsInf(num, 0) {
+			return fieldError(i18n.FieldNotFinite)
+		}
+		return nil
+	}
+}
+
+// 数字必须在[min, max]之间
+func Range(min, max float64) Check {
+	return func(value interface{}) *apperror.FieldError {
+		num, _ := value.(float64)
+		if num < min || num > max {
+			return fieldError(i18n.FieldOutOfRange, min, max)
+		}
+		return nil
+	}
+}
+
+// 数字的小数位数不能超过scale位
+func Scale(scale int) Check {
+	return func(value interface{}) *apperror.FieldError {
+		num, _ := value.(float64)
+		// 使用最短的十进制表示计算小数位数，避免浮点误差
+		str := strconv.FormatFloat(num, 'f', -1, 64)
+		if i := strings.IndexByte(str, '.'); i >= 0 && len(str)-i-1 > scale {
+			return fieldError(i18n.FieldTooManyDecimals, scale)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,56 @@
+package validation
+
+import (
+	"math"
+	"miHttpServer/i18n"
+	"testing"
+)
+
+func TestChecks(t *testing.T) {
+	tests := []struct {
+		name  string
+		check Check
+		value interface{}
+		want  string
+	}{
+		{name: "必填", check: Required(), value: "a"},
+		{name: "必填为空", check: Required(), value: "", want: i18n.FieldRequired},
+		{name: "必填只有空白", check: Required(), value: " \t", want: i18n.FieldRequired},
+		{name: "长度按字符计算", check: Length(1, 2), value: "小米"},
+		{name: "长度过短", check: Length(2, 0), value: "a", want: i18n.FieldTooShort},
+		{name: "长度过长", check: Length(1, 2), value: "小米手", want: i18n.FieldTooLong},
+		{name: "不限制最大长度", check: Length(1, 0), value: "很长很长的名称"},
+		{name: "有限值", check: Finite(), value: 1.5},
+		{name: "NaN", check: Finite(), value: math.NaN(), want: i18n.FieldNotFinite},
+		{name: "Inf", check: Finite(), value: math.Inf(-1), want: i18n.FieldNotFinite},
+		{name: "范围边界", check: Range(0, 10), value: 10.0},
+		{name: "小于最小值", check: Range(0, 10), value: -0.01, want: i18n.FieldOutOfRange},
+		{name: "大于最大值", check: Range(0, 10), value: 10.01, want: i18n.FieldOutOfRange},
+		{name: "小数位数", check: Scale(2), value: 19.99},
+		{name: "整数", check: Scale(0), value: 100.0},
+		{name: "小数位数过多", check: Scale(2), value: 1.005, want: i18n.FieldTooManyDecimals},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fieldErr := tt.check(tt.value)
+			got := ""
+			if fieldErr != nil {
+				got = fieldErr.Code
+			}
+			if got != tt.want {
+				t.Errorf("校验%v的错误码为%q，应为%q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidatePartial(t *testing.T) {
+	// 部分校验时只校验出现的字段，完整校验时缺少的字段视为零值
+	values := map[string]interface{}{"price": -1.0}
+	if got := fieldCodes(t, Validate(values, ItemRules(), true)); len(got) != 1 || got["price"] != i18n.FieldOutOfRange {
+		t.Errorf("部分校验的错误为%v，应只有price", got)
+	}
+	if got := fieldCodes(t, Validate(values, ItemRules(), false)); len(got) != 2 || got["name"] != i18n.FieldRequired {
+		t.Errorf("完整校验的错误为%v，应包含name和price", got)
+	}
+}
@@ -0,0 +1,51 @@
+package validation
+
+import (
+	"errors"
+	"miHttpServer/apperror"
+	"miHttpServer/config"
+	"miHttpServer/i18n"
+	"miHttpServer/models"
+	"regexp"
+	"strings"
+)
+
+// SKU编码和条码的格式
+var (
+	skuCodePattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+	barcodePattern = regexp.MustCompile(`^[0-9]{8,14}$`)
+)
+
+// 解析并校验增加和修改SKU时请求的json
+// 编码必填，条码可以为空，价格的限制与商品价格相同，规格属性的限制与商品属性相同
+func DecodeSKU(data []byte) (models.SKURequest, error) {
+	var request models.SKURequest
+	if err := DecodeStrict(data, &request); err != nil {
+		return request, err
+	}
+	request.Code = strings.TrimSpace(request.Code)
+	request.Barcode = strings.TrimSpace(request.Barcode)
+
+	limits := config.Configs.Validation.Item
+	values := map[string]interface{}{"code": request.Code, "price": request.Price}
+	rules := []Rule{
+		{Field: "code", Checks: []Check{Required()}},
+		{Field: "price", Checks: []Check{Finite(), Range(limits.PriceMin, limits.PriceMax), Scale(limits.PriceScale)}},
+	}
+	var fields []apperror.FieldError
+	var appErr *apperror.Error
+	if err := Validate(values, rules, false); errors.As(err, &appErr) {
+		fields = append(fields, appErr.Fields...)
+	}
+	if request.Code != "" && !skuCodePattern.MatchString(request.Code) {
+		fields = append(fields, *fieldErrorAt("code", i18n.FieldInvalidFormat, skuCodePattern.String()))
+	}
+	if request.Barcode != "" && !barcodePattern.MatchString(request.Barcode) {
+		fields = append(fields, *fieldErrorAt("barcode", i18n.FieldInvalidFormat, barcodePattern.String()))
+	}
+	fields = append(fields, validateAttributes(request.Attributes)...)
+	if len(fields) > 0 {
+		return request, apperror.InvalidFields(fields)
+	}
+	return request, nil
+}
@@ -0,0 +1,23 @@
+package validation
+
+import (
+	"miHttpServer/apperror"
+	"miHttpServer/i18n"
+	"miHttpServer/models"
+	"strings"
+)
+
+// 解析并校验修改商品状态时请求的json，状态必须是草稿、已发布或已归档之一
+func DecodeStatus(data []byte) (models.StatusRequest, error) {
+	var request models.StatusRequest
+	if err := DecodeStrict(data, &request); err != nil {
+		return request, err
+	}
+	if _, ok := models.StatusTransitions[request.Status]; !ok {
+		allowed := []string{models.StatusDraft, models.StatusPublished, models.StatusArchived}
+		return request, apperror.InvalidFields([]apperror.FieldError{
+			*fieldErrorAt("status", i18n.FieldInvalidEnum, strings.Join(allowed, ", ")),
+		})
+	}
+	return request, nil
+}
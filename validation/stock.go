@@ -0,0 +1,36 @@
+package validation
+
+import (
+	"math"
+	"miHttpServer/config"
+	"miHttpServer/models"
+)
+
+// 解析并校验设置库存时请求的json，库存数量不能为负数
+func DecodeStock(data []byte) (models.StockRequest, error) {
+	var request models.StockRequest
+	if err := DecodeStrict(data, &request); err != nil {
+		return request, err
+	}
+	values := map[string]interface{}{"quantity": float64(request.Quantity)}
+	rules := []Rule{{Field: "quantity", Checks: []Check{Range(0, math.MaxInt32)}}}
+	return request, Validate(values, rules, false)
+}
+
+// 解析并校验预留库存时请求的json，ttl_sec为0时使用默认的过期时间
+func DecodeReservation(data []byte) (models.ReservationRequest, error) {
+	var request models.ReservationRequest
+	if err := DecodeStrict(data, &request); err != nil {
+		return request, err
+	}
+	values := map[string]interface{}{"quantity": float64(request.Quantity)}
+	rules := []Rule{{Field: "quantity", Checks: []Check{Range(1, math.MaxInt32)}}}
+	if request.TTLSec != 0 {
+		values["ttl_sec"] = float64(request.TTLSec)
+		rules = append(rules, Rule{
+			Field:  "ttl_sec",
+			Checks: []Check{Range(1, float64(config.Configs.Stock.MaxReservationTTLSec))},
+		})
+	}
+	return request, Validate(values, rules, true)
+}